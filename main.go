@@ -22,112 +22,112 @@
 package main
 
 import (
-	"bufio"
-	"crypto/ed25519"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
-	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
-)
 
-const (
-	defaultRepo = "https://github.com/shrub4thedub/pack-repo"
-	packDir     = ".pack"
-	
-	// Performance and timeout constants
-	httpTimeoutSeconds        = 30
-	dialTimeoutSeconds        = 10
-	keepAliveSeconds         = 30
-	maxIdleConns             = 100
-	maxIdleConnsPerHost      = 10
-	idleConnTimeoutSeconds   = 90
-	tlsHandshakeTimeoutSeconds = 10
-	
-	// Worker pool constants
-	updateCheckWorkers       = 8
-	keyRefreshWorkers       = 4
-	packageDiscoveryWorkers = 6
-	
-	// Cache and pagination constants
-	cacheExpiryMinutes      = 30
-	coreCheckIntervalHours  = 2
-	maxPackagesDisplay      = 20
-	
-	// File permission constants
-	publicFilePerms  = 0644
-	publicDirPerms   = 0755
-	privateFilePerms = 0600
-	privateDirPerms  = 0700
+	"pack/pkg/pack"
 )
 
-// Global HTTP client with connection pooling for better performance
-var httpClient = &http.Client{
-	Timeout: httpTimeoutSeconds * time.Second,
-	Transport: &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   dialTimeoutSeconds * time.Second,
-			KeepAlive: keepAliveSeconds * time.Second,
-		}).DialContext,
-		MaxIdleConns:        maxIdleConns,
-		MaxIdleConnsPerHost: maxIdleConnsPerHost,
-		IdleConnTimeout:     idleConnTimeoutSeconds * time.Second,
-		TLSHandshakeTimeout: tlsHandshakeTimeoutSeconds * time.Second,
-	},
-}
+const maxPackagesDisplay = 20
 
-type Config struct {
-	Sources []string
-}
+// manager does the actual work, main only parses arguments and prints
+var manager *pack.Manager
 
-type Source struct {
-	URL string
-	Name string
-}
+// outputFormat is set by --json and --format for commands that support them
+var outputFormat = pack.FormatText
 
-type PackageCache struct {
-	Metadata  map[string]CachedPackage
-	Timestamp time.Time
-}
+func main() {
+	args := os.Args[1:]
 
-type CachedPackage struct {
-	Name        string
-	Description string
-	Version     string
-	License     string
-	Source      string
-	CachedAt    time.Time
-}
+	// --yes, --no-review and --source answer questions for scripts
+	policy, args, err := parsePromptFlags(args)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// the rest of the flags depend on the command, which they may come before
+	command := commandName(args)
+
+	// machine-readable output keeps stdout for records, everything else goes to stderr
+	out := os.Stdout
+	switch command {
+	case "shelf", "list", "seek", "peek", "update", "outdated", "open", "close":
+		outputFormat, args, err = parseFormatFlags(args)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+		if outputFormat != pack.FormatText {
+			out = os.Stderr
+		}
+	case "export":
+		// so does export, which writes the packfile to stdout
+		out = os.Stderr
+	}
+
+	// list, seek and peek answer from the metadata cache and take flags to control it
+	cacheMode := pack.CacheDefault
+	switch command {
+	case "list", "seek", "peek":
+		cacheMode, args, err = parseCacheFlags(args)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// without a terminal nobody can answer, so questions the policy doesn't
+	// cover fail straight away and box scripts get no stdin to wait on
+	var prompter pack.Prompter = pack.NewTerminalPrompter(os.Stdin, out)
+	stdin := os.Stdin
+	interactive := pack.IsTerminal(os.Stdin) && !nonInteractiveEnv()
+	if !interactive {
+		prompter = nil
+		stdin = nil
+	}
+	if !interactive || policy != (pack.Policy{}) {
+		prompter = pack.NewPolicyPrompter(policy, prompter, out)
+	}
+
+	opts := pack.Options{
+		Out:      out,
+		Prompter: prompter,
+		Cache:    cacheMode,
+	}
+	if stdin != nil {
+		opts.Stdin = stdin
+	}
+	manager, err = pack.New(opts)
+	if err != nil {
+		fmt.Printf("failed to set up pack: %v\n", err)
+		os.Exit(1)
+	}
 
-func main() {
 	// check pack directory structure exists cuz we need that shit
-	if err := ensurePackDirExists(); err != nil {
+	if err := manager.EnsureDirs(); err != nil {
 		fmt.Printf("failed to create pack directory: %v\n", err)
 		os.Exit(1)
 	}
 
 	// bootstrap box interpreter if missing cuz we need that shit too
-	if err := ensureBoxExists(); err != nil {
+	if err := manager.EnsureBox(); err != nil {
 		fmt.Printf("failed to bootstrap box interpreter: %v\n", err)
 		os.Exit(1)
 	}
 
-	args := os.Args[1:]
 	if len(args) == 0 {
 		showHelp()
 		return
 	}
 
-	command := args[0]
+	command = args[0]
 	switch command {
 	case "open":
 		if len(args) < 2 {
@@ -163,6 +163,60 @@ func main() {
 		seekPackages(args[1:])
 	case "update":
 		updatePackages(args[1:])
+	case "outdated":
+		outdatedPackages(args[1:])
+	case "files":
+		if len(args) < 2 {
+			fmt.Println("error: package name required")
+			fmt.Println("usage: pack files <package>")
+			os.Exit(1)
+		}
+		listPackageFiles(args[1:])
+	case "owns":
+		if len(args) < 2 {
+			fmt.Println("error: path required")
+			fmt.Println("usage: pack owns <path>")
+			os.Exit(1)
+		}
+		showOwner(args[1:])
+	case "log":
+		if len(args) < 2 {
+			fmt.Println("error: package name required")
+			fmt.Println("usage: pack log <package> [--last|--list]")
+			os.Exit(1)
+		}
+		showBuildLog(args[1:])
+	case "doctor":
+		runDoctor(args[1:])
+	case "verify":
+		verifyPackages(args[1:])
+	case "rollback":
+		if len(args) < 2 {
+			fmt.Println("error: package name required")
+			fmt.Println("usage: pack rollback <package> [generation]")
+			os.Exit(1)
+		}
+		rollbackPackage(args[1:])
+	case "export":
+		exportPackages(args[1:])
+	case "import", "sync":
+		if len(args) < 2 {
+			fmt.Println("error: packfile required")
+			fmt.Printf("usage: pack %s <packfile>\n", command)
+			os.Exit(1)
+		}
+		applyPackfile(command, args[1:])
+	case "history":
+		showHistory(args[1:])
+	case "undo":
+		undoTransaction(args[1:])
+	case "hold", "unhold":
+		if len(args) < 2 {
+			fmt.Println("error: package name required")
+			fmt.Printf("usage: pack %s <package>\n", command)
+			os.Exit(1)
+		}
+		holdPackages(command, args[1:])
 	case "clean":
 		cleanTempDirectory(args[1:])
 	case "add-source":
@@ -195,7 +249,7 @@ func main() {
 	case "repo":
 		if len(args) < 2 {
 			fmt.Println("error: repo subcommand required")
-			fmt.Println("usage: pack repo <create|keygen|sign>")
+			fmt.Println("usage: pack repo <create|keygen|sign|index>")
 			os.Exit(1)
 		}
 		handleRepoCommand(args[1:])
@@ -206,130 +260,260 @@ func main() {
 	}
 }
 
+// parseCacheFlags strips --refresh and --offline from args
+func parseCacheFlags(args []string) (pack.CacheMode, []string, error) {
+	mode := pack.CacheDefault
+	var rest []string
+
+	for _, arg := range args {
+		switch arg {
+		case "--refresh":
+			if mode == pack.CacheOffline {
+				return mode, nil, fmt.Errorf("--refresh and --offline cannot be used together")
+			}
+			mode = pack.CacheRefresh
+		case "--offline":
+			if mode == pack.CacheRefresh {
+				return mode, nil, fmt.Errorf("--refresh and --offline cannot be used together")
+			}
+			mode = pack.CacheOffline
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return mode, rest, nil
+}
+
+// commandName returns the first argument that is neither a flag nor the
+// value of --format
+func commandName(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--format":
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// parsePromptFlags pulls --yes, --no-review and --source out of args
+func parsePromptFlags(args []string) (pack.Policy, []string, error) {
+	var policy pack.Policy
+	var rest []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--yes" || arg == "-y":
+			policy.AssumeYes = true
+		case arg == "--no-review":
+			policy.SkipReview = true
+		case arg == "--source":
+			if i+1 >= len(args) {
+				return policy, nil, fmt.Errorf("--source needs a source name")
+			}
+			i++
+			policy.Source = args[i]
+		case strings.HasPrefix(arg, "--source="):
+			policy.Source = strings.TrimPrefix(arg, "--source=")
+			if policy.Source == "" {
+				return policy, nil, fmt.Errorf("--source needs a source name")
+			}
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return policy, rest, nil
+}
+
+// parseFormatFlags pulls --json and --format out of args
+func parseFormatFlags(args []string) (pack.Format, []string, error) {
+	format := pack.FormatText
+	var rest []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var err error
+		switch {
+		case arg == "--json":
+			format = pack.FormatJSON
+		case arg == "--format":
+			if i+1 >= len(args) {
+				return format, nil, fmt.Errorf("--format needs text, json or tsv")
+			}
+			i++
+			format, err = pack.ParseFormat(args[i])
+		case strings.HasPrefix(arg, "--format="):
+			format, err = pack.ParseFormat(strings.TrimPrefix(arg, "--format="))
+		default:
+			rest = append(rest, arg)
+		}
+		if err != nil {
+			return format, nil, err
+		}
+	}
+
+	return format, rest, nil
+}
+
+// reportError prints err as a structured record on stderr for
+// machine-readable output, or as text otherwise
+func reportError(err error, text string) {
+	if outputFormat != pack.FormatText {
+		pack.WriteError(os.Stderr, outputFormat, pack.NewErrorRecord(err))
+		return
+	}
+	fmt.Println(text)
+}
+
+// reportSourceError is reportError for a whole source
+func reportSourceError(source pack.Source, err error, text string) {
+	if outputFormat != pack.FormatText {
+		record := pack.NewErrorRecord(err)
+		record.Source = source.URL
+		pack.WriteError(os.Stderr, outputFormat, record)
+		return
+	}
+	fmt.Println(text)
+}
+
+// writeOutput reports a failure to write machine-readable output
+func writeOutput(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// nonInteractiveEnv reports whether PACK_NONINTERACTIVE asks pack never to
+// wait for input
+func nonInteractiveEnv() bool {
+	value := os.Getenv("PACK_NONINTERACTIVE")
+	return value != "" && value != "0" && value != "false"
+}
 
 func openPackage(args []string) {
 	if len(args) > 0 && args[0] == "help" {
 		showOpenHelp()
 		return
 	}
-	
+
 	if len(args) == 0 {
 		fmt.Println("error: package name(s) required")
 		fmt.Println("usage: pack open <package1> [package2] [package3] ... [--verbose]")
 		os.Exit(1)
 	}
-	
+
 	// Parse arguments - separate packages from flags
 	var packageNames []string
-	verbose := false
-	
-	for _, arg := range args {
+	var opts pack.InstallOptions
+	dryRun := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		if arg == "--verbose" || arg == "-v" {
-			verbose = true
+			opts.Verbose = true
+		} else if arg == "--dry-run" {
+			dryRun = true
+		} else if arg == "--jobs" || arg == "-j" {
+			if i+1 >= len(args) {
+				fmt.Printf("error: %s requires a number\n", arg)
+				os.Exit(1)
+			}
+			i++
+			jobs, err := strconv.Atoi(args[i])
+			if err != nil || jobs < 1 {
+				fmt.Printf("error: invalid number of jobs '%s'\n", args[i])
+				os.Exit(1)
+			}
+			opts.Jobs = jobs
 		} else {
 			packageNames = append(packageNames, arg)
 		}
 	}
-	
+
 	if len(packageNames) == 0 {
 		fmt.Println("error: no package names provided")
 		os.Exit(1)
 	}
-	
+	checkDryRunFormat(dryRun)
+
+	if dryRun {
+		var run *pack.DryRun
+		var err error
+		if len(packageNames) == 1 {
+			run, err = manager.DryRunInstall(packageNames[0])
+		} else {
+			run, err = manager.DryRunInstallAll(packageNames)
+		}
+		showDryRun(run, err)
+		return
+	}
+
 	// Check for pack/boxlang updates before installing any package
-	checkAndUpdateCorePackages()
-	
+	manager.AutoUpdateCore()
+
 	// Handle single package (legacy behavior)
 	if len(packageNames) == 1 {
-		if err := executePackageScript(packageNames[0], false, verbose); err != nil {
+		if _, err := manager.Install(packageNames[0], opts); err != nil {
 			fmt.Printf("error opening package %s: %v\n", packageNames[0], err)
 			os.Exit(1)
 		}
 		return
 	}
-	
+
 	// Handle multiple packages
-	installMultiplePackages(packageNames, verbose)
+	installMultiplePackages(packageNames, opts)
 }
 
 // installMultiplePackages handles installing multiple packages with confirmation sequentially
-func installMultiplePackages(packageNames []string, verbose bool) {
-	fmt.Printf("Planning to install %d package(s): %s\n", len(packageNames), strings.Join(packageNames, ", "))
-	
-	// Check which packages are already installed
-	var packagesToInstall []string
-	var alreadyInstalled []string
-	
-	for _, packageName := range packageNames {
-		lockFilePath, err := getLockFilePath(packageName)
-		if err == nil {
-			if _, err := os.Stat(lockFilePath); err == nil {
-				alreadyInstalled = append(alreadyInstalled, packageName)
-			} else {
-				packagesToInstall = append(packagesToInstall, packageName)
-			}
-		} else {
-			packagesToInstall = append(packagesToInstall, packageName)
-		}
-	}
-	
-	// Show status
-	if len(alreadyInstalled) > 0 {
-		fmt.Printf("Already installed: %s\n", strings.Join(alreadyInstalled, ", "))
-	}
-	
-	if len(packagesToInstall) == 0 {
-		fmt.Println("All packages are already installed.")
+func installMultiplePackages(packageNames []string, opts pack.InstallOptions) {
+	batch, err := manager.InstallAll(packageNames, opts)
+	if errors.Is(err, pack.ErrCancelled) {
+		fmt.Println("Installation cancelled.")
 		return
 	}
-	
-	fmt.Printf("To install: %s\n", strings.Join(packagesToInstall, ", "))
-	
-	// Ask for confirmation
-	fmt.Printf("\nInstall %d package(s)? [y/N]: ", len(packagesToInstall))
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
 	if err != nil {
-		fmt.Printf("error reading input: %v\n", err)
+		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
-	
-	response = strings.TrimSpace(strings.ToLower(response))
-	if response != "y" && response != "yes" {
-		fmt.Println("Installation cancelled.")
+
+	if len(batch.Installed) == 0 && len(batch.Failed) == 0 {
+		fmt.Println("All packages are already installed.")
 		return
 	}
-	
-	// Install packages sequentially
-	fmt.Printf("\nInstalling %d package(s)...\n\n", len(packagesToInstall))
-	
-	var successful []string
-	var failed []string
-	
-	for i, packageName := range packagesToInstall {
-		fmt.Printf("[%d/%d] Installing %s...\n", i+1, len(packagesToInstall), packageName)
-		
-		if err := executePackageScript(packageName, false, verbose); err != nil {
-			fmt.Printf("✗ Failed to install %s: %v\n", packageName, err)
-			failed = append(failed, packageName)
-		} else {
-			fmt.Printf("✓ Successfully installed %s\n", packageName)
-			successful = append(successful, packageName)
-		}
-		
-		if i < len(packagesToInstall)-1 {
-			fmt.Println()
-		}
-	}
-	
+
 	// Show summary
 	fmt.Printf("\n=== Installation Summary ===\n")
-	if len(successful) > 0 {
+	if len(batch.Installed) > 0 {
+		var successful []string
+		for _, result := range batch.Installed {
+			successful = append(successful, result.Package)
+		}
 		fmt.Printf("✓ Successfully installed: %s\n", strings.Join(successful, ", "))
 	}
-	
-	if len(failed) > 0 {
+
+	if len(batch.Failed) > 0 {
+		var failed []string
+		for _, failure := range batch.Failed {
+			failed = append(failed, failure.Package)
+		}
 		fmt.Printf("✗ Failed to install: %s\n", strings.Join(failed, ", "))
+	}
+
+	if len(batch.Skipped) > 0 {
+		var skipped []string
+		for _, skip := range batch.Skipped {
+			skipped = append(skipped, skip.Package)
+		}
+		fmt.Printf("✗ Skipped (dependency failed): %s\n", strings.Join(skipped, ", "))
+	}
+
+	if len(batch.Failed) > 0 || len(batch.Skipped) > 0 {
 		os.Exit(1)
 	}
 }
@@ -339,17 +523,44 @@ func closePackage(args []string) {
 		showCloseHelp()
 		return
 	}
-	
+
 	if len(args) == 0 {
 		fmt.Println("error: package name required")
 		fmt.Println("usage: pack close <package>")
 		os.Exit(1)
 	}
-	
-	packageName := args[0]
+
+	packageName := ""
+	dryRun := false
+	for _, arg := range args {
+		switch {
+		case arg == "--dry-run":
+			dryRun = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Printf("error: unknown option '%s'\n", arg)
+			os.Exit(1)
+		case packageName != "":
+			fmt.Printf("error: unexpected argument '%s' (close takes one package)\n", arg)
+			os.Exit(1)
+		default:
+			packageName = arg
+		}
+	}
+	if packageName == "" {
+		fmt.Println("error: package name required")
+		fmt.Println("usage: pack close <package>")
+		os.Exit(1)
+	}
+	checkDryRunFormat(dryRun)
+	if dryRun {
+		run, err := manager.DryRunUninstall(packageName)
+		showDryRun(run, err)
+		return
+	}
+
 	fmt.Printf("closing package: %s\n", packageName)
-	
-	if err := executeUninstallScript(packageName); err != nil {
+
+	if err := manager.Uninstall(packageName); err != nil {
 		fmt.Printf("error closing package %s: %v\n", packageName, err)
 		os.Exit(1)
 	}
@@ -360,3534 +571,1178 @@ func peekPackage(args []string) {
 		showPeekHelp()
 		return
 	}
-	
+
 	if len(args) == 0 {
 		fmt.Println("error: package name required")
 		fmt.Println("usage: pack peek <package>")
 		os.Exit(1)
 	}
-	
+
 	packageName := args[0]
-	
-	if err := showPackageInfo(packageName); err != nil {
-		fmt.Printf("error showing package info for %s: %v\n", packageName, err)
+
+	pkgData, err := manager.Peek(packageName)
+	if err != nil {
+		reportError(&pack.PackageError{Package: packageName, Err: err},
+			fmt.Sprintf("error showing package info for %s: %v", packageName, err))
 		os.Exit(1)
 	}
-}
 
-func addSource(args []string) {
-	if args[0] == "help" {
-		showAddSourceHelp()
+	if outputFormat != pack.FormatText {
+		writeOutput(pack.WritePeek(os.Stdout, outputFormat, packageName, pkgData))
 		return
 	}
-	
-	sourceURL := args[0]
-	
-	// Try to fetch public key from the repository cuz we dont play about security
-	fmt.Printf("Fetching public key for %s...\n", sourceURL)
-	pubkey, err := fetchPublicKeyFromRepo(sourceURL)
-	if err != nil {
-		fmt.Printf("Warning: could not fetch public key: %v\n", err)
-		fmt.Print("Add source without public key verification? [y/N]: ")
-		
-		var response string
-		fmt.Scanln(&response)
-		response = strings.ToLower(strings.TrimSpace(response))
-		
-		if response != "y" && response != "yes" {
-			fmt.Println("Source not added.")
-			return
-		}
-		
-		// Add without public key
-		if err := addSourceToConfig(sourceURL); err != nil {
-			fmt.Printf("error adding source: %v\n", err)
-			os.Exit(1)
-		}
-	} else {
-		// Add with public key
-		if err := addSourceWithKeyToConfig(sourceURL, pubkey); err != nil {
-			fmt.Printf("error adding source: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("✓ Public key verified and cached\n")
+
+	// Display package information
+	fmt.Printf("package: %s\n", packageName)
+	fmt.Println("--------")
+
+	if len(pkgData) == 0 {
+		fmt.Println("no package information available")
+		return
 	}
-	
-	fmt.Printf("✓ Added source: %s\n", sourceURL)
-}
 
-func executePackageScript(packageName string, uninstall bool, verbose ...bool) error {
-	isVerbose := len(verbose) > 0 && verbose[0]
-	// Uninstall is now handled by executeUninstallScript
-	if uninstall {
-		return executeUninstallScript(packageName)
+	canonicalFields := []struct{ key, label string }{
+		{"name", "name"},
+		{"desc", "desc"},
+		{"ver", "version"},
+		{"src-type", "source type"},
+		{"src-url", "source url"},
+		{"src-ref", "source ref"},
+		{"bin", "binary"},
+		{"license", "license"},
 	}
-	
-	packPath, err := getPackDir()
-	if err != nil {
-		return fmt.Errorf("failed to get pack directory: %v", err)
+	labels := make(map[string]string)
+	for _, field := range canonicalFields {
+		labels[field.key] = field.label
 	}
-	packTmpDir := filepath.Join(packPath, "tmp")
-	tempDir, err := os.MkdirTemp(packTmpDir, "pack-"+packageName)
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %v", err)
+
+	// Display fields in canonical order, then any others sorted
+	for _, key := range pack.FieldOrder(pkgData) {
+		label := key
+		if labels[key] != "" {
+			label = labels[key]
+		}
+		fmt.Printf("%s: %s\n", label, pkgData[key])
 	}
-	defer os.RemoveAll(tempDir)
+}
 
-	scriptPath := filepath.Join(tempDir, packageName+".box")
-	selectedSource, err := downloadFromSources(packageName, scriptPath)
-	if err != nil {
-		return fmt.Errorf("failed to download script: %v", err)
+func addSource(args []string) {
+	if args[0] == "help" {
+		showAddSourceHelp()
+		return
 	}
 
-	// verify recipe integrity
-	fmt.Println("verifying recipe integrity...")
-	if err := verifyRecipeIntegrity(scriptPath, selectedSource.Name); err != nil {
-		fmt.Printf("⚠️  warning: %v\n", err)
-		fmt.Print("continue anyway? [y/N]: ")
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("failed to read input: %v", err)
-		}
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
-			return fmt.Errorf("installation cancelled due to verification failure")
+	// Parse arguments - the URL plus optional provider and branch
+	var source pack.Source
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--provider", "--branch":
+			if i+1 >= len(args) {
+				fmt.Printf("error: %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			if args[i] == "--provider" {
+				source.Provider = args[i+1]
+			} else {
+				source.Branch = args[i+1]
+			}
+			i++
+		default:
+			source.URL = args[i]
 		}
-	} else {
-		fmt.Println("✓ recipe integrity verified")
 	}
 
-	// Show recipe and get user confirmation
-	if err := showRecipeAndConfirm(scriptPath); err != nil {
-		return err
+	if source.URL == "" {
+		fmt.Println("error: source URL required")
+		fmt.Println("usage: pack add-source <url> [--provider <name>] [--branch <name>]")
+		os.Exit(1)
 	}
+	sourceURL := source.URL
 
-	boxPath, err := findBoxExecutable()
-	if err != nil {
-		return fmt.Errorf("box executable not found: %v", err)
-	}
-
-	
-	cmdArgs := []string{scriptPath}
-	execCmd := exec.Command(boxPath, cmdArgs...)
-	
-	// Set working directory to the temp directory to contain build debris because random source trees are fucking annoying right
-	execCmd.Dir = tempDir
-	
-	if isVerbose {
-		fmt.Println("executing installation script...")
-	} else {
-		fmt.Println("installing package...")
-	}
-	
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-	execCmd.Stdin = os.Stdin
-	
-	err = execCmd.Run()
-	if err != nil {
-		return fmt.Errorf("script execution failed: %v", err)
-	}
-	
-	fmt.Println("✓ installation complete")
-	fmt.Println("creating lock file...")
-	
-	// Extract source information based on the standard 
-	sourceType, recipeSourceURL, sourceRef, sourceVersion, err := detectSourceTypeAndVersion(scriptPath)
-	if err != nil {
-		fmt.Printf("warning: failed to extract source info: %v\n", err)
-		// Fall back to legacy extraction
-		recipeSourceURL, err = extractRecipeURL(scriptPath)
-		if err != nil {
-			fmt.Printf("warning: failed to extract source URL: %v\n", err)
-			recipeSourceURL = "unknown"
-		}
-		sourceType = "unknown"
-		sourceVersion = "unknown"
-		sourceRef = "unknown"
-	}
-	
-	
-	// Calculate recipe version (content hash)
-	recipeVersion, err := calculateRecipeVersion(scriptPath)
-	if err != nil {
-		fmt.Printf("warning: failed to calculate recipe version: %v\n", err)
-		recipeVersion = "unknown"
-	}
-	
-	// Construct recipe URL from selected source
-	recipeURL := constructRecipeURL(selectedSource, packageName)
-	
-	// Calculate actual SHA256 for lock file
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		fmt.Printf("warning: failed to read script for hash: %v\n", err)
+	verified, err := manager.AddSource(source)
+	if errors.Is(err, pack.ErrCancelled) {
+		fmt.Println("Source not added.")
+		return
 	}
-	contentWithoutSHA256, err := removeCSHA256Field(content)
 	if err != nil {
-		fmt.Printf("warning: failed to remove SHA256 field: %v\n", err)
-	}
-	recipeSHA256 := calculateSHA256(contentWithoutSHA256)
-	
-	// Get repo name from selected source
-	repoName := selectedSource.Name
-	
-	if err := createLockFile(packageName, repoName, recipeSourceURL, sourceType, sourceRef, sourceVersion, recipeVersion, recipeURL, recipeSHA256); err != nil {
-		fmt.Printf("warning: failed to create lock file: %v\n", err)
-	} else {
-		fmt.Println("✓ lockfile created")
+		fmt.Printf("error adding source: %v\n", err)
+		os.Exit(1)
 	}
 
-	return nil
+	if verified {
+		fmt.Printf("✓ Public key verified and cached\n")
+	}
+	fmt.Printf("✓ Added source: %s\n", sourceURL)
 }
 
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
+// listInstalledPackages displays all installed packages with their version information
+func listInstalledPackages(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		showListHelp()
+		return
 	}
-	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	installed, err := manager.Installed()
 	if err != nil {
-		return err
+		reportError(err, err.Error())
+		os.Exit(1)
 	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	return err
-}
 
-func downloadFile(url, filepath string) error {
-	return downloadFileWithCache(url, filepath, false)
-}
-
-// downloadFileWithCache downloads a file with optional ETag caching
-func downloadFileWithCache(url, filepath string, useCache bool) error {
-	// Prepare request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return err
-	}
-	
-	// Use ETag caching if enabled and cache exists
-	if useCache {
-		if etag, err := loadETag(url); err == nil && etag != "" {
-			req.Header.Set("If-None-Match", etag)
+	if outputFormat != pack.FormatText {
+		var records []pack.InstalledRecord
+		for _, pkg := range installed {
+			if pkg.Err != nil {
+				reportError(&pack.PackageError{Package: pkg.Name, Err: pkg.Err}, "")
+				continue
+			}
+			records = append(records, pack.NewInstalledRecord(pkg))
 		}
-	}
-	
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	
-	// Handle 304 Not Modified
-	if resp.StatusCode == http.StatusNotModified {
-		// File hasn't changed, use cached version
-		return nil
-	}
-	
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		writeOutput(pack.WriteInstalled(os.Stdout, outputFormat, records))
+		return
 	}
 
-	// Create output file
-	out, err := os.Create(filepath)
-	if err != nil {
-		return err
+	if len(installed) == 0 {
+		fmt.Println("no packages installed")
+		return
 	}
-	defer out.Close()
 
-	// Download content
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return err
-	}
-	
-	// Save ETag for future requests if caching is enabled
-	if useCache {
-		if etag := resp.Header.Get("ETag"); etag != "" {
-			saveETag(url, etag)
+	fmt.Printf("%-15s %-12s %-30s %s\n", "package", "version", "source", "installed")
+	fmt.Printf("%-15s %-12s %-30s %s\n", "-------", "-------", "------", "---------")
+
+	for _, pkg := range installed {
+		if pkg.Err != nil {
+			fmt.Printf("%-15s %-12s %-30s %s\n", pkg.Name, "error", "error", "error")
+			continue
 		}
-	}
-	
-	return nil
-}
 
-// loadETag loads the cached ETag for a URL
-func loadETag(url string) (string, error) {
-	packPath, err := getPackDir()
-	if err != nil {
-		return "", err
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return "", err
-	}
-	
-	// Create filename from URL hash
-	hash := sha256.Sum256([]byte(url))
-	filename := hex.EncodeToString(hash[:])[:16] + ".etag"
-	etagPath := filepath.Join(cacheDir, filename)
-	
-	content, err := os.ReadFile(etagPath)
-	if err != nil {
-		return "", err
+		// Format the display
+		version := pkg.Lock.SrcRefUsed
+		if len(version) > 12 {
+			version = version[:12]
+		}
+		source := pkg.Lock.SrcURL
+		if len(source) > 30 {
+			source = source[:27] + "..."
+		}
+		installDate := ""
+		if !pkg.Lock.InstalledAt.IsZero() {
+			installDate = pkg.Lock.InstalledAt.Format("2006-01-02")
+		}
+
+		if pkg.Lock.InstallReason == pack.ReasonDependency {
+			installDate += " (dependency)"
+		}
+		if pkg.Lock.Pinned {
+			installDate += " (pinned to " + pkg.Lock.SrcRef + ")"
+		}
+		if pkg.Lock.Held {
+			installDate += " (held)"
+		}
+
+		fmt.Printf("%-15s %-12s %-30s %s\n", pkg.Name, version, source, installDate)
 	}
-	
-	return strings.TrimSpace(string(content)), nil
 }
 
-// saveETag saves the ETag for a URL
-func saveETag(url, etag string) error {
-	packPath, err := getPackDir()
+// listAllPackages displays all packages available in configured repositories
+func listAllPackages(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		fmt.Println("Usage: pack list [source] [--refresh|--offline]")
+		fmt.Println("Lists all packages available in configured repositories")
+		fmt.Println("Optional: specify source index (1, 2, etc.) to list from specific repository")
+		fmt.Println()
+		fmt.Println("Package indexes are cached for 30 minutes.")
+		fmt.Println("  --refresh   check every source for a newer index now")
+		fmt.Println("  --offline   only use the cache, never the network")
+		fmt.Println()
+		fmt.Println("--json or --format=tsv prints every package, untruncated, for scripts.")
+		return
+	}
+
+	sources, err := manager.Sources()
 	if err != nil {
-		return err
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return err
-	}
-	
-	// Create filename from URL hash
-	hash := sha256.Sum256([]byte(url))
-	filename := hex.EncodeToString(hash[:])[:16] + ".etag"
-	etagPath := filepath.Join(cacheDir, filename)
-	
-	return os.WriteFile(etagPath, []byte(etag), 0644)
-}
+		reportError(err, fmt.Sprintf("error getting sources: %v", err))
+		os.Exit(1)
+	}
 
-func findBoxExecutable() (string, error) {
-	// Try to find box in PATH
-	if boxPath, err := exec.LookPath("box"); err == nil {
-		return boxPath, nil
+	if outputFormat != pack.FormatText {
+		listPackageRecords(sources, args)
+		return
 	}
 
-	// Try relative path to boxlang directory
-	currentDir, err := os.Getwd()
-	if err != nil {
-		return "", err
+	if len(sources) == 0 {
+		fmt.Println("no package sources configured")
+		fmt.Println("use 'pack add-source <url>' to add a repository")
+		return
 	}
 
-	// Try ../boxlang/box (assuming pack is sibling to boxlang)
-	relativePath := filepath.Join(filepath.Dir(currentDir), "boxlang", "box")
-	if _, err := os.Stat(relativePath); err == nil {
-		absPath, err := filepath.Abs(relativePath)
-		if err == nil {
-			return absPath, nil
+	// If source specified, list only that source
+	if len(args) > 0 {
+		sourceIndex, err := strconv.Atoi(args[0])
+		if err != nil || sourceIndex < 1 || sourceIndex > len(sources) {
+			fmt.Printf("invalid source index: %s\n", args[0])
+			fmt.Println("available sources:")
+			for i, source := range sources {
+				fmt.Printf("  %d) %s\n", i+1, source.Name)
+			}
+			return
 		}
-	}
 
-	// Try ./box in current directory
-	localPath := "./box"
-	if _, err := os.Stat(localPath); err == nil {
-		return localPath, nil
+		fmt.Printf("Packages from %s:\n", sources[sourceIndex-1].Name)
+		listPackagesFromSource(sources[sourceIndex-1])
+		return
 	}
 
-	return "", fmt.Errorf("box executable not found in PATH or relative paths")
+	// List packages from all sources
+	for i, source := range sources {
+		fmt.Printf("\n%d) %s:\n", i+1, source.Name)
+		listPackagesFromSource(source)
+	}
 }
 
-func showRecipeAndConfirm(scriptPath string) error {
-	// Read and display the script content
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return fmt.Errorf("failed to read script: %v", err)
-	}
-
-	fmt.Println("recipe:")
-	fmt.Println("-------")
-	fmt.Println(string(content))
-	fmt.Println("-------")
-	
-	for {
-		fmt.Print("proceed? [y/e/n]: ")
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
+// listPackageRecords writes every package from sources, or the one source
+// picked by index in args, as records
+func listPackageRecords(sources []pack.Source, args []string) {
+	if len(args) > 0 {
+		sourceIndex, err := strconv.Atoi(args[0])
+		if err != nil || sourceIndex < 1 || sourceIndex > len(sources) {
+			reportError(fmt.Errorf("invalid source index: %s", args[0]), "")
+			os.Exit(1)
+		}
+		sources = sources[sourceIndex-1 : sourceIndex]
+	}
+
+	var records []pack.PackageRecord
+	failed := false
+	for _, source := range sources {
+		packages, err := manager.Available(source)
 		if err != nil {
-			return fmt.Errorf("failed to read input: %v", err)
+			reportSourceError(source, fmt.Errorf("could not read package index: %v", err), "")
+			failed = true
+			continue
 		}
-		
-		response = strings.TrimSpace(strings.ToLower(response))
-		
-		switch response {
-		case "y", "yes", "":
-			return nil
-		case "n", "no":
-			return fmt.Errorf("cancelled")
-		case "e", "edit":
-			if err := editScript(scriptPath); err != nil {
-				fmt.Printf("error editing script: %v\n", err)
-				continue
-			}
-			// Show the updated script and ask again
-			return showRecipeAndConfirm(scriptPath)
-		default:
-			fmt.Println("enter y/e/n")
+		for _, pkg := range packages {
+			records = append(records, pack.NewPackageRecord(source, pkg))
 		}
 	}
+
+	writeOutput(pack.WritePackages(os.Stdout, outputFormat, records))
+	if failed {
+		os.Exit(1)
+	}
 }
 
-func editScript(scriptPath string) error {
-	// Try to find an editor
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = os.Getenv("VISUAL")
-	}
-	
-	// Validate editor from environment variables for security
-	if editor != "" {
-		if !isValidEditor(editor) {
-			fmt.Printf("warning: potentially unsafe editor '%s' from environment, using fallback\n", editor)
-			editor = ""
-		}
+// seekPackages searches for packages by name or description
+func seekPackages(args []string) {
+	if len(args) == 0 {
+		fmt.Println("error: search term required")
+		fmt.Println("usage: pack seek <search_term>")
+		return
+	}
+
+	searchTerm := strings.ToLower(strings.Join(args, " "))
+
+	if outputFormat == pack.FormatText {
+		fmt.Printf("Searching for '%s'...\n\n", searchTerm)
+	}
+
+	results, err := manager.Search(searchTerm)
+	if err != nil {
+		reportError(err, err.Error())
+		os.Exit(1)
 	}
-	
-	if editor == "" {
-		// Try common editors
-		for _, e := range []string{"vim", "nano", "vi"} {
-			if _, err := exec.LookPath(e); err == nil {
-				editor = e
-				break
+
+	if outputFormat != pack.FormatText {
+		var records []pack.PackageRecord
+		for _, result := range results {
+			if result.Err != nil {
+				reportSourceError(result.Source, fmt.Errorf("could not search: %v", result.Err), "")
+				continue
+			}
+			for _, pkg := range result.Packages {
+				records = append(records, pack.NewPackageRecord(result.Source, pkg))
 			}
 		}
-	}
-	if editor == "" {
-		return fmt.Errorf("no editor found")
+		writeOutput(pack.WritePackages(os.Stdout, outputFormat, records))
+		return
 	}
 
-	// Launch editor
-	cmd := exec.Command(editor, scriptPath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	found := false
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("warning: could not search %s: %v\n\n", result.Source.URL, result.Err)
+			continue
+		}
 
-	return cmd.Run()
-}
+		found = true
+		fmt.Printf("From %s:\n", result.Source.Name)
+		for _, pkg := range result.Packages {
+			fmt.Printf("  %-15s - %s\n", pkg.Name, pkg.Description)
+		}
+		fmt.Println()
+	}
 
-// isValidEditor validates that an editor command is safe to execute
-func isValidEditor(editor string) bool {
-	// Check for basic command injection patterns
-	if strings.Contains(editor, ";") || 
-	   strings.Contains(editor, "&") || 
-	   strings.Contains(editor, "|") ||
-	   strings.Contains(editor, "`") ||
-	   strings.Contains(editor, "$") ||
-	   strings.Contains(editor, "$(") {
-		return false
-	}
-	
-	// Only allow single commands (no spaces except in known safe editors)
-	parts := strings.Fields(editor)
-	if len(parts) == 0 {
-		return false
-	}
-	
-	// Check if the base command exists
-	if _, err := exec.LookPath(parts[0]); err != nil {
-		return false
-	}
-	
-	return true
+	if !found {
+		fmt.Printf("No packages found matching '%s'\n", searchTerm)
+		fmt.Println("Try 'pack list' to see all available packages")
+	}
 }
 
-func showPackageInfo(packageName string) error {
-	// Create temporary directory for script
-	tempDir, err := os.MkdirTemp("", "pack-peek-"+packageName)
+// listPackagesFromSource lists packages from a specific source with smart pagination
+func listPackagesFromSource(source pack.Source) {
+	packages, err := manager.Available(source)
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %v", err)
+		fmt.Printf("  could not read package index: %v\n", err)
+		return
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Download or copy script using multi-source selection
-	scriptPath := filepath.Join(tempDir, packageName+".box")
-	
-	_, err = downloadFromSources(packageName, scriptPath)
-	if err != nil {
-		return fmt.Errorf("failed to download script: %v", err)
+	if len(packages) == 0 {
+		fmt.Println("  no packages available")
+		return
 	}
 
-	// Parse and display package information
-	return parseAndDisplayPackageInfo(scriptPath, packageName)
-}
+	fmt.Printf("  %-15s %-50s %s\n", "name", "description", "license")
+	fmt.Printf("  %-15s %-50s %s\n", "----", "-----------", "-------")
 
-func parseAndDisplayPackageInfo(scriptPath, packageName string) error {
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return fmt.Errorf("failed to read script: %v", err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	var pkgData map[string]string
-	var inDataBlock bool
-	var blockIndent int
-
-	// Parse data block
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		// Check for data block start (specifically looking for -c pkg block)
-		if strings.HasPrefix(trimmed, "[data") && strings.Contains(trimmed, "pkg") {
-			inDataBlock = true
-			pkgData = make(map[string]string)
-			// Calculate indentation level
-			blockIndent = len(line) - len(strings.TrimLeft(line, " \t"))
-			continue
-		}
-		
-		// Check for block end
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		// Check for any other block start (should end data block)
-		if inDataBlock && strings.HasPrefix(trimmed, "[") {
-			break
-		}
-		
-		// Parse data block content
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			// Only process lines that are indented more than the block header
-			lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
-			if lineIndent > blockIndent {
-				parts := strings.SplitN(trimmed, " ", 2)
-				if len(parts) >= 1 {
-					key := parts[0]
-					var value string
-					if len(parts) == 2 {
-						value = strings.Trim(parts[1], "\"")
-					}
-					pkgData[key] = value
-				}
-			}
-		}
+	// For large lists, show first packages and total count
+	displayCount := len(packages)
+	if displayCount > maxPackagesDisplay {
+		displayCount = maxPackagesDisplay
 	}
 
-	// Display package information
-	fmt.Printf("package: %s\n", packageName)
-	fmt.Println("--------")
-	
-	if pkgData == nil || len(pkgData) == 0 {
-		fmt.Println("no package information available")
-		return nil
+	for i := 0; i < displayCount; i++ {
+		pkg := packages[i]
+		desc := pkg.Description
+		if len(desc) > 50 {
+			desc = desc[:47] + "..."
+		}
+		fmt.Printf("  %-15s %-50s %s\n", pkg.Name, desc, pkg.License)
 	}
 
-	// Display fields in the new canonical order
-	if name, ok := pkgData["name"]; ok {
-		fmt.Printf("name: %s\n", name)
+	// Show summary if list was truncated
+	if len(packages) > maxPackagesDisplay {
+		fmt.Printf("\n  showing %d of %d packages (use 'pack seek' to search for specific packages)\n",
+			maxPackagesDisplay, len(packages))
 	}
-	if desc, ok := pkgData["desc"]; ok {
-		fmt.Printf("desc: %s\n", desc)
+}
+
+// updatePackages scans for updates and installs them with confirmation
+func updatePackages(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		showUpdateHelp()
+		return
 	}
-	if ver, ok := pkgData["ver"]; ok {
-		fmt.Printf("version: %s\n", ver)
+
+	var opts pack.UpdateOptions
+	check, dryRun := false, false
+	for _, arg := range args {
+		switch arg {
+		case "--include-held":
+			opts.IncludeHeld = true
+		case "--check":
+			check = true
+		case "--dry-run":
+			dryRun = true
+		default:
+			fmt.Printf("error: unknown option '%s'\n", arg)
+			os.Exit(1)
+		}
 	}
-	if srcType, ok := pkgData["src-type"]; ok {
-		fmt.Printf("source type: %s\n", srcType)
+
+	if check {
+		checkOutdated(opts)
+		return
 	}
-	if srcURL, ok := pkgData["src-url"]; ok {
-		fmt.Printf("source url: %s\n", srcURL)
+	if dryRun {
+		if outputFormat == pack.FormatTSV {
+			checkDryRunFormat(true)
+		}
+		run, err := manager.DryRunUpdate(opts)
+		showDryRun(run, err)
+		return
 	}
-	if srcRef, ok := pkgData["src-ref"]; ok {
-		fmt.Printf("source ref: %s\n", srcRef)
+
+	result, err := manager.UpdateAll(opts)
+	if outputFormat != pack.FormatText {
+		writeUpdateRecords(result, err)
+		return
 	}
-	if bin, ok := pkgData["bin"]; ok {
-		fmt.Printf("binary: %s\n", bin)
+	if errors.Is(err, pack.ErrCancelled) {
+		fmt.Println("update cancelled")
+		return
 	}
-	if license, ok := pkgData["license"]; ok {
-		fmt.Printf("license: %s\n", license)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
 	}
 
-	// Display any other field
-	canonicalFields := map[string]bool{
-		"name": true, "desc": true, "ver": true, "src-type": true,
-		"src-url": true, "src-ref": true, "bin": true, "license": true,
-	}
-	for key, value := range pkgData {
-		if !canonicalFields[key] {
-			fmt.Printf("%s: %s\n", key, value)
+	if len(result.Available) == 0 {
+		if len(result.Held) > 0 {
+			fmt.Println("all packages that aren't held are up to date")
+		} else {
+			fmt.Println("all packages are up to date")
 		}
+		return
 	}
 
-	return nil
+	fmt.Println("update complete!")
 }
 
-func getPackDir() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+// checkDryRunFormat exits unless the output format works for the command:
+// open and close only print JSON for a dry run, and dry runs don't do TSV
+func checkDryRunFormat(dryRun bool) {
+	switch {
+	case outputFormat == pack.FormatTSV && dryRun:
+		fmt.Fprintln(os.Stderr, "error: a dry run prints text or json")
+	case outputFormat != pack.FormatText && !dryRun:
+		fmt.Fprintln(os.Stderr, "error: --json and --format only work with --dry-run here")
+	default:
+		return
 	}
-	
-	packPath := filepath.Join(homeDir, packDir)
-	return packPath, nil
+	os.Exit(1)
 }
 
-func getConfigPath() (string, error) {
-	packPath, err := getPackDir()
+// showDryRun prints what pack would do, or the error that stopped it
+// working that out
+func showDryRun(run *pack.DryRun, err error) {
 	if err != nil {
-		return "", err
+		reportError(err, fmt.Sprintf("error: %v", err))
+		os.Exit(1)
 	}
-	
-	configPath := filepath.Join(packPath, "config")
-	return configPath, nil
-}
 
-func getLocalRepoPath() (string, error) {
-	packPath, err := getPackDir()
-	if err != nil {
-		return "", err
+	if outputFormat == pack.FormatJSON {
+		writeOutput(pack.WriteDryRun(os.Stdout, run))
+		return
 	}
-	
-	localPath := filepath.Join(packPath, "local")
-	return localPath, nil
-}
 
-func getShelfPath() (string, error) {
-	packPath, err := getPackDir()
-	if err != nil {
-		return "", err
+	fmt.Println("dry run, nothing was changed")
+	if len(run.AlreadyInstalled) > 0 {
+		fmt.Printf("already installed: %s\n", strings.Join(run.AlreadyInstalled, ", "))
 	}
-	
-	shelfPath := filepath.Join(packPath, "shelf")
-	return shelfPath, nil
-}
-
-func ensurePackDirExists() error {
-	packPath, err := getPackDir()
-	if err != nil {
-		return err
-	}
-	
-	// Create main pack directory
-	if err := os.MkdirAll(packPath, 0755); err != nil {
-		return err
-	}
-	
-	// Create subdirectories (future shrub dont change these its a fucking pain)
-	subdirs := []string{"locks", "config", "tmp", "local", "shelf", "cache"}
-	for _, subdir := range subdirs {
-		subdirPath := filepath.Join(packPath, subdir)
-		if err := os.MkdirAll(subdirPath, 0755); err != nil {
-			return err
-		}
+	if len(run.Held) > 0 {
+		fmt.Printf("held, not updated: %s\n", strings.Join(run.Held, ", "))
+	}
+	if len(run.Steps) == 0 {
+		fmt.Println("nothing to do")
+		return
 	}
-	
-	return nil
-}
 
-func ensureConfigExists() error {
-	configPath, err := getConfigPath()
-	if err != nil {
-		return err
-	}
-	
-	configFile := filepath.Join(configPath, "sources.box")
-	
-	// Check if config file exists
-	if _, err := os.Stat(configFile); err == nil {
-		return nil // Config exists
-	}
-	
-	// Create default config and fetch public key dynamically
-	fmt.Println("Setting up default pack configuration...")
-	
-	// Try to fetch the public key from the default repository
-	pubkey, err := fetchPublicKeyFromRepo(defaultRepo)
-	if err != nil {
-		fmt.Printf("Warning: could not fetch public key from %s: %v\n", defaultRepo, err)
-		fmt.Println("Creating minimal config without public key verification.")
-		
-		// Create minimal config without pubkey
-		defaultConfig := `[data -c sources]
-  repo ` + defaultRepo + `
-end`
-		return os.WriteFile(configFile, []byte(defaultConfig), 0644)
-	}
-	
-	// Create config with fetched public key
-	defaultConfig := `[data -c sources]
-  repo ` + defaultRepo + `
-  pubkey ` + pubkey + `
-end`
-	
-	fmt.Printf("✓ Configured default source with public key verification\n")
-	return os.WriteFile(configFile, []byte(defaultConfig), 0644)
-}
+	for i, step := range run.Steps {
+		name := step.Package
+		if step.Ref != "" {
+			name += "@" + step.Ref
+		}
+		fmt.Printf("\n%d. %s %s", i+1, step.Action, name)
+		if len(step.RequiredBy) > 0 {
+			fmt.Printf(" (dependency of %s)", strings.Join(step.RequiredBy, ", "))
+		}
+		fmt.Println()
 
-// Simple stoopid simple progress bar for updates
-func showProgress(current, total int, message string) {
-	percent := float64(current) / float64(total) * 100
-	barWidth := 30
-	filled := int(percent / 100.0 * float64(barWidth))
-	
-	bar := "["
-	for i := 0; i < barWidth; i++ {
-		if i < filled {
-			bar += "="
-		} else if i == filled && current < total {
-			bar += ">"
+		if step.Source != "" {
+			fmt.Printf("   source:   %s\n", step.Source)
+		}
+		if step.RecipeURL != "" {
+			fmt.Printf("   recipe:   %s\n", step.RecipeURL)
+		}
+		if step.Verified {
+			fmt.Println("   verified: yes")
 		} else {
-			bar += " "
+			fmt.Printf("   verified: NO (%s)\n", step.VerifyError)
+		}
+		for _, conflict := range step.Replaces {
+			fmt.Printf("   replaces: %s (%s)\n", conflict.Installed, strings.Join(conflict.Reasons, ", "))
+		}
+		for _, link := range step.Links {
+			fmt.Printf("   link:     %s", link.Path)
+			switch {
+			case step.Action == pack.ActionRemove:
+				fmt.Print(" (removed)")
+			case link.Current == "":
+				fmt.Print(" (new)")
+			case link.Owner != "":
+				fmt.Printf(" (overwrites %s from %s)", link.Current, link.Owner)
+			default:
+				fmt.Printf(" (overwrites %s)", link.Current)
+			}
+			fmt.Println()
+		}
+		for _, path := range step.Remove {
+			fmt.Printf("   remove:   %s\n", path)
+		}
+		fmt.Printf("   lock:     %s %s\n", step.Lock.Change, step.Lock.Path)
+		for _, field := range step.Lock.Fields {
+			fmt.Printf("             %s: %q -> %q\n", field.Field, field.Old, field.New)
+		}
+		for _, note := range step.Notes {
+			fmt.Printf("   note:     %s\n", note)
 		}
-	}
-	bar += "]"
-	
-	fmt.Printf("\r%s %3.0f%% (%d/%d) %s", bar, percent, current, total, message)
-	if current >= total {
-		fmt.Println()
 	}
 }
 
-// These functions are now only used for updates, not installs
-
-func ensureBoxExists() error {
-	// Check if box is available in PATH
-	if _, err := exec.LookPath("box"); err == nil {
-		return nil // box is available
-	}
-
-	fmt.Println("box interpreter not found, bootstrapping...")
-
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %v", err)
+// outdatedPackages lists available updates without installing anything
+func outdatedPackages(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		showOutdatedHelp()
+		return
 	}
 
-	// Check if box exists in ~/.local/bin
-	localBinDir := filepath.Join(homeDir, ".local", "bin")
-	boxPath := filepath.Join(localBinDir, "box")
-	
-	if _, err := os.Stat(boxPath); err == nil {
-		fmt.Printf("found box at %s\n", boxPath)
-		return nil // box exists in ~/.local/bin
+	var opts pack.UpdateOptions
+	for _, arg := range args {
+		if arg == "--include-held" {
+			opts.IncludeHeld = true
+		} else {
+			fmt.Printf("error: unknown option '%s'\n", arg)
+			os.Exit(1)
+		}
 	}
 
-	fmt.Println("installing box interpreter...")
-	
-	// Create a minimal box bootstrap without using box itself
-	return bootstrapBoxMinimal()
+	checkOutdated(opts)
 }
 
-func bootstrapBoxMinimal() error {
-	fmt.Println("bootstrapping box interpreter...")
-	
-	// For bootstrapping, we'll download and build box directly
-	showProgress(1, 6, "creating temporary directory...")
-	tempDir, err := os.MkdirTemp("", "box-bootstrap-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
+// exit codes for pack outdated and pack update --check
+const (
+	exitUpToDate         = 0
+	exitError            = 1
+	exitUpdatesAvailable = 10
+)
 
-	// Clone boxlang repository
-	showProgress(2, 6, "cloning boxlang repository...")
-	cloneCmd := exec.Command("git", "clone", "https://github.com/shrub4thedub/boxlang.git", tempDir)
-	cloneCmd.Stdout = nil // Suppress git output
-	cloneCmd.Stderr = nil
-	if err := cloneCmd.Run(); err != nil {
-		return fmt.Errorf("failed to clone boxlang repository: %v", err)
+// checkOutdated prints available updates and exits 0 when everything is up
+// to date, 10 when there are updates and 1 when something couldn't be checked
+func checkOutdated(opts pack.UpdateOptions) {
+	result, err := manager.Outdated(opts)
+	if err != nil {
+		reportError(err, err.Error())
+		os.Exit(exitError)
 	}
 
-	// Build box
-	showProgress(3, 6, "building box interpreter...")
-	buildCmd := exec.Command("go", "build", "-o", "box", "cmd/box/main.go")
-	buildCmd.Dir = tempDir
-	buildCmd.Stdout = nil // Suppress build output
-	buildCmd.Stderr = nil
-	if err := buildCmd.Run(); err != nil {
-		return fmt.Errorf("failed to build box: %v", err)
+	if outputFormat != pack.FormatText {
+		writeOutput(pack.WriteUpdates(os.Stdout, outputFormat, pack.UpdateRecords(result)))
+		for _, pkgErr := range result.Failed {
+			reportError(pkgErr, "")
+		}
+	} else {
+		printOutdated(result)
 	}
 
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %v", err)
+	switch {
+	case len(result.Failed) > 0:
+		os.Exit(exitError)
+	case len(result.Available) > 0:
+		os.Exit(exitUpdatesAvailable)
 	}
+	os.Exit(exitUpToDate)
+}
 
-	// Create shelf and bin directories
-	showProgress(4, 6, "creating installation directories...")
-	shelfDir := filepath.Join(homeDir, ".pack", "shelf", "boxlang")
-	localBinDir := filepath.Join(homeDir, ".local", "bin")
-	
-	if err := os.MkdirAll(shelfDir, 0755); err != nil {
-		return fmt.Errorf("failed to create shelf directory: %v", err)
-	}
-	if err := os.MkdirAll(localBinDir, 0755); err != nil {
-		return fmt.Errorf("failed to create ~/.local/bin: %v", err)
+// printOutdated shows each update's versions and why it's needed
+func printOutdated(result *pack.UpdateResult) {
+	for _, pkgErr := range result.Failed {
+		fmt.Printf("error: could not check %s: %v\n", pkgErr.Package, pkgErr.Err)
 	}
 
-	// Install box to shelf
-	showProgress(5, 6, "installing box binary...")
-	boxSrc := filepath.Join(tempDir, "box")
-	boxDst := filepath.Join(shelfDir, "box")
-	symlinkDst := filepath.Join(localBinDir, "box")
-	
-	srcFile, err := os.Open(boxSrc)
-	if err != nil {
-		return fmt.Errorf("failed to open source box binary: %v", err)
+	updates := append(append([]pack.PackageUpdate{}, result.Available...), result.Held...)
+	if len(updates) == 0 {
+		if len(result.Failed) == 0 {
+			fmt.Println("all packages are up to date")
+		}
+		return
 	}
-	defer srcFile.Close()
 
-	dstFile, err := os.Create(boxDst)
-	if err != nil {
-		return fmt.Errorf("failed to create destination box binary: %v", err)
+	fmt.Printf("%-15s %-12s %-12s %s\n", "package", "current", "new", "reason")
+	fmt.Printf("%-15s %-12s %-12s %s\n", "-------", "-------", "---", "------")
+	for _, update := range updates {
+		reason := update.UpdateType
+		if update.Pin != "" {
+			reason += " (pinned to " + update.Pin + ")"
+		}
+		if update.Held {
+			reason += " (held)"
+		}
+		fmt.Printf("%-15s %-12s %-12s %s\n", update.PackageName,
+			shortVersion(update.CurrentVersion), shortVersion(update.NewVersion), reason)
 	}
-	defer dstFile.Close()
+}
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return fmt.Errorf("failed to copy box binary: %v", err)
+// shortVersion trims commit hashes to fit a table column
+func shortVersion(version string) string {
+	if len(version) > 12 {
+		return version[:12]
 	}
+	return version
+}
 
-	// Make box executable
-	if err := os.Chmod(boxDst, 0755); err != nil {
-		return fmt.Errorf("failed to make box executable: %v", err)
+// writeUpdateRecords writes what UpdateAll found and did as records, with
+// any failure on stderr
+func writeUpdateRecords(result *pack.UpdateResult, err error) {
+	if result != nil {
+		writeOutput(pack.WriteUpdates(os.Stdout, outputFormat, pack.UpdateRecords(result)))
+		for _, pkgErr := range result.Failed {
+			reportError(pkgErr, "")
+		}
 	}
-
-	// Create symlink
-	if err := createSymlink(boxDst, symlinkDst); err != nil {
-		return fmt.Errorf("failed to create symlink: %v", err)
+	if err != nil && !errors.Is(err, pack.ErrCancelled) {
+		reportError(err, "")
+		os.Exit(1)
 	}
-
-	// Create lock file for proper package management
-	showProgress(6, 6, "creating package lock file...")
-	if err := createBootstrapLockFile(tempDir, shelfDir); err != nil {
-		return fmt.Errorf("failed to create lock file: %v", err)
+	if result != nil && len(result.Failed) > 0 {
+		os.Exit(1)
 	}
-
-	fmt.Printf("✓ box interpreter installed to %s\n", boxDst)
-	fmt.Printf("✓ symlink created at %s\n", symlinkDst)
-	fmt.Printf("add %s to your PATH if it's not already included\n", localBinDir)
-	
-	return nil
 }
 
-// createSymlink creates a symlink, removing existing one if needed
-func createSymlink(target, link string) error {
-	// Remove existing symlink if it exists
-	if _, err := os.Lstat(link); err == nil {
-		if err := os.Remove(link); err != nil {
-			return err
+// runDoctor checks the pack directory for drift, repairing it with --fix
+func runDoctor(args []string) {
+	fix := false
+	for _, arg := range args {
+		switch arg {
+		case "help":
+			showDoctorHelp()
+			return
+		case "--fix":
+			fix = true
+		default:
+			fmt.Printf("error: unknown option '%s'\n", arg)
+			os.Exit(1)
 		}
 	}
-	
-	return os.Symlink(target, link)
-}
 
-// createBootstrapLockFile creates a proper lock file for the bootstrapped box installation
-func createBootstrapLockFile(tempDir, shelfDir string) error {
-	// Get current commit hash from the cloned repository
-	getCommitCmd := exec.Command("git", "rev-parse", "HEAD")
-	getCommitCmd.Dir = tempDir
-	commitOutput, err := getCommitCmd.Output()
+	report, err := manager.Doctor(fix)
 	if err != nil {
-		return fmt.Errorf("failed to get commit hash: %v", err)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
-	commitHash := strings.TrimSpace(string(commitOutput))
 
-	// Get locks directory path
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	locksDir := filepath.Join(homeDir, ".pack", "locks")
-	if err := os.MkdirAll(locksDir, 0755); err != nil {
-		return err
-	}
-
-	// Create lock file content in proper box format
-	lockPath := filepath.Join(locksDir, "boxlang.lock")
-	lockContent := fmt.Sprintf(`[data -c lock]
-  package boxlang
-  repo https://github.com/shrub4thedub/pack-repo
-  src_url https://github.com/shrub4thedub/boxlang.git
-  src_type git
-  src_ref HEAD
-  src_ref_used %s
-  recipe_sha256 bootstrap
-  recipe_url https://github.com/shrub4thedub/pack-repo/raw/main/boxlang.box
-  installed_at %s
-  shelf_path %s
-  symlink_path %s
-  config_dir %s/.config/boxlang
-  trust_state bootstrap
-end
-`, commitHash[:8], time.Now().Format("2006-01-02T15:04:05Z"), shelfDir, 
-   filepath.Join(homeDir, ".local", "bin", "box"), homeDir)
-
-	return os.WriteFile(lockPath, []byte(lockContent), 0644)
-}
+	if len(report.Findings) == 0 {
+		fmt.Println("✓ no problems found")
+		return
+	}
 
-func getConfiguredSources() ([]Source, error) {
-	config, err := loadConfig()
-	if err != nil {
-		return nil, err
-	}
-	
-	var sources []Source
-	for i, url := range config.Sources {
-		sources = append(sources, Source{
-			URL:  url,
-			Name: fmt.Sprintf("source-%d", i+1),
-		})
-	}
-	
-	return sources, nil
-}
+	remaining := 0
+	category := ""
+	for _, finding := range report.Findings {
+		if finding.Category != category {
+			category = finding.Category
+			fmt.Printf("\n[%s]\n", category)
+		}
 
-func loadConfig() (*Config, error) {
-	if err := ensureConfigExists(); err != nil {
-		return nil, err
-	}
-	
-	configPath, err := getConfigPath()
-	if err != nil {
-		return nil, err
-	}
-	
-	configFile := filepath.Join(configPath, "sources.box")
-	content, err := os.ReadFile(configFile)
-	if err != nil {
-		return nil, err
-	}
-	
-	config := &Config{}
-	lines := strings.Split(string(content), "\n")
-	var inDataBlock bool
-	var blockIndent int
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		if strings.HasPrefix(trimmed, "[data") && strings.Contains(trimmed, "sources") {
-			inDataBlock = true
-			blockIndent = len(line) - len(strings.TrimLeft(line, " \t"))
+		switch {
+		case finding.Fixed:
+			fmt.Printf("  ✓ fixed: %s\n", finding.Problem)
 			continue
+		case finding.FixErr != nil:
+			fmt.Printf("  ✗ %s (fix failed: %v)\n", finding.Problem, finding.FixErr)
+		case finding.Fixable():
+			fmt.Printf("  ✗ %s (--fix will repair this)\n", finding.Problem)
+		default:
+			fmt.Printf("  ✗ %s\n", finding.Problem)
 		}
-		
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		if inDataBlock && strings.HasPrefix(trimmed, "[") && !strings.Contains(trimmed, "sources") {
-			break
-		}
-		
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
-			if lineIndent > blockIndent {
-				parts := strings.SplitN(trimmed, " ", 2)
-				if len(parts) >= 2 {
-					config.Sources = append(config.Sources, parts[1])
-				}
-			}
+		if finding.Hint != "" {
+			fmt.Printf("    %s\n", finding.Hint)
 		}
+		remaining++
 	}
-	
-	return config, nil
-}
-
-func saveConfig(config *Config) error {
-	configPath, err := getConfigPath()
-	if err != nil {
-		return err
-	}
-	
-	configFile := filepath.Join(configPath, "sources.box")
-	
-	var content strings.Builder
-	content.WriteString("[data -c sources]\n")
-	
-	for _, source := range config.Sources {
-		content.WriteString("  repo " + source + "\n")
-	}
-	
-	content.WriteString("end\n")
-	
-	return os.WriteFile(configFile, []byte(content.String()), 0644)
-}
 
-func addSourceToConfig(sourceURL string) error {
-	return addSourceWithKeyToConfig(sourceURL, "")
+	fmt.Println()
+	if remaining == 0 {
+		fmt.Println("all problems fixed")
+		return
+	}
+	fmt.Printf("%d problem(s) remaining\n", remaining)
+	os.Exit(1)
 }
 
-func addSourceWithKeyToConfig(sourceURL, pubkey string) error {
-	configPath, err := getConfigPath()
-	if err != nil {
-		return err
-	}
-	
-	configFile := filepath.Join(configPath, "sources.box")
-	
-	// Read existing config
-	var existingContent string
-	if content, err := os.ReadFile(configFile); err == nil {
-		existingContent = string(content)
-	}
-	
-	// Check if source already exists
-	if strings.Contains(existingContent, sourceURL) {
-		return fmt.Errorf("source already exists")
-	}
-	
-	// If no existing config, create new one
-	if existingContent == "" {
-		var newConfig string
-		if pubkey != "" {
-			newConfig = fmt.Sprintf(`[data -c sources]
-  repo %s
-  pubkey %s
-end`, sourceURL, pubkey)
-		} else {
-			newConfig = fmt.Sprintf(`[data -c sources]
-  repo %s
-end`, sourceURL)
-		}
-		return os.WriteFile(configFile, []byte(newConfig), 0644)
-	}
-	
-	// Append to existing config
-	lines := strings.Split(existingContent, "\n")
-	var newLines []string
-	
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "end" {
-			// Insert new source before the end
-			newLines = append(newLines, fmt.Sprintf("  repo %s", sourceURL))
-			if pubkey != "" {
-				newLines = append(newLines, fmt.Sprintf("  pubkey %s", pubkey))
-			}
-		}
-		newLines = append(newLines, line)
+// verifyPackages checks installed files against their manifests and exits
+// non-zero on any mismatch
+func verifyPackages(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		showVerifyHelp()
+		return
 	}
-	
-	return os.WriteFile(configFile, []byte(strings.Join(newLines, "\n")), 0644)
-}
 
-// PackageSource represents a source where a package is available
-type PackageSource struct {
-	Name string
-	URL  string
-	Type string // "remote" or "local"
-}
+	var checks []*pack.FileCheck
+	failed := false
 
-// findAvailableSources finds all sources where a package is available
-func findAvailableSources(packageName string) ([]PackageSource, error) {
-	var availableSources []PackageSource
-	
-	// Check local source if it exists
-	localRepoPath, err := getLocalRepoPath()
-	if err == nil {
-		// First try the old flat structure for backward compatibility
-		localPackagePath := filepath.Join(localRepoPath, packageName+".box")
-		if _, err := os.Stat(localPackagePath); err == nil {
-			availableSources = append(availableSources, PackageSource{
-				Name: "local",
-				URL:  localPackagePath,
-				Type: "local",
-			})
-		} else {
-			// If not found in flat structure, search through the new section-based structure
-			sections := []string{"lang", "utils", "toys", "misc"}
-			for _, section := range sections {
-				localPackagePath := filepath.Join(localRepoPath, section, packageName, packageName+".box")
-				if _, err := os.Stat(localPackagePath); err == nil {
-					availableSources = append(availableSources, PackageSource{
-						Name: "local",
-						URL:  localPackagePath,
-						Type: "local",
-					})
-					break // Found in this section, no need to check others
-				}
-			}
+	if len(args) == 0 {
+		var skipped []*pack.PackageError
+		var err error
+		checks, skipped, err = manager.VerifyAllFiles()
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
 		}
-	}
-	
-	// Check configured remote sources
-	config, err := loadConfig()
-	if err == nil && len(config.Sources) > 0 {
-		for _, source := range config.Sources {
-			// First try the old flat structure for backward compatibility
-			var scriptURL string
-			if strings.Contains(source, "raw.githubusercontent.com") {
-				scriptURL = fmt.Sprintf("%s/%s.box", source, packageName)
-			} else {
-				scriptURL = fmt.Sprintf("%s/raw/main/%s.box", source, packageName)
-			}
-			
-			if testPackageExists(scriptURL) {
-				availableSources = append(availableSources, PackageSource{
-					Name: source,
-					URL:  scriptURL,
-					Type: "remote",
-				})
-			} else {
-				// If not found in flat structure, search through sections in remote repos
-				sections := []string{"lang", "utils", "toys", "misc"}
-				
-				for _, section := range sections {
-					// Try raw github content URL format
-					if strings.Contains(source, "raw.githubusercontent.com") {
-						scriptURL = fmt.Sprintf("%s/%s/%s/%s.box", source, section, packageName, packageName)
-					} else {
-						scriptURL = fmt.Sprintf("%s/raw/main/%s/%s/%s.box", source, section, packageName, packageName)
-					}
-					
-					// Test if package exists at this source (lightweight check)
-					if testPackageExists(scriptURL) {
-						availableSources = append(availableSources, PackageSource{
-							Name: source,
-							URL:  scriptURL,
-							Type: "remote",
-						})
-						break // Found in this section, no need to check others
-					}
-				}
+		for _, skip := range skipped {
+			fmt.Printf("- %v\n", skip)
+		}
+	} else {
+		for _, packageName := range args {
+			check, err := manager.VerifyFiles(packageName)
+			if err != nil {
+				fmt.Printf("✗ %s: %v\n", packageName, err)
+				failed = true
+				continue
 			}
+			checks = append(checks, check)
 		}
 	}
-	
-	return availableSources, nil
-}
-
-// testPackageExists does a lightweight test to see if a package exists at a URL
-func testPackageExists(url string) bool {
-	resp, err := httpClient.Head(url)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == 200
-}
 
-// promptSourceSelection lets user choose from multiple sources
-func promptSourceSelection(packageName string, sources []PackageSource) (PackageSource, error) {
-	fmt.Printf("\nPackage '%s' is available from multiple sources:\n\n", packageName)
-	
-	for i, source := range sources {
-		if source.Type == "local" {
-			fmt.Printf("%d) Local repository\n", i+1)
-		} else {
-			fmt.Printf("%d) %s\n", i+1, source.Name)
+	for _, check := range checks {
+		if check.OK() {
+			fmt.Printf("✓ %s: %d entries ok\n", check.Package, check.Checked)
+			continue
 		}
-	}
-	
-	fmt.Print("\nSelect source [1]: ")
-	
-	var input string
-	fmt.Scanln(&input)
-	
-	if input == "" {
-		input = "1"
-	}
-	
-	choice, err := strconv.Atoi(input)
-	if err != nil || choice < 1 || choice > len(sources) {
-		return PackageSource{}, fmt.Errorf("invalid selection")
-	}
-	
-	return sources[choice-1], nil
-}
 
-func downloadFromSources(packageName string, scriptPath string) (PackageSource, error) {
-	// Find all available sources
-	sources, err := findAvailableSources(packageName)
-	if err != nil {
-		return PackageSource{}, err
-	}
-	
-	if len(sources) == 0 {
-		return PackageSource{}, fmt.Errorf("package '%s' not found in any configured source", packageName)
-	}
-	
-	var selectedSource PackageSource
-	
-	if len(sources) == 1 {
-		// Only one source available, use it
-		selectedSource = sources[0]
-		if selectedSource.Type == "local" {
-			fmt.Printf("Found package in local repository\n")
-		} else {
-			fmt.Printf("Found package at %s\n", selectedSource.Name)
+		failed = true
+		fmt.Printf("✗ %s:\n", check.Package)
+		for _, path := range check.Modified {
+			fmt.Printf("  modified %s\n", path)
 		}
-	} else {
-		// Multiple sources available, let user choose
-		selectedSource, err = promptSourceSelection(packageName, sources)
-		if err != nil {
-			return PackageSource{}, err
+		for _, path := range check.Missing {
+			fmt.Printf("  missing  %s\n", path)
+		}
+		for _, path := range check.Extra {
+			fmt.Printf("  extra    %s\n", path)
 		}
 	}
-	
-	// Source selected, continue silently
-	
-	// Download from selected source
-	if selectedSource.Type == "local" {
-		err = copyFile(selectedSource.URL, scriptPath)
-	} else {
-		err = downloadFile(selectedSource.URL, scriptPath)
+
+	if failed {
+		os.Exit(1)
 	}
-	
-	return selectedSource, err
 }
 
-// calculateSHA256 calculates the SHA256 hash of file contents
-func calculateSHA256(content []byte) string {
-	hash := sha256.Sum256(content)
-	return hex.EncodeToString(hash[:])
-}
+// listPackageFiles prints the file manifest of an installed package
+func listPackageFiles(args []string) {
+	if args[0] == "help" {
+		showFilesHelp()
+		return
+	}
 
-// extractSHA256FromRecipe parses the sha256 field from a recipe's data block
-func extractSHA256FromRecipe(scriptPath string) (string, error) {
-	content, err := os.ReadFile(scriptPath)
+	manifest, err := manager.Files(args[0])
 	if err != nil {
-		return "", fmt.Errorf("failed to read script: %v", err)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
 
-	lines := strings.Split(string(content), "\n")
-	var inDataBlock bool
-	var blockIndent int
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		// Check for data block start
-		if strings.HasPrefix(trimmed, "[data") {
-			inDataBlock = true
-			blockIndent = len(line) - len(strings.TrimLeft(line, " \t"))
-			continue
-		}
-		
-		// Check for block end
-		if inDataBlock && trimmed == "end" {
-			break
+	for _, entry := range manifest.Entries {
+		modified := ""
+		if entry.Modified {
+			modified = " (modified)"
 		}
-		
-		// Check for any other block start
-		if inDataBlock && strings.HasPrefix(trimmed, "[") {
-			break
-		}
-		
-		// Parse data block content for sha256 field
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
-			if lineIndent > blockIndent {
-				parts := strings.SplitN(trimmed, " ", 2)
-				if len(parts) >= 2 && parts[0] == "sha256" {
-					return strings.TrimSpace(parts[1]), nil
-				}
-			}
+		switch entry.Kind {
+		case pack.EntryLink:
+			fmt.Printf("%-4s %s -> %s%s\n", entry.Kind, entry.Path, entry.Target, modified)
+		default:
+			fmt.Printf("%-4s %s%s\n", entry.Kind, entry.Path, modified)
 		}
 	}
-	
-	return "", fmt.Errorf("sha256 field not found in recipe data block")
 }
 
-// verifyRecipeIntegrity verifies Ed25519 signature - no fallback to unsafe SHA256
-func verifyRecipeIntegrity(scriptPath string, sourceRepo string) error {
-	// Skip verification for local sources
-	if sourceRepo == "local" {
-		return nil
-	}
-	
-	// Only use Ed25519 signature verification for remote sources
-	if err := verifyEd25519Signature(scriptPath, sourceRepo); err != nil {
-		return fmt.Errorf("Ed25519 signature verification failed: %v", err)
+// showOwner prints which installed package put a path on disk
+func showOwner(args []string) {
+	if args[0] == "help" {
+		showFilesHelp()
+		return
 	}
-	
-	return nil
-}
 
-// verifyEd25519Signature verifies a detached Ed25519 signature with fallback chain
-func verifyEd25519Signature(scriptPath string, sourceRepo string) error {
-	// Download signature file first
-	sigPath := scriptPath + ".sig"
-	
-	// Try to download signature file (support both old and new formats)
-	packageName := strings.TrimSuffix(filepath.Base(scriptPath), ".box")
-	
-	// First try old flat structure
-	sigURL := fmt.Sprintf("%s/raw/main/%s.box.sig", sourceRepo, packageName)
-	
-	if err := downloadFile(sigURL, sigPath); err != nil {
-		// If old structure fails, try new section-based structure
-		sections := []string{"lang", "utils", "toys", "misc"}
-		found := false
-		
-		for _, section := range sections {
-			sigURL = fmt.Sprintf("%s/raw/main/%s/%s/%s.box.sig", sourceRepo, section, packageName, packageName)
-			if err := downloadFile(sigURL, sigPath); err == nil {
-				found = true
-				break
-			}
-		}
-		
-		if !found {
-			return fmt.Errorf("failed to download signature: signature file not found in any location")
-		}
-	}
-	defer os.Remove(sigPath)
-	
-	// Read signature
-	sigBytes, err := os.ReadFile(sigPath)
-	if err != nil {
-		return fmt.Errorf("failed to read signature: %v", err)
-	}
-	
-	// Decode base64 signature
-	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigBytes)))
+	path := args[0]
+	owners, err := manager.Owns(path)
 	if err != nil {
-		return fmt.Errorf("invalid base64 signature: %v", err)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	// Read recipe content
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return fmt.Errorf("failed to read recipe: %v", err)
+
+	if len(owners) == 0 {
+		fmt.Printf("no installed package owns %s\n", path)
+		os.Exit(1)
 	}
-	
-	// Implement fallback chain verification
-	return verifyWithKeyChain(content, signature, sourceRepo)
+	fmt.Printf("%s is owned by %s\n", path, strings.Join(owners, ", "))
 }
 
-// verifyWithKeyChain tries multiple keys in order: current, refreshed, previous versions
-func verifyWithKeyChain(content, signature []byte, sourceRepo string) error {
-	// Step 1: Try current cached key
-	if pubkey, _, err := getCachedPublicKeyWithVersion(sourceRepo); err == nil {
-		if verifySignatureWithKey(content, signature, pubkey) == nil {
-			return nil
-		}
-	}
-	
-	// Step 2: Try to fetch latest key metadata and verify
-	if metadata, err := fetchKeyMetadata(sourceRepo); err == nil {
-		if verifySignatureWithKey(content, signature, metadata.Key) == nil {
-			// Cache the new key
-			cachePublicKeyWithVersion(sourceRepo, metadata)
-			return nil
-		}
-		
-		// Step 3: If new key fails, try with previous versions (if available)
-		if previousKeys, err := fetchPreviousKeyVersions(sourceRepo, metadata.Version); err == nil {
-			for _, prevKey := range previousKeys {
-				if verifySignatureWithKey(content, signature, prevKey) == nil {
-					return nil // Accept previous key during transition
-				}
-			}
-		}
-	}
-	
-	// Step 4: Try legacy .pub format as last resort
-	if legacyKey, err := fetchLegacyPublicKey(sourceRepo); err == nil {
-		if verifySignatureWithKey(content, signature, legacyKey) == nil {
-			return nil
-		}
-	}
-	
-	// Step 5: Clear cache and try once more with fresh fetch
-	clearKeyCache(sourceRepo)
-	if metadata, err := fetchKeyMetadata(sourceRepo); err == nil {
-		if verifySignatureWithKey(content, signature, metadata.Key) == nil {
-			cachePublicKeyWithVersion(sourceRepo, metadata)
-			return nil
-		}
+// showBuildLog prints the newest build log for a package, or lists them all
+func showBuildLog(args []string) {
+	if args[0] == "help" {
+		showLogHelp()
+		return
 	}
-	
-	// Step 6: Attempt automated recovery from verification failure
-	if err := recoverFromVerificationFailure(sourceRepo); err == nil {
-		// Retry verification after recovery
-		if legacyKey, err := fetchLegacyPublicKey(sourceRepo); err == nil {
-			if verifySignatureWithKey(content, signature, legacyKey) == nil {
-				return nil
-			}
+
+	packageName := args[0]
+	list := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--last":
+			list = false
+		case "--list":
+			list = true
+		default:
+			fmt.Printf("error: unknown option '%s'\n", arg)
+			os.Exit(1)
 		}
 	}
-	
-	return fmt.Errorf("signature verification failed with all available keys")
-}
 
-// verifySignatureWithKey verifies a signature against a specific public key
-func verifySignatureWithKey(content, signature []byte, pubkeyB64 string) error {
-	pubkeyBytes, err := base64.StdEncoding.DecodeString(pubkeyB64)
+	logs, err := manager.BuildLogs(packageName)
 	if err != nil {
-		return fmt.Errorf("invalid base64 public key: %v", err)
-	}
-	
-	if len(pubkeyBytes) != ed25519.PublicKeySize {
-		return fmt.Errorf("invalid public key size: expected %d, got %d", ed25519.PublicKeySize, len(pubkeyBytes))
-	}
-	
-	if !ed25519.Verify(pubkeyBytes, content, signature) {
-		return fmt.Errorf("signature verification failed")
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	return nil
-}
 
-// fetchPreviousKeyVersions attempts to fetch previous key versions for transition support
-func fetchPreviousKeyVersions(sourceRepo string, currentVersion int) ([]string, error) {
-	var previousKeys []string
-	
-	// Try to fetch up to 3 previous versions
-	for i := 1; i <= 3 && currentVersion-i >= 0; i++ {
-		prevVersion := currentVersion - i
-		keyURL := fmt.Sprintf("%s/raw/main/keys/pack_v%d.box", sourceRepo, prevVersion)
-		
-		resp, err := httpClient.Get(keyURL)
-		if err != nil {
-			continue // Skip missing versions
-		}
-		defer resp.Body.Close()
-		
-		if resp.StatusCode != 200 {
-			continue // Version doesn't exist
+	if !list {
+		if err := pack.WriteBuildLog(os.Stdout, logs[len(logs)-1]); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
 		}
-		
-		content, err := io.ReadAll(resp.Body)
-		if err != nil {
-			continue
+		return
+	}
+
+	fmt.Printf("%-20s %-10s %-10s %-10s %s\n", "started", "action", "status", "took", "log")
+	for _, log := range logs {
+		status := "ok"
+		switch {
+		case !log.Finished:
+			status = "unfinished"
+		case log.Exit < 0:
+			status = "not run"
+		case log.Exit > 0:
+			status = fmt.Sprintf("exit %d", log.Exit)
 		}
-		
-		if metadata, err := parseKeyMetadata(string(content)); err == nil {
-			previousKeys = append(previousKeys, metadata.Key)
+		took := "-"
+		if log.Finished {
+			took = log.Duration.Round(time.Millisecond).String()
 		}
+		fmt.Printf("%-20s %-10s %-10s %-10s %s\n", log.Started.Local().Format("2006-01-02 15:04:05"), log.Action, status, took, log.Path)
 	}
-	
-	if len(previousKeys) == 0 {
-		return nil, fmt.Errorf("no previous key versions found")
-	}
-	
-	return previousKeys, nil
 }
 
-// clearKeyCache removes cached keys for a source to force refresh
-func clearKeyCache(sourceRepo string) {
-	packPath, err := getPackDir()
-	if err != nil {
+// rollbackPackage switches a package back to an earlier shelf generation
+func rollbackPackage(args []string) {
+	if args[0] == "help" {
+		showRollbackHelp()
 		return
 	}
-	
-	cacheDir := filepath.Join(packPath, "cache", "keys")
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceRepo)))
-	
-	// Remove both .box and .pub cache files
-	os.Remove(filepath.Join(cacheDir, hash+".box"))
-	os.Remove(filepath.Join(cacheDir, hash+".pub"))
-}
 
-// recoverFromVerificationFailure attempts to recover from Ed25519 verification failures
-func recoverFromVerificationFailure(sourceRepo string) error {
-	// 1. Clear cached keys for this source
-	clearKeyCache(sourceRepo)
-	
-	// 2. Fetch fresh public key from repository
-	pubKey, err := fetchLegacyPublicKey(sourceRepo)
-	if err != nil {
-		return fmt.Errorf("failed to fetch fresh public key: %v", err)
-	}
-	
-	// 3. Update sources.box configuration
-	if err := updateSourcePublicKey(sourceRepo, pubKey); err != nil {
-		return fmt.Errorf("failed to update source configuration: %v", err)
+	packageName := args[0]
+	generation := 0
+	if len(args) > 1 {
+		var err error
+		generation, err = strconv.Atoi(args[1])
+		if err != nil || generation < 1 {
+			fmt.Printf("error: invalid generation '%s'\n", args[1])
+			os.Exit(1)
+		}
 	}
-	
-	return nil
-}
 
-// updateSourcePublicKey updates the public key for a source in sources.box
-func updateSourcePublicKey(sourceRepo string, newPubKey string) error {
-	configPath, err := getConfigPath()
+	lock, err := manager.Rollback(packageName, generation)
 	if err != nil {
-		return err
+		fmt.Printf("error rolling back %s: %v\n", packageName, err)
+		os.Exit(1)
 	}
-	
-	configFile := filepath.Join(configPath, "sources.box")
-	content, err := os.ReadFile(configFile)
-	if err != nil {
-		return err
-	}
-	
-	lines := strings.Split(string(content), "\n")
-	var updatedLines []string
-	var inSourcesBlock bool
-	var foundRepo bool
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		// Check if we're entering the sources data block
-		if strings.Contains(trimmed, "[data") && strings.Contains(trimmed, "sources") {
-			inSourcesBlock = true
-		}
-		
-		// Check if we're leaving the sources block
-		if inSourcesBlock && trimmed == "end" {
-			inSourcesBlock = false
-		}
-		
-		// Update pubkey if we're in sources block and found matching repo
-		if inSourcesBlock && strings.HasPrefix(trimmed, "repo ") && strings.Contains(line, sourceRepo) {
-			foundRepo = true
-			updatedLines = append(updatedLines, line)
-			continue
-		}
-		
-		// Replace pubkey line if we found the matching repo
-		if inSourcesBlock && foundRepo && strings.HasPrefix(trimmed, "pubkey ") {
-			// Replace with new public key, preserving indentation
-			indent := ""
-			for _, char := range line {
-				if char == ' ' || char == '\t' {
-					indent += string(char)
-				} else {
-					break
-				}
-			}
-			updatedLines = append(updatedLines, indent+"pubkey "+newPubKey)
-			foundRepo = false
-			continue
-		}
-		
-		updatedLines = append(updatedLines, line)
+
+	version := lock.SrcRefUsed
+	if version == "" {
+		version = "unknown"
 	}
-	
-	// Write updated configuration back
-	updatedContent := strings.Join(updatedLines, "\n")
-	return os.WriteFile(configFile, []byte(updatedContent), 0644)
+	fmt.Printf("✓ %s rolled back to generation %d (%s)\n", packageName, lock.Generation, version)
 }
 
-// verifySHA256Hash performs legacy SHA256 self-verification
-func verifySHA256Hash(scriptPath string) error {
-	// Read the file content
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return fmt.Errorf("failed to read script: %v", err)
+// exportPackages writes a packfile of the installed packages to a file or stdout
+func exportPackages(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		showPackfileHelp()
+		return
 	}
-	
-	// Extract expected hash from recipe
-	expectedHash, err := extractSHA256FromRecipe(scriptPath)
+
+	pf, err := manager.Export()
 	if err != nil {
-		return fmt.Errorf("failed to extract expected hash: %v", err)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	// Calculate actual hash excluding the SHA256 field
-	contentWithoutSHA256, err := removeCSHA256Field(content)
-	if err != nil {
-		return fmt.Errorf("failed to remove SHA256 field: %v", err)
+
+	if len(args) == 0 || args[0] == "-" {
+		os.Stdout.Write(pf.Marshal())
+		return
 	}
-	actualHash := calculateSHA256(contentWithoutSHA256)
-	
-	// Compare hashes
-	if actualHash != expectedHash {
-		return fmt.Errorf("SHA256 verification failed: expected %s, got %s", expectedHash, actualHash)
+
+	if err := os.WriteFile(args[0], pf.Marshal(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	return nil
+	fmt.Fprintf(os.Stderr, "✓ wrote %d package(s) to %s\n", len(pf.Packages), args[0])
 }
 
-// getPublicKeyForSource gets the public key for a given source repository
-func getPublicKeyForSource(sourceRepo string) (string, error) {
-	// First try to get key from repository's keys directory
-	if pubkey, err := fetchPublicKeyFromRepo(sourceRepo); err == nil {
-		return pubkey, nil
-	}
-	
-	// Fallback to local config for backward compatibility
-	configPath, err := getConfigPath()
-	if err != nil {
-		return "", err
-	}
-	
-	configFile := filepath.Join(configPath, "sources.box")
-	content, err := os.ReadFile(configFile)
-	if err != nil {
-		return "", err
-	}
-	
-	lines := strings.Split(string(content), "\n")
-	var inDataBlock bool
-	var currentRepo string
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		if strings.HasPrefix(trimmed, "[data") && strings.Contains(trimmed, "sources") {
-			inDataBlock = true
-			continue
-		}
-		
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			parts := strings.SplitN(trimmed, " ", 2)
-			if len(parts) >= 2 {
-				switch parts[0] {
-				case "repo":
-					currentRepo = parts[1]
-				case "pubkey":
-					if currentRepo == sourceRepo {
-						return strings.Trim(parts[1], "\""), nil
-					}
-				}
-			}
-		}
+// applyPackfile opens what a packfile lists, or with sync makes the shelf match it
+func applyPackfile(command string, args []string) {
+	if args[0] == "help" {
+		showPackfileHelp()
+		return
 	}
-	
-	return "", fmt.Errorf("public key not found for source %s", sourceRepo)
-}
 
-// fetchPublicKeyFromRepo fetches the public key from the repository's keys directory
-func fetchPublicKeyFromRepo(sourceRepo string) (string, error) {
-	// Skip fetching for local sources
-	if sourceRepo == "local" {
-		return "", fmt.Errorf("local sources don't have remote keys")
-	}
-	
-	// Try cached key first (with version checking)
-	if cachedKey, version, err := getCachedPublicKeyWithVersion(sourceRepo); err == nil {
-		// Check if we need to refresh (for automatic cache invalidation)
-		if shouldRefreshKey(sourceRepo, version) {
-			// Try to fetch new version in background, but use cached for now
-			go backgroundKeyRefresh(sourceRepo)
+	var path string
+	var opts pack.SyncOptions
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--prune" && command == "sync":
+			opts.Prune = true
+		case arg == "--jobs" || arg == "-j":
+			if i+1 >= len(args) {
+				fmt.Printf("error: %s requires a number\n", arg)
+				os.Exit(1)
+			}
+			i++
+			jobs, err := strconv.Atoi(args[i])
+			if err != nil || jobs < 1 {
+				fmt.Printf("error: invalid number of jobs '%s'\n", args[i])
+				os.Exit(1)
+			}
+			opts.Jobs = jobs
+		case strings.HasPrefix(arg, "-") || path != "":
+			fmt.Printf("error: unknown option '%s'\n", arg)
+			os.Exit(1)
+		default:
+			path = arg
 		}
-		return cachedKey, nil
 	}
-	
-	// Try new .box format first
-	if keyData, err := fetchKeyMetadata(sourceRepo); err == nil {
-		return keyData.Key, nil
+	if path == "" {
+		fmt.Println("error: packfile required")
+		os.Exit(1)
 	}
-	
-	// Fallback to legacy .pub format
-	return fetchLegacyPublicKey(sourceRepo)
-}
 
-// KeyMetadata represents the metadata from a pack.box key file
-type KeyMetadata struct {
-	Version   int
-	IssuedAt  int64
-	ExpiresAt int64
-	Algorithm string
-	Key       string
-}
-
-// fetchKeyMetadata fetches key metadata from the new .box format
-func fetchKeyMetadata(sourceRepo string) (*KeyMetadata, error) {
-	keyURL := fmt.Sprintf("%s/raw/main/keys/pack.box", sourceRepo)
-	
-	resp, err := httpClient.Get(keyURL)
+	pf, err := pack.ReadPackfile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch key metadata: %v", err)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("key metadata not found at %s (status: %d)", keyURL, resp.StatusCode)
+
+	var result *pack.SyncResult
+	if command == "sync" {
+		result, err = manager.Sync(pf, opts)
+	} else {
+		result, err = manager.Import(pf, opts)
 	}
-	
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read key metadata: %v", err)
+	if errors.Is(err, pack.ErrCancelled) {
+		fmt.Printf("%s cancelled\n", command)
+		return
 	}
-	
-	// Parse the .box file using Box parser
-	metadata, err := parseKeyMetadata(string(content))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse key metadata: %v", err)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	if len(result.Installed) > 0 {
+		fmt.Printf("✓ opened: %s\n", strings.Join(result.Installed, ", "))
 	}
-	
-	// Validate key format
-	if len(metadata.Key) != 44 || !strings.HasSuffix(metadata.Key, "=") {
-		return nil, fmt.Errorf("invalid public key format in metadata")
+	if len(result.Changed) > 0 {
+		fmt.Printf("✓ changed: %s\n", strings.Join(result.Changed, ", "))
 	}
-	
-	// Cache the key with version info
-	if err := cachePublicKeyWithVersion(sourceRepo, metadata); err != nil {
-		// Don't fail on cache errors, just log them
-		fmt.Printf("Warning: failed to cache public key: %v\n", err)
+	if len(result.Removed) > 0 {
+		fmt.Printf("✓ closed: %s\n", strings.Join(result.Removed, ", "))
 	}
-	
-	return metadata, nil
-}
-
-// fetchLegacyPublicKey fetches from the old .pub format (fallback)
-func fetchLegacyPublicKey(sourceRepo string) (string, error) {
-	keyURL := fmt.Sprintf("%s/raw/main/keys/pack.pub", sourceRepo)
-	
-	resp, err := httpClient.Get(keyURL)
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch legacy public key: %v", err)
+	if len(result.UpToDate) > 0 {
+		fmt.Printf("already in place: %s\n", strings.Join(result.UpToDate, ", "))
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("public key not found at %s (status: %d)", keyURL, resp.StatusCode)
+	if len(result.Failed) > 0 {
+		for _, pkgErr := range result.Failed {
+			fmt.Printf("✗ %v\n", pkgErr)
+		}
+		os.Exit(1)
 	}
-	
-	keyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read legacy public key: %v", err)
-	}
-	
-	pubkey := strings.TrimSpace(string(keyBytes))
-	
-	// Validate key format
-	if len(pubkey) != 44 || !strings.HasSuffix(pubkey, "=") {
-		return "", fmt.Errorf("invalid legacy public key format")
-	}
-	
-	// Cache as legacy key (version 0)
-	legacyMetadata := &KeyMetadata{
-		Version:   0,
-		IssuedAt:  time.Now().Unix(),
-		ExpiresAt: time.Now().Add(365 * 24 * time.Hour).Unix(),
-		Algorithm: "ed25519",
-		Key:       pubkey,
-	}
-	
-	if err := cachePublicKeyWithVersion(sourceRepo, legacyMetadata); err != nil {
-		fmt.Printf("Warning: failed to cache legacy public key: %v\n", err)
-	}
-	
-	return pubkey, nil
 }
 
-// getCachedPublicKey retrieves a cached public key for a source
-func getCachedPublicKey(sourceRepo string) (string, error) {
-	packPath, err := getPackDir()
-	if err != nil {
-		return "", err
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache", "keys")
-	
-	// Create a safe filename from the source repo URL
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceRepo)))
-	keyFile := filepath.Join(cacheDir, hash+".pub")
-	
-	content, err := os.ReadFile(keyFile)
-	if err != nil {
-		return "", err
+// showHistory lists the journal, the transactions that touched a package,
+// or the details of one transaction
+func showHistory(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		showHistoryHelp()
+		return
 	}
-	
-	return strings.TrimSpace(string(content)), nil
-}
 
-// cachePublicKey stores a public key in the local cache
-func cachePublicKey(sourceRepo, pubkey string) error {
-	packPath, err := getPackDir()
-	if err != nil {
-		return err
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache", "keys")
-	if err := os.MkdirAll(cacheDir, privateDirPerms); err != nil {
-		return err
-	}
-	
-	// Create a safe filename from the source repo URL
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceRepo)))
-	keyFile := filepath.Join(cacheDir, hash+".pub")
-	
-	return os.WriteFile(keyFile, []byte(pubkey+"\n"), privateFilePerms)
-}
+	if len(args) > 0 {
+		if id, err := strconv.Atoi(args[0]); err == nil {
+			txn, err := manager.Transaction(id)
+			if err != nil {
+				fmt.Printf("error: %v\n", err)
+				os.Exit(1)
+			}
+			printTransaction(txn)
+			return
+		}
+	}
 
-// getCachedPublicKeyWithVersion retrieves a cached public key with version info
-func getCachedPublicKeyWithVersion(sourceRepo string) (string, int, error) {
-	packPath, err := getPackDir()
+	history, err := manager.History()
 	if err != nil {
-		return "", 0, err
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache", "keys")
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceRepo)))
-	
-	// Try versioned cache first
-	keyFile := filepath.Join(cacheDir, hash+".box")
-	if content, err := os.ReadFile(keyFile); err == nil {
-		metadata, err := parseKeyMetadata(string(content))
-		if err == nil {
-			return metadata.Key, metadata.Version, nil
-		}
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	// Fallback to legacy cache
-	legacyFile := filepath.Join(cacheDir, hash+".pub")
-	if content, err := os.ReadFile(legacyFile); err == nil {
-		return strings.TrimSpace(string(content)), 0, nil
+
+	var packageName string
+	if len(args) > 0 {
+		packageName = args[0]
 	}
-	
-	return "", 0, fmt.Errorf("no cached key found")
-}
 
-// cachePublicKeyWithVersion stores a public key with version metadata
-func cachePublicKeyWithVersion(sourceRepo string, metadata *KeyMetadata) error {
-	packPath, err := getPackDir()
-	if err != nil {
-		return err
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache", "keys")
-	if err := os.MkdirAll(cacheDir, privateDirPerms); err != nil {
-		return err
-	}
-	
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceRepo)))
-	keyFile := filepath.Join(cacheDir, hash+".box")
-	
-	// Create .box format cache file
-	cacheContent := fmt.Sprintf(`[data -c keyinfo]
-  version     %d
-  issued_at   %d
-  expires_at  %d
-  algorithm   %s
-  cached_at   %d
-end
-
-[data -c pubkey]
-  key %s
-end`, metadata.Version, metadata.IssuedAt, metadata.ExpiresAt, metadata.Algorithm, time.Now().Unix(), metadata.Key)
-	
-	return os.WriteFile(keyFile, []byte(cacheContent), privateFilePerms)
-}
+	shown := 0
+	for _, txn := range history {
+		var changes []string
+		touched := packageName == ""
+		for _, change := range txn.Changes {
+			changes = append(changes, describeChange(change))
+			touched = touched || change.Package == packageName
+		}
+		if !touched {
+			continue
+		}
+		if txn.SourcesChanged {
+			changes = append(changes, "sources.box")
+		}
 
-// shouldRefreshKey determines if a cached key should be refreshed
-func shouldRefreshKey(sourceRepo string, version int) bool {
-	packPath, err := getPackDir()
-	if err != nil {
-		return true // Refresh if we can't check
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache", "keys")
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceRepo)))
-	keyFile := filepath.Join(cacheDir, hash+".box")
-	
-	stat, err := os.Stat(keyFile)
-	if err != nil {
-		return true // Refresh if cache file doesn't exist
+		status := " "
+		if txn.Error != "" {
+			status = "✗"
+		}
+		command := strings.TrimSpace(txn.Action + " " + strings.Join(txn.Args, " "))
+		fmt.Printf("%4d %s %s %-10s %-24s %s\n", txn.ID, status, txn.Time.Local().Format("2006-01-02 15:04"), txn.User, command, strings.Join(changes, ", "))
+		shown++
 	}
-	
-	// Refresh if cache is older than 24 hours
-	return time.Since(stat.ModTime()) > 24*time.Hour
-}
 
-// backgroundKeyRefresh refreshes a key in the background
-func backgroundKeyRefresh(sourceRepo string) {
-	// Try to fetch new metadata
-	if metadata, err := fetchKeyMetadata(sourceRepo); err == nil {
-		// Key was updated, clear old cache and store new
-		cachePublicKeyWithVersion(sourceRepo, metadata)
-	} else {
-		// Fallback to legacy format
-		if key, err := fetchLegacyPublicKey(sourceRepo); err == nil {
-			legacyMetadata := &KeyMetadata{
-				Version:   0,
-				IssuedAt:  time.Now().Unix(),
-				ExpiresAt: time.Now().Add(365 * 24 * time.Hour).Unix(),
-				Algorithm: "ed25519",
-				Key:       key,
-			}
-			cachePublicKeyWithVersion(sourceRepo, legacyMetadata)
+	if shown == 0 {
+		if packageName != "" {
+			fmt.Printf("no history for %s\n", packageName)
+		} else {
+			fmt.Println("no history yet")
 		}
 	}
 }
 
-// parseKeyMetadata parses key metadata from a .box format string using the Box binary
-func parseKeyMetadata(content string) (*KeyMetadata, error) {
-	// Create a temporary file for the Box parser
-	tempFile, err := os.CreateTemp("", "keyparse_*.box")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %v", err)
-	}
-	defer os.Remove(tempFile.Name())
-	
-	if err := os.WriteFile(tempFile.Name(), []byte(content), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write temp file: %v", err)
-	}
-	
-	// Use the Box binary to execute the script and extract data
-	boxPath := findBoxBinary()
-	if boxPath == "" {
-		// Fallback to simple parsing if Box binary not found
-		return parseKeyMetadataSimple(content)
-	}
-	
-	// Create a wrapper script that extracts the data
-	wrapperContent := fmt.Sprintf(`import %s
-
-[main]
-  # Extract keyinfo data
-  echo "VERSION:" $keyinfo.version
-  echo "ISSUED_AT:" $keyinfo.issued_at  
-  echo "EXPIRES_AT:" $keyinfo.expires_at
-  echo "ALGORITHM:" $keyinfo.algorithm
-  echo "KEY:" $pubkey.key
-end`, tempFile.Name())
-	
-	wrapperFile, err := os.CreateTemp("", "wrapper_*.box")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create wrapper file: %v", err)
-	}
-	defer os.Remove(wrapperFile.Name())
-	
-	if err := os.WriteFile(wrapperFile.Name(), []byte(wrapperContent), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write wrapper file: %v", err)
-	}
-	
-	// Execute the wrapper script
-	cmd := exec.Command(boxPath, wrapperFile.Name())
-	output, err := cmd.Output()
-	if err != nil {
-		// Fallback to simple parsing if Box execution fails
-		return parseKeyMetadataSimple(content)
+// describeChange sums up what a transaction did to one package
+func describeChange(change *pack.PackageChange) string {
+	before, after := change.Before, change.After
+	switch {
+	case before == nil && after == nil:
+		return change.Package
+	case before == nil:
+		return fmt.Sprintf("+%s %s", change.Package, generationOrVersion(after))
+	case after == nil:
+		return "-" + change.Package
+	case before.Generation != after.Generation || before.SrcRefUsed != after.SrcRefUsed || before.RecipeSHA256 != after.RecipeSHA256:
+		return fmt.Sprintf("%s %s → %s", change.Package, generationOrVersion(before), generationOrVersion(after))
+	case !before.Held && after.Held:
+		return change.Package + " held"
+	case before.Held && !after.Held:
+		return change.Package + " released"
 	}
-	
-	return parseBoxOutput(string(output))
+	return change.Package
 }
 
-// findBoxBinary finds the Box binary in the system
-func findBoxBinary() string {
-	// Try relative path first (for development)
-	boxPath := "../boxlang/box"
-	if _, err := os.Stat(boxPath); err == nil {
-		return boxPath
+// generationOrVersion names a build by its version, or its shelf
+// generation when the version isn't known
+func generationOrVersion(lock *pack.Lockfile) string {
+	if lock.SrcRefUsed != "" && lock.SrcRefUsed != "unknown" {
+		return shortVersion(lock.SrcRefUsed)
 	}
-	
-	// Try in PATH
-	if path, err := exec.LookPath("box"); err == nil {
-		return path
-	}
-	
-	return ""
+	return fmt.Sprintf("gen %d", lock.Generation)
 }
 
-// parseBoxOutput parses the output from the Box binary
-func parseBoxOutput(output string) (*KeyMetadata, error) {
-	metadata := &KeyMetadata{
-		Algorithm: "ed25519", // default
-	}
-	
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				
-				switch key {
-				case "VERSION":
-					if v, err := strconv.Atoi(value); err == nil {
-						metadata.Version = v
-					}
-				case "ISSUED_AT":
-					if v, err := strconv.ParseInt(value, 10, 64); err == nil {
-						metadata.IssuedAt = v
-					}
-				case "EXPIRES_AT":
-					if v, err := strconv.ParseInt(value, 10, 64); err == nil {
-						metadata.ExpiresAt = v
-					}
-				case "ALGORITHM":
-					metadata.Algorithm = value
-				case "KEY":
-					metadata.Key = value
-				}
-			}
-		}
+// printTransaction shows one transaction with every lock field it changed
+func printTransaction(txn *pack.Transaction) {
+	fmt.Printf("transaction %d\n", txn.ID)
+	fmt.Printf("  time:    %s\n", txn.Time.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("  user:    %s\n", txn.User)
+	fmt.Printf("  command: %s\n", strings.TrimSpace(txn.Action+" "+strings.Join(txn.Args, " ")))
+	if txn.Error != "" {
+		fmt.Printf("  error:   %s\n", txn.Error)
 	}
-	
-	if metadata.Key == "" {
-		return nil, fmt.Errorf("no public key found in metadata")
-	}
-	
-	return metadata, nil
-}
 
-// parseKeyMetadataSimple is a fallback simple parser for when Box binary is not available
-func parseKeyMetadataSimple(content string) (*KeyMetadata, error) {
-	metadata := &KeyMetadata{
-		Algorithm: "ed25519", // default
-	}
-	
-	lines := strings.Split(content, "\n")
-	inKeyInfo := false
-	inPubKey := false
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		
-		// Check for block start/end
-		if strings.HasPrefix(line, "[data -c keyinfo]") {
-			inKeyInfo = true
-			inPubKey = false
-			continue
-		} else if strings.HasPrefix(line, "[data -c pubkey]") {
-			inKeyInfo = false
-			inPubKey = true
-			continue
-		} else if line == "end" {
-			inKeyInfo = false
-			inPubKey = false
-			continue
+	for _, change := range txn.Changes {
+		fmt.Println()
+		switch {
+		case change.Before == nil:
+			fmt.Printf("%s: installed\n", change.Package)
+		case change.After == nil:
+			fmt.Printf("%s: removed\n", change.Package)
+		default:
+			fmt.Printf("%s: changed\n", change.Package)
 		}
-		
-		// Parse fields within blocks
-		if inKeyInfo && strings.Contains(line, " ") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				switch parts[0] {
-				case "version":
-					if v, err := strconv.Atoi(parts[1]); err == nil {
-						metadata.Version = v
-					}
-				case "issued_at":
-					if v, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
-						metadata.IssuedAt = v
-					}
-				case "expires_at":
-					if v, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
-						metadata.ExpiresAt = v
-					}
-				case "algorithm":
-					metadata.Algorithm = parts[1]
-				}
-			}
-		} else if inPubKey && strings.Contains(line, " ") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 && parts[0] == "key" {
-				metadata.Key = parts[1]
-			}
+		for _, field := range change.Fields() {
+			fmt.Printf("  %-14s %s → %s\n", field.Field, valueOrDash(field.Old), valueOrDash(field.New))
 		}
 	}
-	
-	if metadata.Key == "" {
-		return nil, fmt.Errorf("no public key found in metadata")
+	if txn.SourcesChanged {
+		fmt.Println()
+		fmt.Println("sources.box: changed")
 	}
-	
-	return metadata, nil
 }
 
-// refreshPublicKeys updates cached public keys from all configured sources
-func refreshPublicKeys() {
-	// Get all configured source repositories
-	configPath, err := getConfigPath()
-	if err != nil {
-		fmt.Printf("Warning: could not get config path: %v\n", err)
-		return
-	}
-	
-	configFile := filepath.Join(configPath, "sources.box")
-	content, err := os.ReadFile(configFile)
-	if err != nil {
-		fmt.Printf("Warning: could not read sources config: %v\n", err)
-		return
-	}
-	
-	lines := strings.Split(string(content), "\n")
-	var inDataBlock bool
-	var sources []string
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		if strings.HasPrefix(trimmed, "[data") && strings.Contains(trimmed, "sources") {
-			inDataBlock = true
-			continue
-		}
-		
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			parts := strings.SplitN(trimmed, " ", 2)
-			if len(parts) >= 2 && parts[0] == "repo" {
-				sources = append(sources, parts[1])
-			}
-		}
+// valueOrDash stands in for an empty value
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
 	}
-	
-	// Refresh keys from each source concurrently
-	refreshKeysConcurrently(sources)
+	return value
 }
 
-// refreshKeysConcurrently refreshes public keys from multiple sources in parallel
-func refreshKeysConcurrently(sources []string) {
-	if len(sources) == 0 {
+// undoTransaction reverses a transaction from the history, the newest by default
+func undoTransaction(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		showUndoHelp()
 		return
 	}
-	
-	// Filter out local sources
-	var remoteSources []string
-	for _, source := range sources {
-		if source != "local" {
-			remoteSources = append(remoteSources, source)
+
+	id := 0
+	if len(args) > 0 {
+		var err error
+		id, err = strconv.Atoi(args[0])
+		if err != nil || id < 1 {
+			fmt.Printf("error: invalid transaction id '%s'\n", args[0])
+			os.Exit(1)
 		}
 	}
-	
-	if len(remoteSources) == 0 {
+
+	txn, failed, err := manager.Undo(id)
+	if errors.Is(err, pack.ErrCancelled) {
+		fmt.Println("undo cancelled")
 		return
 	}
-	
-	// Create jobs and results channels
-	jobs := make(chan string, len(remoteSources))
-	results := make(chan keyRefreshResult, len(remoteSources))
-	
-	workerCount := keyRefreshWorkers
-	if len(remoteSources) < keyRefreshWorkers {
-		workerCount = len(remoteSources)
-	}
-	
-	// Start worker goroutines
-	for w := 0; w < workerCount; w++ {
-		go keyRefreshWorker(jobs, results)
-	}
-	
-	// Send jobs
-	go func() {
-		defer close(jobs)
-		for _, source := range remoteSources {
-			jobs <- source
-		}
-	}()
-	
-	// Collect results
-	for i := 0; i < len(remoteSources); i++ {
-		result := <-results
-		if result.Error != nil {
-			fmt.Printf("Warning: could not refresh key for %s: %v\n", result.Source, result.Error)
-		}
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
-}
 
-// keyRefreshResult represents the result of a key refresh operation
-type keyRefreshResult struct {
-	Source string
-	Error  error
+	if len(failed) > 0 {
+		fmt.Printf("⚠️  transaction %d partly undone\n", txn.ID)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ transaction %d undone\n", txn.ID)
 }
 
-// keyRefreshWorker processes key refresh jobs
-func keyRefreshWorker(jobs <-chan string, results chan<- keyRefreshResult) {
-	for source := range jobs {
-		_, err := fetchPublicKeyFromRepo(source)
-		results <- keyRefreshResult{
-			Source: source,
-			Error:  err,
-		}
+// holdPackages sets or clears the hold on each package named in args
+func holdPackages(command string, args []string) {
+	if args[0] == "help" {
+		showHoldHelp()
+		return
 	}
-}
 
-// getScriptURL constructs the script URL for a given source and package
-func getScriptURL(sourceRepo, packageName string) string {
-	return fmt.Sprintf("%s/raw/main/%s", sourceRepo, packageName)
-}
+	failed := false
+	for _, packageName := range args {
+		var changed bool
+		var err error
+		if command == "hold" {
+			changed, err = manager.Hold(packageName)
+		} else {
+			changed, err = manager.Unhold(packageName)
+		}
+
+		switch {
+		case err != nil:
+			fmt.Printf("error: %v\n", err)
+			failed = true
+		case !changed && command == "hold":
+			fmt.Printf("%s is already held\n", packageName)
+		case !changed:
+			fmt.Printf("%s is not held\n", packageName)
+		case command == "hold":
+			fmt.Printf("✓ %s held, pack update will skip it\n", packageName)
+		default:
+			fmt.Printf("✓ %s released\n", packageName)
+		}
+	}
 
-// getLockFilePath returns the path to a package's lock file
-func getLockFilePath(packageName string) (string, error) {
-	packPath, err := getPackDir()
-	if err != nil {
-		return "", err
+	if failed {
+		os.Exit(1)
 	}
-	
-	lockPath := filepath.Join(packPath, "locks", packageName+".lock")
-	return lockPath, nil
-}
-
-// createLockFile creates a lock file with unambiguous field names and trust state
-func createLockFile(packageName, repo, sourceURL, sourceType, sourceRef, sourceVersion, recipeVersion, recipeURL, recipeSHA256 string) error {
-	lockFilePath, err := getLockFilePath(packageName)
-	if err != nil {
-		return err
-	}
-	
-	// Get shelf and symlink paths
-	shelfPath, err := getShelfPath()
-	if err != nil {
-		return err
-	}
-	packageShelfPath := filepath.Join(shelfPath, packageName)
-	
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	symlinkPath := filepath.Join(homeDir, ".local/bin", packageName)
-	configDir := filepath.Join(homeDir, ".config", packageName)
-	
-	// Determine trust state - always ed25519 now
-	trustState := "ed25519"
-	
-	// Create comprehensive lock file content with unambiguous field names
-	lockContent := fmt.Sprintf(`[data -c lock]
-  package %s
-  repo %s
-  src_url %s
-  src_type %s
-  src_ref %s
-  src_ref_used %s
-  recipe_sha256 %s
-  recipe_url %s
-  installed_at %s
-  shelf_path %s
-  symlink_path %s
-  config_dir %s
-  trust_state %s
-end
-`, packageName, repo, sourceURL, sourceType, sourceRef, sourceVersion, recipeVersion, recipeURL, time.Now().UTC().Format(time.RFC3339), packageShelfPath, symlinkPath, configDir, trustState)
-	
-	return os.WriteFile(lockFilePath, []byte(lockContent), 0644)
-}
-
-// extractRecipeURL extracts the src-url from the recipe data block
-func extractRecipeURL(scriptPath string) (string, error) {
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return "", err
-	}
-
-	lines := strings.Split(string(content), "\n")
-	inDataBlock := false
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		if strings.HasPrefix(trimmed, "[data") && strings.Contains(trimmed, "pkg") {
-			inDataBlock = true
-			continue
-		}
-		
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		// Look for src-url field in canonical schema
-		if inDataBlock && strings.HasPrefix(trimmed, "src-url ") {
-			url := strings.TrimSpace(strings.TrimPrefix(trimmed, "src-url"))
-			// Remove surrounding quotes if present
-			url = strings.Trim(url, "\"'")
-			return url, nil
-		}
-		
-		// Fallback to old url field for compatibility
-		if inDataBlock && strings.HasPrefix(trimmed, "url ") {
-			url := strings.TrimSpace(strings.TrimPrefix(trimmed, "url"))
-			// Remove surrounding quotes if present
-			url = strings.Trim(url, "\"'")
-			return url, nil
-		}
-	}
-	
-	return "", fmt.Errorf("src-url not found in recipe")
-}
-
-// detectSourceTypeAndVersion extracts and validates source info from canonical recipe schema
-func detectSourceTypeAndVersion(scriptPath string) (sourceType, sourceURL, sourceRef, sourceVersion string, err error) {
-	// Extract source fields from canonical recipe schema
-	sourceType, sourceURL, sourceRef, err = extractSourceFields(scriptPath)
-	if err != nil {
-		// Fall back to legacy url field
-		legacyURL, legacyErr := extractRecipeURL(scriptPath)
-		if legacyErr != nil {
-			return "", "", "", "", fmt.Errorf("failed to extract source info: %v", err)
-		}
-		
-		// Infer source type from legacy URL
-		if strings.Contains(legacyURL, "github.com") || strings.Contains(legacyURL, "gitlab.com") || strings.HasSuffix(legacyURL, ".git") {
-			sourceType = "git"
-			sourceURL = legacyURL
-			sourceRef = "HEAD"
-		} else {
-			sourceType = "archive"
-			sourceURL = legacyURL
-			sourceRef = "latest"
-		}
-	}
-	
-	// Get actual version based on source type
-	switch sourceType {
-	case "git":
-		sourceVersion, err = getGitRefCommit(sourceURL, sourceRef)
-		if err != nil {
-			sourceVersion = "unknown"
-			err = nil // Don't fail installation for version detection issues
-		}
-	case "archive", "file":
-		// For archives and files, use the ref as version
-		sourceVersion = sourceRef
-	default:
-		sourceVersion = "unknown"
-	}
-	
-	return sourceType, sourceURL, sourceRef, sourceVersion, nil
-}
-
-// extractSourceFields extracts src-type, src-url, and src-ref from canonical recipe schema
-func extractSourceFields(scriptPath string) (srcType, srcURL, srcRef string, err error) {
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return "", "", "", err
-	}
-
-	lines := strings.Split(string(content), "\n")
-	inDataBlock := false
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		if strings.HasPrefix(trimmed, "[data") && strings.Contains(trimmed, "pkg") {
-			inDataBlock = true
-			continue
-		}
-		
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			parts := strings.SplitN(trimmed, " ", 2)
-			if len(parts) >= 2 {
-				key := parts[0]
-				value := strings.Trim(parts[1], "\"'")
-				
-				switch key {
-				case "src-type":
-					srcType = strings.TrimSpace(value)
-				case "src-url":
-					srcURL = strings.TrimSpace(value)
-				case "src-ref":
-					srcRef = strings.TrimSpace(value)
-				}
-			}
-		}
-	}
-	
-	if srcType == "" || srcURL == "" {
-		return "", "", "", fmt.Errorf("missing required src-type or src-url fields")
-	}
-	
-	if srcRef == "" {
-		srcRef = "HEAD" // Default reference
-	}
-	
-	return srcType, srcURL, srcRef, nil
-}
-
-// getGitRefCommit gets the commit hash for a specific reference
-func getGitRefCommit(repoURL, ref string) (string, error) {
-	cmd := exec.Command("git", "ls-remote", repoURL, ref)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get git commit for ref %s: %v", ref, err)
-	}
-	
-	// Parse the output to get the commit hash
-	parts := strings.Fields(string(output))
-	if len(parts) > 0 {
-		return parts[0][:8], nil // Return short commit hash (8 chars)
-	}
-	
-	return "", fmt.Errorf("no commit hash found for ref %s", ref)
-}
-
-// getGitHeadCommit gets the HEAD commit hash from a git repository
-func getGitHeadCommit(repoURL string) (string, error) {
-	cmd := exec.Command("git", "ls-remote", repoURL, "HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get git commit: %v", err)
-	}
-	
-	// Parse the output to get the commit hash
-	parts := strings.Fields(string(output))
-	if len(parts) > 0 {
-		return parts[0][:8], nil // Return short commit hash (8 chars)
-	}
-	
-	return "", fmt.Errorf("no commit hash found")
-}
-
-// calculateRecipeVersion calculates the hash of recipe content excluding sha256 field
-func calculateRecipeVersion(scriptPath string) (string, error) {
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return "", err
-	}
-	
-	contentWithoutSHA256, err := removeCSHA256Field(content)
-	if err != nil {
-		return "", err
-	}
-	
-	return calculateSHA256(contentWithoutSHA256), nil
-}
-
-// constructRecipeURL constructs the recipe URL based on selected source
-func constructRecipeURL(selectedSource PackageSource, packageName string) string {
-	if selectedSource.Type == "local" {
-		return "local"
-	}
-	
-	// For remote sources, construct the recipe URL
-	return fmt.Sprintf("%s/raw/main/%s.box", selectedSource.Name, packageName)
-}
-
-// getSourceCommit gets the latest commit hash from a git repository URL
-func getSourceCommit(sourceURL string) (string, error) {
-	// For now, return a placeholder. In a real implementation, this would
-	// use git ls-remote or similar to get the actual commit hash
-	cmd := exec.Command("git", "ls-remote", sourceURL, "HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		return "unknown", nil // Don't fail if we can't get commit info
-	}
-	
-	// Parse the output to get the commit hash
-	parts := strings.Fields(string(output))
-	if len(parts) > 0 {
-		return parts[0][:8], nil // Return short commit hash
-	}
-	
-	return "unknown", nil
-}
-
-// extractPackageInfo extracts version and SHA256 from a recipe
-func extractPackageInfo(scriptPath string) (version, sha256 string, err error) {
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to read script: %v", err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	var inDataBlock bool
-	var blockIndent int
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		// Check for data block start
-		if strings.HasPrefix(trimmed, "[data") {
-			inDataBlock = true
-			blockIndent = len(line) - len(strings.TrimLeft(line, " \t"))
-			continue
-		}
-		
-		// Check for block end
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		// Check for any other block start
-		if inDataBlock && strings.HasPrefix(trimmed, "[") {
-			break
-		}
-		
-		// Parse data block content
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
-			if lineIndent > blockIndent {
-				parts := strings.SplitN(trimmed, " ", 2)
-				if len(parts) >= 2 {
-					key := parts[0]
-					value := strings.TrimSpace(parts[1])
-					switch key {
-					case "ver":
-						version = value
-					case "sha256":
-						sha256 = value
-					}
-				}
-			}
-		}
-	}
-	
-	if version == "" {
-		version = "unknown"
-	}
-	if sha256 == "" {
-		sha256 = "unknown"
-	}
-	
-	return version, sha256, nil
-}
-
-// removeCSHA256Field removes the sha256 field from file content for hash calculation
-func removeCSHA256Field(content []byte) ([]byte, error) {
-	lines := strings.Split(string(content), "\n")
-	var filteredLines []string
-
-	for _, line := range lines {
-		// Simple approach: skip any line that contains "sha256" as the first field after whitespace
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" && strings.HasPrefix(trimmed, "sha256 ") {
-			// Skip this line
-			continue
-		}
-		// Add all other lines
-		filteredLines = append(filteredLines, line)
-	}
-	
-	return []byte(strings.Join(filteredLines, "\n")), nil
-}
-
-// executeUninstallScript calls the recipe's uninstall function directly
-func executeUninstallScript(packageName string) error {
-	// Check if lock file exists
-	lockFilePath, err := getLockFilePath(packageName)
-	if err != nil {
-		return fmt.Errorf("failed to get lock file path: %v", err)
-	}
-	
-	if _, err := os.Stat(lockFilePath); os.IsNotExist(err) {
-		return fmt.Errorf("package %s is not installed (no lock file found)", packageName)
-	}
-	
-	// Read lock file to get original recipe URL
-	lockData, err := parseLockFile(lockFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to parse lock file: %v", err)
-	}
-	
-	// Create temporary directory for uninstall in .pack/tmp
-	packPath, err := getPackDir()
-	if err != nil {
-		return fmt.Errorf("failed to get pack directory: %v", err)
-	}
-	packTmpDir := filepath.Join(packPath, "tmp")
-	tempDir, err := os.MkdirTemp(packTmpDir, "pack-uninstall-"+packageName)
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-	
-	// Download the original recipe
-	scriptPath := filepath.Join(tempDir, packageName+".box")
-	originalRepo := lockData["repo"]
-	
-	if originalRepo == "local" {
-		// Use local source - try both old and new formats
-		localRepoPath, err := getLocalRepoPath()
-		if err != nil {
-			return fmt.Errorf("failed to get local repo path: %v", err)
-		}
-		
-		// First try old flat structure
-		localPackagePath := filepath.Join(localRepoPath, packageName+".box")
-		if err := copyFile(localPackagePath, scriptPath); err != nil {
-			// If old structure fails, try new section-based structure
-			sections := []string{"lang", "utils", "toys", "misc"}
-			found := false
-			for _, section := range sections {
-				localPackagePath = filepath.Join(localRepoPath, section, packageName, packageName+".box")
-				if err := copyFile(localPackagePath, scriptPath); err == nil {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return fmt.Errorf("failed to find package in local source: %v", err)
-			}
-		}
-	} else {
-		// Use remote source - try both old and new formats
-		scriptURL := fmt.Sprintf("%s/raw/main/%s.box", originalRepo, packageName)
-		if err := downloadFile(scriptURL, scriptPath); err != nil {
-			// If old structure fails, try new section-based structure
-			sections := []string{"lang", "utils", "toys", "misc"}
-			found := false
-			for _, section := range sections {
-				scriptURL = fmt.Sprintf("%s/raw/main/%s/%s/%s.box", originalRepo, section, packageName, packageName)
-				if err := downloadFile(scriptURL, scriptPath); err == nil {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return fmt.Errorf("failed to download recipe: %v", err)
-			}
-		}
-	}
-	
-	// Find box executable
-	boxPath, err := findBoxExecutable()
-	if err != nil {
-		return fmt.Errorf("box executable not found: %v", err)
-	}
-	
-	// Execute recipe with uninstall verb
-	execCmd := exec.Command(boxPath, scriptPath, "uninstall")
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-	execCmd.Stdin = os.Stdin
-	execCmd.Dir = tempDir
-	
-	err = execCmd.Run()
-	if err != nil {
-		return fmt.Errorf("uninstall failed: %v", err)
-	}
-	
-	// Remove lock file after successful uninstall
-	fmt.Println("removing lockfile...")
-	if err := os.Remove(lockFilePath); err != nil {
-		fmt.Printf("warning: failed to remove lockfile: %v\n", err)
-	} else {
-		fmt.Println("✓ lockfile removed")
-	}
-	
-	return nil
-}
-
-// listPackages displays all installed packages with their version information
-func listInstalledPackages(args []string) {
-	if len(args) > 0 && args[0] == "help" {
-		showListHelp()
-		return
-	}
-	
-	packPath, err := getPackDir()
-	if err != nil {
-		fmt.Printf("error getting pack directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	locksDir := filepath.Join(packPath, "locks")
-	if _, err := os.Stat(locksDir); os.IsNotExist(err) {
-		fmt.Println("no packages installed")
-		return
-	}
-
-	files, err := os.ReadDir(locksDir)
-	if err != nil {
-		fmt.Printf("error reading locks directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	if len(files) == 0 {
-		fmt.Println("no packages installed")
-		return
-	}
-
-	fmt.Printf("%-15s %-12s %-30s %s\n", "package", "version", "source", "installed")
-	fmt.Printf("%-15s %-12s %-30s %s\n", "-------", "-------", "------", "---------")
-
-	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".lock") {
-			continue
-		}
-
-		packageName := strings.TrimSuffix(file.Name(), ".lock")
-		lockData, err := parseLockFile(filepath.Join(locksDir, file.Name()))
-		if err != nil {
-			fmt.Printf("%-15s %-12s %-30s %s\n", packageName, "error", "error", "error")
-			continue
-		}
-
-		// Format the display
-		version := lockData["src_ref_used"]
-		if len(version) > 12 {
-			version = version[:12]
-		}
-		source := lockData["src_url"]
-		if len(source) > 30 {
-			source = source[:27] + "..."
-		}
-		installDate := lockData["installed_at"]
-		if installDate != "" {
-			// Parse and format the date
-			if t, err := time.Parse(time.RFC3339, installDate); err == nil {
-				installDate = t.Format("2006-01-02")
-			}
-		}
-
-		fmt.Printf("%-15s %-12s %-30s %s\n", packageName, version, source, installDate)
-	}
-}
-
-// listAllPackages displays all packages available in configured repositories
-func listAllPackages(args []string) {
-	if len(args) > 0 && args[0] == "help" {
-		fmt.Println("Usage: pack list [source]")
-		fmt.Println("Lists all packages available in configured repositories")
-		fmt.Println("Optional: specify source index (1, 2, etc.) to list from specific repository")
-		return
-	}
-	
-	sources, err := getConfiguredSources()
-	if err != nil {
-		fmt.Printf("error getting sources: %v\n", err)
-		os.Exit(1)
-	}
-	
-	if len(sources) == 0 {
-		fmt.Println("no package sources configured")
-		fmt.Println("use 'pack add-source <url>' to add a repository")
-		return
-	}
-	
-	// If source specified, list only that source
-	if len(args) > 0 {
-		sourceIndex, err := strconv.Atoi(args[0])
-		if err != nil || sourceIndex < 1 || sourceIndex > len(sources) {
-			fmt.Printf("invalid source index: %s\n", args[0])
-			fmt.Println("available sources:")
-			for i, source := range sources {
-				fmt.Printf("  %d) %s\n", i+1, source.Name)
-			}
-			return
-		}
-		
-		fmt.Printf("Packages from %s:\n", sources[sourceIndex-1].Name)
-		listPackagesFromSource(sources[sourceIndex-1])
-		return
-	}
-	
-	// List packages from all sources
-	for i, source := range sources {
-		fmt.Printf("\n%d) %s:\n", i+1, source.Name)
-		listPackagesFromSource(source)
-	}
-}
-
-// seekPackages searches for packages by name or description
-func seekPackages(args []string) {
-	if len(args) == 0 {
-		fmt.Println("error: search term required")
-		fmt.Println("usage: pack seek <search_term>")
-		return
-	}
-	
-	searchTerm := strings.ToLower(strings.Join(args, " "))
-	
-	sources, err := getConfiguredSources()
-	if err != nil {
-		fmt.Printf("error getting sources: %v\n", err)
-		os.Exit(1)
-	}
-	
-	if len(sources) == 0 {
-		fmt.Println("no package sources configured")
-		return
-	}
-	
-	fmt.Printf("Searching for '%s'...\n\n", searchTerm)
-	foundAny := false
-	
-	for _, source := range sources {
-		packages := getPackagesFromSource(source)
-		found := false
-		
-		for _, pkg := range packages {
-			// Search in name and description
-			if strings.Contains(strings.ToLower(pkg.Name), searchTerm) ||
-			   strings.Contains(strings.ToLower(pkg.Description), searchTerm) {
-				if !found {
-					fmt.Printf("From %s:\n", source.Name)
-					found = true
-					foundAny = true
-				}
-				fmt.Printf("  %-15s - %s\n", pkg.Name, pkg.Description)
-			}
-		}
-		if found {
-			fmt.Println()
-		}
-	}
-	
-	if !foundAny {
-		fmt.Printf("No packages found matching '%s'\n", searchTerm)
-		fmt.Println("Try 'pack list' to see all available packages")
-	}
-}
-
-// PackageInfo represents basic package information
-type PackageInfo struct {
-	Name        string
-	Description string
-	Version     string
-	License     string
-}
-
-// listPackagesFromSource lists packages from a specific source with smart pagination
-func listPackagesFromSource(source Source) {
-	packages := getPackagesFromSource(source)
-	
-	if len(packages) == 0 {
-		fmt.Println("  no packages available")
-		return
-	}
-	
-	fmt.Printf("  %-15s %-50s %s\n", "name", "description", "license")
-	fmt.Printf("  %-15s %-50s %s\n", "----", "-----------", "-------")
-	
-	// For large lists, show first packages and total count
-	displayCount := len(packages)
-	if displayCount > maxPackagesDisplay {
-		displayCount = maxPackagesDisplay
-	}
-	
-	for i := 0; i < displayCount; i++ {
-		pkg := packages[i]
-		desc := pkg.Description
-		if len(desc) > 50 {
-			desc = desc[:47] + "..."
-		}
-		fmt.Printf("  %-15s %-50s %s\n", pkg.Name, desc, pkg.License)
-	}
-	
-	// Show summary if list was truncated
-	if len(packages) > maxPackagesDisplay {
-		fmt.Printf("\n  showing %d of %d packages (use 'pack seek' to search for specific packages)\n", 
-			maxPackagesDisplay, len(packages))
-	}
-}
-
-// getPackagesFromSource fetches and parses package information from a source
-func getPackagesFromSource(source Source) []PackageInfo {
-	// Updated list of all known packages
-	commonPackages := []string{
-		"9dir", "boxlang", "btop", "cava", "cbonsai", "cpick", "crystal-orb", 
-		"edith", "fml", "glow", "pack", "packlib", "pfetch", "python", 
-		"shrub9", "vim", // "test", "uninstall" are internal
-	}
-	
-	return checkPackagesParallel(source, commonPackages)
-}
-
-// checkPackagesParallel checks for package existence in parallel
-func checkPackagesParallel(source Source, packageNames []string) []PackageInfo {
-	
-	jobs := make(chan string, len(packageNames))
-	results := make(chan packageCheckResult, len(packageNames))
-	
-	workerCount := packageDiscoveryWorkers
-	if len(packageNames) < packageDiscoveryWorkers {
-		workerCount = len(packageNames)
-	}
-	
-	// Start worker goroutines
-	for w := 0; w < workerCount; w++ {
-		go packageDiscoveryWorker(source, jobs, results)
-	}
-	
-	// Send jobs
-	go func() {
-		defer close(jobs)
-		for _, pkgName := range packageNames {
-			jobs <- pkgName
-		}
-	}()
-	
-	// Collect results
-	var packages []PackageInfo
-	for i := 0; i < len(packageNames); i++ {
-		result := <-results
-		if result.Package.Name != "" {
-			packages = append(packages, result.Package)
-		}
-	}
-	
-	return packages
-}
-
-// packageCheckResult represents the result of checking a package
-type packageCheckResult struct {
-	Package PackageInfo
-	Error   error
-}
-
-// packageDiscoveryWorker checks if packages exist in a source
-func packageDiscoveryWorker(source Source, jobs <-chan string, results chan<- packageCheckResult) {
-	for pkgName := range jobs {
-		result := packageCheckResult{}
-		
-		// First try the old flat structure for backward compatibility
-		var scriptURL string
-		if strings.Contains(source.URL, "raw.githubusercontent.com") {
-			scriptURL = fmt.Sprintf("%s/%s.box", source.URL, pkgName)
-		} else {
-			scriptURL = fmt.Sprintf("%s/raw/main/%s.box", source.URL, pkgName)
-		}
-		
-		// Try to download the package file to check if it exists
-		tempFile, err := os.CreateTemp("", "pkg_check_*.box")
-		if err != nil {
-			result.Error = err
-			results <- result
-			continue
-		}
-		
-		tempFile.Close()
-		defer os.Remove(tempFile.Name())
-		
-		if downloadFile(scriptURL, tempFile.Name()) == nil {
-			// Parse package info from the downloaded file
-			if pkg := parsePackageInfo(tempFile.Name()); pkg.Name != "" {
-				result.Package = pkg
-			}
-		} else {
-			// If not found in flat structure, search through sections
-			sections := []string{"lang", "utils", "toys", "misc"}
-			
-			for _, section := range sections {
-				// Try raw github content URL format
-				if strings.Contains(source.URL, "raw.githubusercontent.com") {
-					scriptURL = fmt.Sprintf("%s/%s/%s/%s.box", source.URL, section, pkgName, pkgName)
-				} else {
-					scriptURL = fmt.Sprintf("%s/raw/main/%s/%s/%s.box", source.URL, section, pkgName, pkgName)
-				}
-				
-				if downloadFile(scriptURL, tempFile.Name()) == nil {
-					// Parse package info from the downloaded file
-					if pkg := parsePackageInfo(tempFile.Name()); pkg.Name != "" {
-						result.Package = pkg
-					}
-					break
-				}
-			}
-		}
-		
-		results <- result
-	}
-}
-
-// parsePackageInfo extracts package information from a .box file
-func parsePackageInfo(filePath string) PackageInfo {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return PackageInfo{}
-	}
-	
-	// Simple parsing of the data block
-	lines := strings.Split(string(content), "\n")
-	inDataBlock := false
-	pkg := PackageInfo{}
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		
-		if strings.HasPrefix(line, "[data -c pkg]") {
-			inDataBlock = true
-			continue
-		} else if line == "end" {
-			inDataBlock = false
-			continue
-		}
-		
-		if inDataBlock && strings.Contains(line, " ") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				switch parts[0] {
-				case "name":
-					pkg.Name = parts[1]
-				case "desc":
-					pkg.Description = strings.Join(parts[1:], " ")
-				case "ver":
-					pkg.Version = parts[1]
-				case "license":
-					pkg.License = parts[1]
-				}
-			}
-		}
-	}
-	
-	return pkg
-}
-
-// updatePackages scans for updates and installs them with confirmation
-func updatePackages(args []string) {
-	if len(args) > 0 && args[0] == "help" {
-		showUpdateHelp()
-		return
-	}
-	
-	// Always update pack and boxlang first during pack update
-	updateCorePackagesFirst()
-	
-	// Refresh public keys from all configured sources
-	fmt.Println("Refreshing public keys...")
-	refreshPublicKeys()
-	
-	availableUpdates, err := scanForUpdates()
-	if err != nil {
-		fmt.Printf("error scanning for updates: %v\n", err)
-		os.Exit(1)
-	}
-
-	if len(availableUpdates) == 0 {
-		fmt.Println("all packages are up to date")
-		return
-	}
-
-	// Display available updates
-	fmt.Printf("\navailable updates:\n")
-	for _, update := range availableUpdates {
-		fmt.Printf("- %s: %s → %s (%s)\n", 
-			update.PackageName, 
-			update.CurrentVersion, 
-			update.NewVersion, 
-			update.UpdateType)
-	}
-
-	// Ask for confirmation
-	fmt.Printf("\nupdate %d package(s)? [y/N]: ", len(availableUpdates))
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		fmt.Printf("error reading input: %v\n", err)
-		os.Exit(1)
-	}
-	
-	response = strings.TrimSpace(strings.ToLower(response))
-	if response != "y" && response != "yes" {
-		fmt.Println("update cancelled")
-		return
-	}
-
-	// Perform updates
-	fmt.Println("\nupdating packages...")
-	total := len(availableUpdates)
-	
-	for i, update := range availableUpdates {
-		showProgress(i, total, fmt.Sprintf("updating %s...", update.PackageName))
-		if err := updatePackageFromOriginalSource(update.PackageName); err != nil {
-			fmt.Printf("\nerror updating %s: %v\n", update.PackageName, err)
-		} else {
-			showProgress(i+1, total, fmt.Sprintf("updated %s", update.PackageName))
-		}
-	}
-	
-	fmt.Println("update complete!")
-}
-
-// PackageUpdate represents an available update
-type PackageUpdate struct {
-	PackageName    string
-	CurrentVersion string
-	NewVersion     string
-	UpdateType     string // "source updated", "recipe updated", "both updated"
-}
-
-// scanForUpdates checks all installed packages for available updates using concurrent workers
-func scanForUpdates() ([]PackageUpdate, error) {
-	packPath, err := getPackDir()
-	if err != nil {
-		return nil, err
-	}
-
-	locksDir := filepath.Join(packPath, "locks")
-	if _, err := os.Stat(locksDir); os.IsNotExist(err) {
-		return []PackageUpdate{}, nil // No packages installed
-	}
-
-	files, err := os.ReadDir(locksDir)
-	if err != nil {
-		return nil, err
-	}
-
-	// Filter for lock files only
-	var lockFiles []string
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), ".lock") {
-			lockFiles = append(lockFiles, file.Name())
-		}
-	}
-
-	if len(lockFiles) == 0 {
-		return []PackageUpdate{}, nil
-	}
-
-	// Use concurrent workers for faster checking
-	return checkUpdatesParallel(lockFiles, locksDir)
-}
-
-// PackageCheckJob represents a job for checking package updates
-type PackageCheckJob struct {
-	FileName    string
-	PackageName string
-	LocksDir    string
-}
-
-// PackageCheckResult represents the result of checking a package for updates
-type PackageCheckResult struct {
-	Update    PackageUpdate
-	HasUpdate bool
-	Error     error
-	Package   string
-}
-
-// checkUpdatesParallel checks packages for updates using multiple goroutines
-func checkUpdatesParallel(lockFiles []string, locksDir string) ([]PackageUpdate, error) {
-	
-	total := len(lockFiles)
-	jobs := make(chan PackageCheckJob, total)
-	results := make(chan PackageCheckResult, total)
-	
-	// Start worker goroutines
-	for w := 0; w < updateCheckWorkers && w < total; w++ {
-		go updateCheckWorker(jobs, results)
-	}
-	
-	// Send jobs to workers
-	go func() {
-		defer close(jobs)
-		for _, fileName := range lockFiles {
-			packageName := strings.TrimSuffix(fileName, ".lock")
-			jobs <- PackageCheckJob{
-				FileName:    fileName,
-				PackageName: packageName,
-				LocksDir:    locksDir,
-			}
-		}
-	}()
-	
-	// Collect results and show progress with better synchronization
-	var updates []PackageUpdate
-	completed := 0
-	
-	// Use a ticker to limit progress updates to prevent terminal glitching
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-	
-	lastProgressUpdate := 0
-	
-	progressUpdate := func() {
-		if completed > lastProgressUpdate {
-			showProgress(completed, total, "")
-			lastProgressUpdate = completed
-		}
-	}
-	
-	// Progress update goroutine
-	go func() {
-		for range ticker.C {
-			if completed >= total {
-				return
-			}
-			progressUpdate()
-		}
-	}()
-	
-	for completed < total {
-		result := <-results
-		completed++
-		
-		if result.Error != nil {
-			fmt.Printf("\nwarning: failed to check updates for %s: %v\n", result.Package, result.Error)
-			continue
-		}
-		
-		if result.HasUpdate {
-			updates = append(updates, result.Update)
-		}
-	}
-	
-	// Final progress update
-	progressUpdate()
-	
-	return updates, nil
-}
-
-// updateCheckWorker is a worker that processes package update check jobs
-func updateCheckWorker(jobs <-chan PackageCheckJob, results chan<- PackageCheckResult) {
-	for job := range jobs {
-		result := PackageCheckResult{
-			Package: job.PackageName,
-		}
-		
-		// Parse lock file
-		lockData, err := parseLockFile(filepath.Join(job.LocksDir, job.FileName))
-		if err != nil {
-			result.Error = err
-			results <- result
-			continue
-		}
-		
-		// Check for updates
-		update, hasUpdate, err := checkPackageForUpdate(job.PackageName, lockData)
-		result.Update = update
-		result.HasUpdate = hasUpdate
-		result.Error = err
-		
-		results <- result
-	}
-}
-
-// parseLockFile parses a lock file and returns a map of key-value pairs
-func parseLockFile(lockFilePath string) (map[string]string, error) {
-	content, err := os.ReadFile(lockFilePath)
-	if err != nil {
-		return nil, err
-	}
-
-	lockData := make(map[string]string)
-	lines := strings.Split(string(content), "\n")
-	inDataBlock := false
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		if strings.HasPrefix(trimmed, "[data") && strings.Contains(trimmed, "lock") {
-			inDataBlock = true
-			continue
-		}
-		
-		if inDataBlock && trimmed == "end" {
-			break
-		}
-		
-		if inDataBlock && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			parts := strings.SplitN(trimmed, " ", 2)
-			if len(parts) >= 2 {
-				key := parts[0]
-				value := strings.TrimSpace(parts[1])
-				lockData[key] = value
-				
-				// Handle legacy field name mapping for backward compatibility
-				switch key {
-				case "source":
-					if _, exists := lockData["src_url"]; !exists {
-						lockData["src_url"] = value
-					}
-				case "source_type":
-					if _, exists := lockData["src_type"]; !exists {
-						lockData["src_type"] = value
-					}
-				case "source_version":
-					if _, exists := lockData["src_ref_used"]; !exists {
-						lockData["src_ref_used"] = value
-					}
-				case "sha256":
-					if _, exists := lockData["recipe_sha256"]; !exists {
-						lockData["recipe_sha256"] = value
-					}
-				}
-			}
-		}
-	}
-
-	return lockData, nil
-}
-
-// checkPackageForUpdate checks if a package has available updates
-func checkPackageForUpdate(packageName string, lockData map[string]string) (PackageUpdate, bool, error) {
-	update := PackageUpdate{
-		PackageName:    packageName,
-		CurrentVersion: lockData["src_ref_used"],
-	}
-
-	var updateReasons []string
-
-	// Check recipe for updates
-	recipeURL := lockData["recipe_url"]
-	if recipeURL != "" && recipeURL != "local" {
-		// Download current recipe and compare hash
-		currentRecipeVersion, err := getCurrentRecipeVersion(recipeURL)
-		if err == nil && currentRecipeVersion != lockData["recipe_sha256"] {
-			updateReasons = append(updateReasons, "recipe updated")
-		}
-	}
-
-	// Check source for updates
-	sourceURL := lockData["src_url"]
-	sourceType := lockData["src_type"]
-	if sourceURL != "" && sourceURL != "unknown" {
-		newSourceVersion, err := getCurrentSourceVersion(sourceURL, sourceType)
-		if err == nil && newSourceVersion != lockData["src_ref_used"] {
-			updateReasons = append(updateReasons, "source updated")
-			update.NewVersion = newSourceVersion
-		}
-	}
-
-	if len(updateReasons) == 0 {
-		return update, false, nil
-	}
-
-	update.UpdateType = strings.Join(updateReasons, ", ")
-	if update.NewVersion == "" {
-		update.NewVersion = "latest"
-	}
-
-	return update, true, nil
-}
-
-// getCurrentRecipeVersion downloads a recipe with ETag caching and calculates its version hash
-func getCurrentRecipeVersion(recipeURL string) (string, error) {
-	// Create temp file to download recipe
-	tempFile, err := os.CreateTemp("", "recipe-*.box")
-	if err != nil {
-		return "", err
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	// Download recipe without ETag caching for update checks
-	if err := downloadFileWithCache(recipeURL, tempFile.Name(), false); err != nil {
-		// If old URL format fails, try new section-based structure
-		if strings.Contains(recipeURL, "/raw/main/") && strings.HasSuffix(recipeURL, ".box") {
-			// Extract package name from URL
-			parts := strings.Split(recipeURL, "/")
-			if len(parts) > 0 {
-				boxFile := parts[len(parts)-1] // e.g., "pfetch.box"
-				packageName := strings.TrimSuffix(boxFile, ".box")
-				baseURL := strings.Replace(recipeURL, "/raw/main/"+boxFile, "", 1)
-				
-				// Try each section with proper raw.githubusercontent.com format
-				sections := []string{"lang", "utils", "toys", "misc"}
-				
-				// Convert to raw.githubusercontent.com format if needed
-				var rawBaseURL string
-				if strings.Contains(baseURL, "github.com") && strings.Contains(baseURL, "/raw/main") {
-					// Extract user/repo from baseURL
-					urlParts := strings.Split(baseURL, "/")
-					for i, part := range urlParts {
-						if part == "github.com" && i+2 < len(urlParts) {
-							user := urlParts[i+1]
-							repo := urlParts[i+2]
-							rawBaseURL = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/main", user, repo)
-							break
-						}
-					}
-				}
-				
-				if rawBaseURL != "" {
-					for _, section := range sections {
-						newURL := fmt.Sprintf("%s/%s/%s/%s", rawBaseURL, section, packageName, boxFile)
-						if err := downloadFileWithCache(newURL, tempFile.Name(), false); err == nil {
-							// Calculate version hash
-							return calculateRecipeVersion(tempFile.Name())
-						}
-					}
-				}
-			}
-		}
-		return "", err
-	}
-
-	// Calculate version hash
-	return calculateRecipeVersion(tempFile.Name())
-}
-
-// getCurrentSourceVersion gets the current version of a source
-func getCurrentSourceVersion(sourceURL, sourceType string) (string, error) {
-	switch sourceType {
-	case "git":
-		return getGitHeadCommit(sourceURL)
-	case "download":
-		// For downloads, we'd need to download and hash, but that's expensive
-		// For now, just indicate that we can't easily check
-		return "latest", nil
-	default:
-		return "", fmt.Errorf("unknown source type: %s", sourceType)
-	}
-}
-
-// updatePackageFromOriginalSource updates a package using the same source it was originally installed from
-func updatePackageFromOriginalSource(packageName string) error {
-	// Read the lock file to get original source info
-	lockFilePath, err := getLockFilePath(packageName)
-	if err != nil {
-		return fmt.Errorf("failed to get lock file path: %v", err)
-	}
-
-	lockData, err := parseLockFile(lockFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to parse lock file: %v", err)
-	}
-
-	originalRepo := lockData["repo"]
-	if originalRepo == "" {
-		return fmt.Errorf("no original repo found in lock file")
-	}
-
-	// Create temporary directory for script in .pack/tmp
-	packPath, err := getPackDir()
-	if err != nil {
-		return fmt.Errorf("failed to get pack directory: %v", err)
-	}
-	packTmpDir := filepath.Join(packPath, "tmp")
-	tempDir, err := os.MkdirTemp(packTmpDir, "pack-update-"+packageName)
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Download script from original source
-	scriptPath := filepath.Join(tempDir, packageName+".box")
-	
-	var selectedSource PackageSource
-	if originalRepo == "local" {
-		// Use local source
-		localRepoPath, err := getLocalRepoPath()
-		if err != nil {
-			return fmt.Errorf("failed to get local repo path: %v", err)
-		}
-		localPackagePath := filepath.Join(localRepoPath, packageName+".box")
-		if _, err := os.Stat(localPackagePath); err != nil {
-			return fmt.Errorf("package not found in local repository: %v", err)
-		}
-		
-		selectedSource = PackageSource{
-			Name: "local",
-			URL:  localPackagePath,
-			Type: "local",
-		}
-		
-		if err := copyFile(localPackagePath, scriptPath); err != nil {
-			return fmt.Errorf("failed to copy from local source: %v", err)
-		}
-	} else {
-		// Use remote source - first try old flat structure
-		scriptURL := fmt.Sprintf("%s/raw/main/%s.box", originalRepo, packageName)
-		
-		selectedSource = PackageSource{
-			Name: originalRepo,
-			URL:  scriptURL,
-			Type: "remote",
-		}
-		
-		if err := downloadFile(scriptURL, scriptPath); err != nil {
-			// If old format fails, try new section-based structure
-			found := false
-			sections := []string{"misc", "utils", "lang", "toys"}
-			
-			// Convert to raw.githubusercontent.com format if needed
-			var rawBaseURL string
-			if strings.Contains(originalRepo, "github.com") {
-				// Extract user/repo from originalRepo
-				urlParts := strings.Split(originalRepo, "/")
-				for i, part := range urlParts {
-					if part == "github.com" && i+2 < len(urlParts) {
-						user := urlParts[i+1]
-						repo := urlParts[i+2]
-						rawBaseURL = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/main", user, repo)
-						break
-					}
-				}
-			}
-			
-			if rawBaseURL != "" {
-				for _, section := range sections {
-					newURL := fmt.Sprintf("%s/%s/%s/%s.box", rawBaseURL, section, packageName, packageName)
-					if err := downloadFile(newURL, scriptPath); err == nil {
-						selectedSource.URL = newURL
-						found = true
-						break
-					}
-				}
-			}
-			
-			if !found {
-				return fmt.Errorf("failed to download from original source: %v", err)
-			}
-		}
-	}
-	
-	fmt.Printf("Using original source: %s\n", selectedSource.Name)
-
-	// Verify recipe integrity
-	fmt.Println("Verifying recipe integrity...")
-	if err := verifyRecipeIntegrity(scriptPath, originalRepo); err != nil {
-		fmt.Printf("⚠️  Warning: %v\n", err)
-		fmt.Print("Continue anyway? [y/N]: ")
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("failed to read input: %v", err)
-		}
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
-			return fmt.Errorf("update cancelled due to verification failure")
-		}
-	} else {
-		fmt.Println("✓ recipe integrity verified")
-	}
-
-	// Show recipe and get user confirmation
-	if err := showRecipeAndConfirm(scriptPath); err != nil {
-		return err
-	}
-
-	// Find box executable
-	boxPath, err := findBoxExecutable()
-	if err != nil {
-		return fmt.Errorf("box executable not found: %v", err)
-	}
-
-	// Execute script
-	cmdArgs := []string{scriptPath}
-
-	execCmd := exec.Command(boxPath, cmdArgs...)
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-	execCmd.Stdin = os.Stdin
-	execCmd.Dir = tempDir
-
-	err = execCmd.Run()
-	if err != nil {
-		return err
-	}
-
-	// Create or update lock file after successful installation
-	fmt.Println("updating lockfile...")
-	
-	// Extract source information from canonical schema
-	sourceType, recipeSourceURL, sourceRef, sourceVersion, err := detectSourceTypeAndVersion(scriptPath)
-	if err != nil {
-		fmt.Printf("warning: failed to extract source info: %v\n", err)
-		// Fall back to legacy extraction
-		recipeSourceURL, err = extractRecipeURL(scriptPath)
-		if err != nil {
-			fmt.Printf("warning: failed to extract source URL: %v\n", err)
-			recipeSourceURL = "unknown"
-		}
-		sourceType = "unknown"
-		sourceVersion = "unknown"
-		sourceRef = "unknown"
-	}
-	
-	// Calculate recipe version (content hash)
-	recipeVersion, err := calculateRecipeVersion(scriptPath)
-	if err != nil {
-		fmt.Printf("warning: failed to calculate recipe version: %v\n", err)
-		recipeVersion = "unknown"
-	}
-	
-	// Construct recipe URL from selected source
-	recipeURL := constructRecipeURL(selectedSource, packageName)
-	
-	// Calculate actual SHA256 for lock file
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		fmt.Printf("warning: failed to read script for hash: %v\n", err)
-	}
-	contentWithoutSHA256, err := removeCSHA256Field(content)
-	if err != nil {
-		fmt.Printf("warning: failed to remove SHA256 field: %v\n", err)
-	}
-	recipeSHA256 := calculateSHA256(contentWithoutSHA256)
-	
-	// Get repo name from selected source
-	repoName := selectedSource.Name
-	
-	if err := createLockFile(packageName, repoName, recipeSourceURL, sourceType, sourceRef, sourceVersion, recipeVersion, recipeURL, recipeSHA256); err != nil {
-		fmt.Printf("warning: failed to update lock file: %v\n", err)
-	} else {
-		fmt.Println("✓ lockfile updated")
-	}
-
-	return nil
-}
-
-// showHelp displays general help information
-func installPackageForRun(packageName string) error {
-	// Skip core package updates for run command to be faster
-	return executePackageScript(packageName, false, false)
 }
 
 func runPackage(args []string) {
@@ -3895,75 +1750,68 @@ func runPackage(args []string) {
 		fmt.Println("error: package name required")
 		os.Exit(1)
 	}
-	
+
 	packageName := args[0]
 	remainingArgs := args[1:]
-	
+
 	// Check if package is already installed
-	lockPath, err := getLockFilePath(packageName)
-	if err != nil {
-		fmt.Printf("error: %v\n", err)
-		os.Exit(1)
-	}
-	
-	wasInstalled := true
-	if _, err := os.Stat(lockPath); os.IsNotExist(err) {
-		wasInstalled = false
-		
+	wasInstalled := manager.IsInstalled(packageName)
+	if !wasInstalled {
 		// Package not installed, install it temporarily
 		fmt.Printf("Package %s not installed, installing temporarily...\n", packageName)
-		if err := installPackageForRun(packageName); err != nil {
+		// Skip core package updates for run command to be faster
+		if _, err := manager.Install(packageName, pack.InstallOptions{}); err != nil {
 			fmt.Printf("error installing package %s: %v\n", packageName, err)
 			os.Exit(1)
 		}
 		fmt.Println("✓ Temporary installation complete")
 	}
-	
-	// Get the executable path from lock file
-	lockData, err := parseLockFile(lockPath)
-	if err != nil {
-		fmt.Printf("error reading lock file: %v\n", err)
+
+	// cleanupAndExit removes a temporary installation before exiting
+	cleanupAndExit := func(code int) {
 		if !wasInstalled {
-			// Clean up temporary installation
 			fmt.Println("Cleaning up temporary installation...")
-			executeUninstallScript(packageName)
+			if err := manager.Uninstall(packageName); err != nil {
+				fmt.Printf("warning: failed to clean up temporary installation: %v\n", err)
+			} else if code == 0 {
+				fmt.Printf("✓ Temporary installation of %s cleaned up\n", packageName)
+			}
 		}
-		os.Exit(1)
+		os.Exit(code)
+	}
+
+	// Get the executable path from lock file
+	lock, err := manager.Lock(packageName)
+	if err != nil {
+		fmt.Printf("error reading lock file: %v\n", err)
+		cleanupAndExit(1)
 	}
-	
+
 	// Find the executable - try symlink path first, then shelf path
 	var execPath string
-	if symlinkPath, exists := lockData["symlink_path"]; exists && symlinkPath != "" {
-		execPath = symlinkPath
-	} else if shelfPath, exists := lockData["shelf_path"]; exists && shelfPath != "" {
+	if lock.SymlinkPath != "" {
+		execPath = lock.SymlinkPath
+	} else if lock.ShelfPath != "" {
 		// Construct likely executable path from shelf path and package name
-		execPath = filepath.Join(shelfPath, packageName)
+		execPath = filepath.Join(lock.ShelfPath, packageName)
 	} else {
 		fmt.Printf("error: could not determine executable path for %s\n", packageName)
-		if !wasInstalled {
-			fmt.Println("Cleaning up temporary installation...")
-			executeUninstallScript(packageName)
-		}
-		os.Exit(1)
+		cleanupAndExit(1)
 	}
-	
+
 	// Check if executable exists
 	if _, err := os.Stat(execPath); os.IsNotExist(err) {
 		fmt.Printf("error: executable not found at %s\n", execPath)
-		if !wasInstalled {
-			fmt.Println("Cleaning up temporary installation...")
-			executeUninstallScript(packageName)
-		}
-		os.Exit(1)
+		cleanupAndExit(1)
 	}
-	
+
 	// Run the package
 	fmt.Printf("Running %s...\n", packageName)
 	cmd := exec.Command(execPath, remainingArgs...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	
+
 	err = cmd.Run()
 	exitCode := 0
 	if err != nil {
@@ -3974,86 +1822,9 @@ func runPackage(args []string) {
 			exitCode = 1
 		}
 	}
-	
-	// If it was a temporary installation, clean it up
-	if !wasInstalled {
-		fmt.Println("Cleaning up temporary installation...")
-		if err := executeUninstallScript(packageName); err != nil {
-			fmt.Printf("warning: failed to clean up temporary installation: %v\n", err)
-		} else {
-			fmt.Printf("✓ Temporary installation of %s cleaned up\n", packageName)
-		}
-	}
-	
-	os.Exit(exitCode)
-}
-
-// Package metadata caching functions
-func getCacheFilePath() (string, error) {
-	packPath, err := getPackDir()
-	if err != nil {
-		return "", err
-	}
-	cacheDir := filepath.Join(packPath, "cache")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create cache directory: %v", err)
-	}
-	return filepath.Join(cacheDir, "packages.cache"), nil
-}
-
-func loadPackageCache() (*PackageCache, error) {
-	cachePath, err := getCacheFilePath()
-	if err != nil {
-		return &PackageCache{Metadata: make(map[string]CachedPackage)}, nil
-	}
-	
-	data, err := os.ReadFile(cachePath)
-	if err != nil {
-		return &PackageCache{Metadata: make(map[string]CachedPackage)}, nil
-	}
-	
-	cache := &PackageCache{Metadata: make(map[string]CachedPackage)}
-	lines := strings.Split(string(data), "\n")
-	
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		parts := strings.Split(line, "\t")
-		if len(parts) >= 6 {
-			cachedAt, _ := time.Parse(time.RFC3339, parts[5])
-			cache.Metadata[parts[0]] = CachedPackage{
-				Name:        parts[0],
-				Description: parts[1],
-				Version:     parts[2],
-				License:     parts[3],
-				Source:      parts[4],
-				CachedAt:    cachedAt,
-			}
-		}
-	}
-	
-	return cache, nil
-}
-
-func savePackageCache(cache *PackageCache) error {
-	cachePath, err := getCacheFilePath()
-	if err != nil {
-		return err
-	}
-	
-	var lines []string
-	for _, pkg := range cache.Metadata {
-		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s",
-			pkg.Name, pkg.Description, pkg.Version, pkg.License, pkg.Source, pkg.CachedAt.Format(time.RFC3339))
-		lines = append(lines, line)
-	}
-	
-	return os.WriteFile(cachePath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
-}
 
-func isCacheExpired(cachedAt time.Time) bool {
-	return time.Since(cachedAt) > cacheExpiryMinutes*time.Minute
+	// If it was a temporary installation, clean it up
+	cleanupAndExit(exitCode)
 }
 
 func showHelp() {
@@ -4068,8 +1839,21 @@ func showHelp() {
 	fmt.Println("  run <package>      run package (install temporarily if needed)")
 	fmt.Println("  shelf              list installed packages")
 	fmt.Println("  list [source]      list all available packages")
-	fmt.Println("  seek <term>        search for packages")
+	fmt.Println("  seek <term>        search for packages (cached, see list help)")
 	fmt.Println("  update             check for and install package updates")
+	fmt.Println("  outdated           list available updates without installing them")
+	fmt.Println("  hold <package>     keep a package out of updates (unhold to release)")
+	fmt.Println("  rollback <package> go back to the previous shelf generation")
+	fmt.Println("  history [package]  show what pack has done to this machine")
+	fmt.Println("  export [file]      write a packfile of the installed packages")
+	fmt.Println("  import <file>      open whatever a packfile lists that is missing")
+	fmt.Println("  sync <file>        make the shelf match a packfile (--prune to close extras)")
+	fmt.Println("  undo [id]          reverse the last (or a given) transaction")
+	fmt.Println("  files <package>    list the files a package installed")
+	fmt.Println("  log <package>      show the last install, update or close output")
+	fmt.Println("  doctor [--fix]     check ~/.pack for problems and repair them")
+	fmt.Println("  verify [package]   check installed files haven't been changed")
+	fmt.Println("  owns <path>        show which package installed a file")
 	fmt.Println("  clean              clean temporary build directories")
 	fmt.Println("  peek <package>     show package information")
 	fmt.Println("  add-source <url>   add a repository source")
@@ -4079,133 +1863,408 @@ func showHelp() {
 	fmt.Println("  info               show information about pack")
 	fmt.Println("  help               show this help information")
 	fmt.Println()
-	fmt.Println("For command-specific help, use: pack <command> help")
-	fmt.Println("Example: pack open help")
+	fmt.Println("OPTIONS:")
+	fmt.Println("  -y, --yes          answer yes to every question and pick the first source")
+	fmt.Println("  --no-review        install recipes without showing them first")
+	fmt.Println("  --source <name>    pick the source whose name contains <name> (or 'local')")
+	fmt.Println()
+	fmt.Println("without a terminal, or with PACK_NONINTERACTIVE=1, any question these don't")
+	fmt.Println("answer fails instead of waiting. recipes that fail verification are never")
+	fmt.Println("accepted unattended, --yes or not.")
+	fmt.Println()
+	fmt.Println("For command-specific help, use: pack <command> help")
+	fmt.Println("Example: pack open help")
+}
+
+func showInfo() {
+	fmt.Println("pack - your new least favorite package manager")
+	fmt.Println()
+	fmt.Println("pack is a simple package manager that uses boxlang scripts for installation.")
+	fmt.Println("each package knows how to install itself based on a readable, auditable")
+	fmt.Println("box script. its easy and fun to use, and you can package your software for it too.")
+	fmt.Println()
+	fmt.Println("it works as such:")
+	fmt.Println("- download .box script that contain installation instructions from any git repo")
+	fmt.Println("- shows you the script before running it and lets you edit; no surprises")
+	fmt.Println("- verifies scripts with ed25519 signatures for security")
+	fmt.Println("- installs binaries to ~/.pack/shelf/ with symlinks to ~/.local/bin/")
+	fmt.Println("- tracks installations with lockfiles for clean removal")
+	fmt.Println()
+	fmt.Println("pack is free software under the gnu general public license v3+")
+	fmt.Println("copyright © 2025 shrub industries")
+	fmt.Println()
+	fmt.Println("run 'pack help' for commands or visit github.com/shrub4thedub/pack")
+}
+
+// showOpenHelp displays help for the open command
+func showOpenHelp() {
+	fmt.Println("pack open - install one or more packages")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack open <package>[@ref] [--verbose]")
+	fmt.Println("  pack open <package1> <package2> <package3> ... [--verbose]")
+	fmt.Println("  pack open help")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --verbose, -v    show all installation output instead of progress bar")
+	fmt.Println("  --jobs, -j <n>   run up to n recipes at once (output is interleaved)")
+	fmt.Println("  --yes, -y        don't ask, install the plan and pick the first source")
+	fmt.Println("  --no-review      skip showing the recipe")
+	fmt.Println("  --source <name>  pick this source when several have the package")
+	fmt.Println("  --dry-run        resolve, download and verify everything, then show")
+	fmt.Println("                   the plan: sources, signatures, dependencies, links")
+	fmt.Println("                   that would be overwritten and lock changes")
+	fmt.Println("  --json           print the dry run as JSON")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  downloads and installs packages from configured sources.")
+	fmt.Println("  missing dependencies from a recipe's deps and build-deps fields are")
+	fmt.Println("  installed first. when more than one package is going in, the full")
+	fmt.Println("  plan is shown for confirmation, then packages are installed in")
+	fmt.Println("  dependency order; anything depending on a failed package is skipped.")
+	fmt.Println("  <package>@<tag|commit> pins a git source to that ref instead of the")
+	fmt.Println("  recipe's src-ref; the script gets it as PACK_SRC_REF and pack update")
+	fmt.Println("  keeps the package there.")
+	fmt.Println("  if an installed package conflicts with the new one or provides the")
+	fmt.Println("  same binary, you'll be asked whether to replace it.")
+	fmt.Println("  if multiple sources have a package, you'll be prompted to choose.")
+	fmt.Println("  by default shows a progress bar, use --verbose to see all output.")
+	fmt.Println()
+	fmt.Println("  the installation process:")
+	fmt.Println("  1. finds each package in available sources")
+	fmt.Println("  2. downloads and verifies every recipe at once")
+	fmt.Println("  3. shows each recipe for review, all before anything runs")
+	fmt.Println("  4. executes the installation scripts, one at a time unless --jobs")
+	fmt.Println("  5. creates a lockfile for tracking")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack open vim                    # Install vim text editor")
+	fmt.Println("  pack open pfetch --verbose       # Install pfetch with verbose output")
+	fmt.Println("  pack open vim glow pfetch        # Install multiple packages")
+	fmt.Println("  pack open vim glow pfetch -j 4   # ...building up to 4 at once")
+	fmt.Println("  pack open edith@v0.3.1           # Install edith at tag v0.3.1")
+	fmt.Println("  pack open 9dir boxlang python    # Install development tools")
+}
+
+// showCloseHelp displays help for the close command
+func showCloseHelp() {
+	fmt.Println("pack close - uninstall a package")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack close <package> [--dry-run [--json]]")
+	fmt.Println("  pack close help")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --dry-run   list the links, files and lock that would be removed")
+	fmt.Println("  --json      print the dry run as JSON")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  uninstalls a previously installed package using the universal")
+	fmt.Println("  uninstaller with information from the package's lock file.")
+	fmt.Println("  warns when the package is the only provider of something other")
+	fmt.Println("  installed packages depend on.")
+	fmt.Println()
+	fmt.Println("  the uninstallation process:")
+	fmt.Println("  1. reads the package lock file")
+	fmt.Println("  2. runs the recipe's uninstall function, if it has one")
+	fmt.Println("  3. removes the files, symlinks and shelf directory recorded at")
+	fmt.Println("     install time, leaving anything changed since then")
+	fmt.Println("  4. preserves configuration files")
+	fmt.Println("  5. removes the lockfile, unless something could not be removed")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack close vim     # Uninstall vim")
+	fmt.Println("  pack close pfetch  # Uninstall pfetch")
+}
+
+// showListHelp displays help for the list command
+func showListHelp() {
+	fmt.Println("pack list - list installed packages")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack list")
+	fmt.Println("  pack list help")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  shows all installed packages with their version information,")
+	fmt.Println("  source repository, and installation date.")
+	fmt.Println()
+	fmt.Println("  columns displayed:")
+	fmt.Println("  - PACKAGE: Package name")
+	fmt.Println("  - VERSION: Source version (git commit or content hash)")
+	fmt.Println("  - SOURCE: Origin URL of the package")
+	fmt.Println("  - INSTALLED: Installation date")
+	fmt.Println()
+	fmt.Println("  --json or --format=tsv prints every lock field instead, with")
+	fmt.Println("  unreadable locks reported on stderr.")
+	fmt.Println()
+	fmt.Println("EXAMPLE:")
+	fmt.Println("  pack list")
+}
+
+// showUpdateHelp displays help for the update command
+func showUpdateHelp() {
+	fmt.Println("pack update - check for and install package updates")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack update [--include-held] [--check|--dry-run] [--json|--format=tsv]")
+	fmt.Println("  pack update help")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --include-held   update held packages too")
+	fmt.Println("  --check          only list updates, the same as pack outdated")
+	fmt.Println("  --dry-run        fetch and verify each update's recipe and show what")
+	fmt.Println("                   would change, without running anything (text or json)")
+	fmt.Println("  --json           print each update and its status (available, held,")
+	fmt.Println("                   updated or failed) as JSON, progress goes to stderr")
+	fmt.Println("  --format=tsv     the same as tab separated values")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  scans all installed packages for available updates by comparing")
+	fmt.Println("  current versions with remote sources. Updates use the same")
+	fmt.Println("  source repository that was used for original installation.")
+	fmt.Println()
+	fmt.Println("  the update process:")
+	fmt.Println("  1. checks all packages for updates")
+	fmt.Println("  2. shows available updates")
+	fmt.Println("  3. asks for confirmation")
+	fmt.Println("  4. updates all confirmed packages")
+	fmt.Println()
+	fmt.Println("  update detection:")
+	fmt.Println("  - git packages: compares commit hashes")
+	fmt.Println("  - recipe changes: compares recipe content")
+	fmt.Println()
+	fmt.Println("  held packages are listed but not updated, and pack and boxlang are")
+	fmt.Println("  not auto-updated while held.")
+	fmt.Println()
+	fmt.Println("EXAMPLE:")
+	fmt.Println("  pack update")
+}
+
+// showVerifyHelp displays help for the verify command
+func showVerifyHelp() {
+	fmt.Println("pack verify - check installed files against what was installed")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack verify [package...]")
+	fmt.Println("  pack verify help")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  re-hashes every file in a package's manifest (see pack files help)")
+	fmt.Println("  and reports files that were modified or are missing, plus extra")
+	fmt.Println("  files on its shelf that pack didn't put there. with no package,")
+	fmt.Println("  checks everything installed. exits 1 on any mismatch, so it can")
+	fmt.Println("  run from a timer.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack verify")
+	fmt.Println("  pack verify edith")
+}
+
+// showDoctorHelp displays help for the doctor command
+func showDoctorHelp() {
+	fmt.Println("pack doctor - check pack's state for problems")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack doctor [--fix]")
+	fmt.Println("  pack doctor help")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --fix    repair what can be repaired safely")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  looks through ~/.pack and ~/.local/bin and reports, by category:")
+	fmt.Println("  - locks that can't be read or whose shelf directory is gone")
+	fmt.Println("  - shelf directories with no lock (--fix removes them)")
+	fmt.Println("  - dangling symlinks into ~/.pack (--fix removes them)")
+	fmt.Println("  - pack-* temp directories over an hour old (--fix removes them)")
+	fmt.Println("  - ~/.local/bin missing from PATH")
+	fmt.Println("  - no box interpreter (--fix bootstraps it)")
+	fmt.Println("  - a sources.box that can't be read")
+	fmt.Println("  - cached keys that expired or are corrupt (--fix drops them so")
+	fmt.Println("    they are fetched again)")
+	fmt.Println("  exits non-zero while any problem is left.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack doctor")
+	fmt.Println("  pack doctor --fix")
+}
+
+// showFilesHelp displays help for the files and owns commands
+func showFilesHelp() {
+	fmt.Println("pack files / pack owns - what did a package install")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack files <package>")
+	fmt.Println("  pack owns <path>")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  while a recipe runs, pack watches the package's shelf, ~/.local/bin")
+	fmt.Println("  and ~/.config/<package>, and records every file, symlink and")
+	fmt.Println("  directory it created or changed (files with their sha256) in")
+	fmt.Println("  ~/.pack/locks/<package>/files.box.")
+	fmt.Println("  files lists that manifest, owns finds the package a path came from.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack files edith")
+	fmt.Println("  pack owns ~/.local/bin/edith")
+}
+
+// showLogHelp displays help for the log command
+func showLogHelp() {
+	fmt.Println("pack log - see what a recipe printed")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack log <package> [--last|--list]")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  every time box runs a recipe for open, update or close, its output")
+	fmt.Println("  is also written to ~/.pack/logs/<package>/<time>.log, with the")
+	fmt.Println("  recipe's sha256, the exit status and how long it took kept in")
+	fmt.Println("  <time>.box next to it. the last 10 are kept per package, including")
+	fmt.Println("  after it is closed.")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --last    print the newest log (the default)")
+	fmt.Println("  --list    list every kept log with its status")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack log edith           # What happened last time")
+	fmt.Println("  pack log edith --list    # Every kept run")
+}
+
+// showRollbackHelp displays help for the rollback command
+func showRollbackHelp() {
+	fmt.Println("pack rollback - go back to an earlier install of a package")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  pack rollback <package> [generation]")
+	fmt.Println("  pack rollback help")
+	fmt.Println()
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  recipes that install into $PACK_STAGE get a new generation under")
+	fmt.Println("  ~/.pack/shelf/<package>/ on every open and update, and the last few")
+	fmt.Println("  are kept along with their lock files. rollback re-points the links")
+	fmt.Println("  in ~/.local/bin at an older generation and restores its lock.")
+	fmt.Println("  without a generation number it goes back one.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack rollback edith      # Back to the generation before this one")
+	fmt.Println("  pack rollback edith 2    # Back to generation 2")
 }
 
-func showInfo() {
-	fmt.Println("pack - your new least favorite package manager")
+// showPackfileHelp displays help for the export, import and sync commands
+func showPackfileHelp() {
+	fmt.Println("pack export / import / sync - share a set of packages")
 	fmt.Println()
-	fmt.Println("pack is a simple package manager that uses boxlang scripts for installation.")
-	fmt.Println("each package knows how to install itself based on a readable, auditable")
-	fmt.Println("box script. its easy and fun to use, and you can package your software for it too.")
+	fmt.Println("USAGE:")
+	fmt.Println("  pack export [file]")
+	fmt.Println("  pack import <file> [-j N]")
+	fmt.Println("  pack sync <file> [--prune] [-j N]")
 	fmt.Println()
-	fmt.Println("it works as such:")
-	fmt.Println("- download .box script that contain installation instructions from any git repo")
-	fmt.Println("- shows you the script before running it and lets you edit; no surprises")
-	fmt.Println("- verifies scripts with ed25519 signatures for security")
-	fmt.Println("- installs binaries to ~/.pack/shelf/ with symlinks to ~/.local/bin/")
-	fmt.Println("- tracks installations with lockfiles for clean removal")
+	fmt.Println("DESCRIPTION:")
+	fmt.Println("  export writes a packfile (stdout without a file): one [data -c package]")
+	fmt.Println("  block per installed package with its name, source repo, pin and hold,")
+	fmt.Println("  and reason dependency for packages only there for another one.")
 	fmt.Println()
-	fmt.Println("pack is free software under the gnu general public license v3+")
-	fmt.Println("copyright © 2025 shrub industries")
+	fmt.Println("  import opens every listed package that isn't installed, from the repo")
+	fmt.Println("  and at the pin the packfile gives. sync does that too, reopens packages")
+	fmt.Println("  whose pin or repo differs and sets or releases holds; with --prune it")
+	fmt.Println("  also closes installed packages the packfile doesn't list, except pack")
+	fmt.Println("  and boxlang.")
+	fmt.Println("  repos must already be configured with pack add-source.")
 	fmt.Println()
-	fmt.Println("run 'pack help' for commands or visit github.com/shrub4thedub/pack")
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack export team.box          # Write the packfile")
+	fmt.Println("  pack import team.box          # Open what's missing")
+	fmt.Println("  pack sync team.box --prune    # Match it exactly")
 }
 
-// showOpenHelp displays help for the open command
-func showOpenHelp() {
-	fmt.Println("pack open - install one or more packages")
+// showHistoryHelp displays help for the history command
+func showHistoryHelp() {
+	fmt.Println("pack history - see what pack has done")
 	fmt.Println()
 	fmt.Println("USAGE:")
-	fmt.Println("  pack open <package> [--verbose]")
-	fmt.Println("  pack open <package1> <package2> <package3> ... [--verbose]")
-	fmt.Println("  pack open help")
-	fmt.Println()
-	fmt.Println("OPTIONS:")
-	fmt.Println("  --verbose, -v    show all installation output instead of progress bar")
+	fmt.Println("  pack history              # Every transaction")
+	fmt.Println("  pack history <package>    # Transactions that touched a package")
+	fmt.Println("  pack history <id>         # Every lock field one transaction changed")
 	fmt.Println()
 	fmt.Println("DESCRIPTION:")
-	fmt.Println("  downloads and installs packages from configured sources.")
-	fmt.Println("  when installing multiple packages, shows confirmation prompt first,")
-	fmt.Println("  then installs them one by one.")
-	fmt.Println("  if multiple sources have a package, you'll be prompted to choose.")
-	fmt.Println("  by default shows a progress bar, use --verbose to see all output.")
-	fmt.Println()
-	fmt.Println("  the installation process:")
-	fmt.Println("  1. finds the package in available sources")
-	fmt.Println("  2. downloads and verifies the recipe")
-	fmt.Println("  3. shows the recipe for review")
-	fmt.Println("  4. executes the installation script")
-	fmt.Println("  5. creates a lockfile for tracking")
+	fmt.Println("  every open, close, update, rollback, hold, add-source, doctor --fix")
+	fmt.Println("  and undo that changes something is journaled under ~/.pack/history/")
+	fmt.Println("  with who ran it, when, and a copy of each lock (and sources.box)")
+	fmt.Println("  before and after. ✗ marks a transaction that failed part way.")
 	fmt.Println()
 	fmt.Println("EXAMPLES:")
-	fmt.Println("  pack open vim                    # Install vim text editor")
-	fmt.Println("  pack open pfetch --verbose       # Install pfetch with verbose output")
-	fmt.Println("  pack open vim glow pfetch        # Install multiple packages")
-	fmt.Println("  pack open 9dir boxlang python    # Install development tools")
+	fmt.Println("  pack history edith")
+	fmt.Println("  pack history 12")
+	fmt.Println("  pack undo 12")
 }
 
-// showCloseHelp displays help for the close command
-func showCloseHelp() {
-	fmt.Println("pack close - uninstall a package")
+// showUndoHelp displays help for the undo command
+func showUndoHelp() {
+	fmt.Println("pack undo - reverse a transaction from the history")
 	fmt.Println()
 	fmt.Println("USAGE:")
-	fmt.Println("  pack close <package>")
-	fmt.Println("  pack close help")
+	fmt.Println("  pack undo [id]")
 	fmt.Println()
 	fmt.Println("DESCRIPTION:")
-	fmt.Println("  uninstalls a previously installed package using the universal")
-	fmt.Println("  uninstaller with information from the package's lock file.")
-	fmt.Println()
-	fmt.Println("  the uninstallation process:")
-	fmt.Println("  1. reads the package lock file")
-	fmt.Println("  2. removes the installed binary")
-	fmt.Println("  3. preserves configuration files")
-	fmt.Println("  4. removes the lockfile")
+	fmt.Println("  puts everything a transaction changed back the way it was, the last")
+	fmt.Println("  one unless an id from pack history is given: packages it opened are")
+	fmt.Println("  closed, ones it closed are reopened from their source (at their pin")
+	fmt.Println("  if they had one), updates and rollbacks go back to the earlier shelf")
+	fmt.Println("  generation, and holds and sources.box are restored.")
+	fmt.Println("  anything that has changed again since, or whose earlier generation")
+	fmt.Println("  is no longer on the shelf, is left alone and reported. the undo is a")
+	fmt.Println("  transaction too, so undoing it redoes the original.")
 	fmt.Println()
 	fmt.Println("EXAMPLES:")
-	fmt.Println("  pack close vim     # Uninstall vim")
-	fmt.Println("  pack close pfetch  # Uninstall pfetch")
+	fmt.Println("  pack undo         # Reverse the last transaction")
+	fmt.Println("  pack undo 12      # Reverse transaction 12")
 }
 
-// showListHelp displays help for the list command
-func showListHelp() {
-	fmt.Println("pack list - list installed packages")
+// showHoldHelp displays help for the hold and unhold commands
+func showHoldHelp() {
+	fmt.Println("pack hold - keep packages out of updates")
 	fmt.Println()
 	fmt.Println("USAGE:")
-	fmt.Println("  pack list")
-	fmt.Println("  pack list help")
+	fmt.Println("  pack hold <package> [package...]")
+	fmt.Println("  pack unhold <package> [package...]")
 	fmt.Println()
 	fmt.Println("DESCRIPTION:")
-	fmt.Println("  shows all installed packages with their version information,")
-	fmt.Println("  source repository, and installation date.")
+	fmt.Println("  a held package stays at the version it is at. pack update shows it")
+	fmt.Println("  as held and skips it unless --include-held is given, and pack and")
+	fmt.Println("  boxlang are not auto-updated while held. unhold releases it.")
 	fmt.Println()
-	fmt.Println("  columns displayed:")
-	fmt.Println("  - PACKAGE: Package name")
-	fmt.Println("  - VERSION: Source version (git commit or content hash)")
-	fmt.Println("  - SOURCE: Origin URL of the package")
-	fmt.Println("  - INSTALLED: Installation date")
-	fmt.Println()
-	fmt.Println("EXAMPLE:")
-	fmt.Println("  pack list")
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  pack hold boxlang      # Keep boxlang where it is")
+	fmt.Println("  pack unhold boxlang    # Let it update again")
 }
 
-// showUpdateHelp displays help for the update command
-func showUpdateHelp() {
-	fmt.Println("pack update - check for and install package updates")
+// showOutdatedHelp displays help for the outdated command
+func showOutdatedHelp() {
+	fmt.Println("pack outdated - list available updates without installing them")
 	fmt.Println()
 	fmt.Println("USAGE:")
-	fmt.Println("  pack update")
-	fmt.Println("  pack update help")
+	fmt.Println("  pack outdated [--include-held] [--json|--format=tsv]")
+	fmt.Println("  pack update --check")
+	fmt.Println("  pack outdated help")
 	fmt.Println()
 	fmt.Println("DESCRIPTION:")
-	fmt.Println("  scans all installed packages for available updates by comparing")
-	fmt.Println("  current versions with remote sources. Updates use the same")
-	fmt.Println("  source repository that was used for original installation.")
+	fmt.Println("  checks every installed package the same way pack update does and")
+	fmt.Println("  prints the current and new version and whether the recipe, the")
+	fmt.Println("  source or both changed. nothing is changed: pack and boxlang")
+	fmt.Println("  aren't updated first and keys aren't refreshed.")
 	fmt.Println()
-	fmt.Println("  the update process:")
-	fmt.Println("  1. checks all packages for updates")
-	fmt.Println("  2. shows available updates")
-	fmt.Println("  3. asks for confirmation")
-	fmt.Println("  4. updates all confirmed packages")
+	fmt.Println("  held packages are listed but only count with --include-held.")
 	fmt.Println()
-	fmt.Println("  update detection:")
-	fmt.Println("  - git packages: compares commit hashes")
-	fmt.Println("  - recipe changes: compares recipe content")
+	fmt.Println("EXIT STATUS:")
+	fmt.Println("  0   everything is up to date")
+	fmt.Println("  10  updates are available")
+	fmt.Println("  1   something couldn't be checked")
 	fmt.Println()
 	fmt.Println("EXAMPLE:")
-	fmt.Println("  pack update")
+	fmt.Println("  pack outdated || [ $? -eq 10 ] && echo time to update")
 }
 
 // showPeekHelp displays help for the peek command
@@ -4213,13 +2272,20 @@ func showPeekHelp() {
 	fmt.Println("pack peek - show package information")
 	fmt.Println()
 	fmt.Println("USAGE:")
-	fmt.Println("  pack peek <package>")
+	fmt.Println("  pack peek <package> [--refresh|--offline] [--json|--format=tsv]")
 	fmt.Println("  pack peek help")
 	fmt.Println()
 	fmt.Println("DESCRIPTION:")
 	fmt.Println("  downloads and displays information about a package without")
 	fmt.Println("  installing it. shows package metadata from the recipe.")
 	fmt.Println()
+	fmt.Println("  recipes are cached and reused while they match the source's")
+	fmt.Println("  index. --refresh rechecks the index now, --offline only")
+	fmt.Println("  uses what is already cached.")
+	fmt.Println()
+	fmt.Println("  --json prints the package's fields as an object and --format=tsv")
+	fmt.Println("  as key/value lines, usual fields first and the rest sorted.")
+	fmt.Println()
 	fmt.Println("  information displayed:")
 	fmt.Println("  - package name and description")
 	fmt.Println("  - version and supported operating systems")
@@ -4236,37 +2302,40 @@ func showAddSourceHelp() {
 	fmt.Println("pack add-source - add a repository source")
 	fmt.Println()
 	fmt.Println("USAGE:")
-	fmt.Println("  pack add-source <url>")
+	fmt.Println("  pack add-source <url> [--provider <name>] [--branch <name>]")
 	fmt.Println("  pack add-source help")
 	fmt.Println()
 	fmt.Println("DESCRIPTION:")
 	fmt.Println("  adds a new repository source for package discovery.")
 	fmt.Println("  sources are searched when installing packages.")
 	fmt.Println()
-	fmt.Println("  supported source types:")
-	fmt.Println("  - github repositories")
-	fmt.Println("  - other git repositories with web access")
+	fmt.Println("  supported providers:")
+	fmt.Println("  - github          github.com repositories")
+	fmt.Println("  - gitlab          gitlab.com and self-hosted gitlab")
+	fmt.Println("  - gitea, forgejo  codeberg.org and self-hosted instances")
+	fmt.Println("  - http            any static web server serving the repo files")
+	fmt.Println("  - file            a repository directory on this machine (file://)")
+	fmt.Println()
+	fmt.Println("  the provider is guessed from the url when not given, and")
+	fmt.Println("  the branch defaults to main. both are saved in sources.box.")
 	fmt.Println()
 	fmt.Println("EXAMPLES:")
 	fmt.Println("  pack add-source https://github.com/user/pack-repo")
 	fmt.Println("  pack add-source https://gitlab.com/user/packages")
+	fmt.Println("  pack add-source https://git.example.org/user/packages --provider forgejo")
+	fmt.Println("  pack add-source file:///home/user/pack-repo")
 }
 
 // generateKeys generates a new Ed25519 key pair for recipe signing
 func generateKeys() {
 	fmt.Println("Generating Ed25519 key pair for recipe signing...")
-	
-	// Generate key pair
-	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+	publicB64, privateB64, err := pack.GenerateKeyPair()
 	if err != nil {
 		fmt.Printf("Failed to generate keys: %v\n", err)
 		os.Exit(1)
 	}
-	
-	// Base64 encode keys
-	publicB64 := base64.StdEncoding.EncodeToString(publicKey)
-	privateB64 := base64.StdEncoding.EncodeToString(privateKey)
-	
+
 	fmt.Println()
 	fmt.Println("🔑 Key pair generated successfully!")
 	fmt.Println()
@@ -4282,86 +2351,23 @@ func generateKeys() {
 // signFiles signs recipe files with the provided private key
 func signFiles(privateKeyB64, target string) {
 	fmt.Printf("Signing recipes with Ed25519...\n")
-	
-	// Decode private key
-	privateKeyBytes, err := base64.StdEncoding.DecodeString(privateKeyB64)
+
+	privateKey, err := pack.ParsePrivateKey(privateKeyB64)
 	if err != nil {
-		fmt.Printf("Failed to decode private key: %v\n", err)
+		fmt.Printf("%v\n", err)
 		os.Exit(1)
 	}
-	
-	if len(privateKeyBytes) != ed25519.PrivateKeySize {
-		fmt.Printf("Invalid private key size: expected %d, got %d\n", ed25519.PrivateKeySize, len(privateKeyBytes))
-		os.Exit(1)
+
+	signed, err := pack.SignPath(privateKey, target)
+	for _, path := range signed {
+		fmt.Printf("Signed: %s -> %s\n", path, path+".sig")
 	}
-	
-	privateKey := ed25519.PrivateKey(privateKeyBytes)
-	
-	// Check if target is directory or file
-	stat, err := os.Stat(target)
 	if err != nil {
-		fmt.Printf("Failed to access target: %v\n", err)
+		fmt.Printf("Failed to sign files: %v\n", err)
 		os.Exit(1)
 	}
-	
-	var signedCount int
-	
-	if stat.IsDir() {
-		// Sign all .box files in directory
-		err = filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			
-			if strings.HasSuffix(path, ".box") {
-				if err := signFile(privateKey, path); err != nil {
-					fmt.Printf("Failed to sign %s: %v\n", path, err)
-					return err
-				}
-				signedCount++
-			}
-			
-			return nil
-		})
-		
-		if err != nil {
-			fmt.Printf("Failed to sign files: %v\n", err)
-			os.Exit(1)
-		}
-	} else {
-		// Sign single file
-		if err := signFile(privateKey, target); err != nil {
-			fmt.Printf("Failed to sign file: %v\n", err)
-			os.Exit(1)
-		}
-		signedCount++
-	}
-	
-	fmt.Printf("✓ Successfully signed %d file(s)\n", signedCount)
-}
 
-// signFile signs a single file with Ed25519
-func signFile(privateKey ed25519.PrivateKey, filePath string) error {
-	// Read file content
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read %s: %v", filePath, err)
-	}
-	
-	// Sign content
-	signature := ed25519.Sign(privateKey, content)
-	
-	// Base64 encode signature
-	signatureB64 := base64.StdEncoding.EncodeToString(signature)
-	
-	// Write signature file
-	sigPath := filePath + ".sig"
-	if err := os.WriteFile(sigPath, []byte(signatureB64), 0644); err != nil {
-		return fmt.Errorf("failed to write signature %s: %v", sigPath, err)
-	}
-	
-	fmt.Printf("Signed: %s -> %s\n", filePath, sigPath)
-	return nil
+	fmt.Printf("✓ Successfully signed %d file(s)\n", len(signed))
 }
 
 // cleanTempDirectory cleans the ~/.pack/tmp directory
@@ -4382,199 +2388,16 @@ func cleanTempDirectory(args []string) {
 		return
 	}
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Printf("error getting home directory: %v\n", err)
-		os.Exit(1)
-	}
+	fmt.Printf("cleaning temporary directory: %s\n", filepath.Join(manager.Root(), "tmp"))
 
-	tmpDir := filepath.Join(homeDir, packDir, "tmp")
-	
-	// Check if tmp directory exists
-	if _, err := os.Stat(tmpDir); os.IsNotExist(err) {
-		fmt.Println("temporary directory is already clean")
-		return
+	removedCount, errs := manager.CleanTemp()
+	for _, err := range errs {
+		fmt.Printf("warning: %v\n", err)
 	}
 
-	fmt.Printf("cleaning temporary directory: %s\n", tmpDir)
-	
-	// Remove everything in the tmp directory
-	entries, err := os.ReadDir(tmpDir)
-	if err != nil {
-		fmt.Printf("error reading tmp directory: %v\n", err)
-		os.Exit(1)
-	}
-	
-	removedCount := 0
-	for _, entry := range entries {
-		entryPath := filepath.Join(tmpDir, entry.Name())
-		if err := os.RemoveAll(entryPath); err != nil {
-			fmt.Printf("warning: failed to remove %s: %v\n", entryPath, err)
-		} else {
-			removedCount++
-		}
-	}
-	
 	fmt.Printf("✓ cleaned %d items from temporary directory\n", removedCount)
 }
 
-// checkAndUpdateCorePackages silently checks and updates pack/boxlang before package installation
-func checkAndUpdateCorePackages() {
-	// Skip if we checked recently (within last 2 hours)
-	if shouldSkipCoreCheck() {
-		return
-	}
-	
-	corePackages := []string{"pack", "boxlang"}
-	
-	for _, packageName := range corePackages {
-		if hasUpdate, err := checkCorePackageForUpdate(packageName); err == nil && hasUpdate {
-			fmt.Printf("🔄 updating %s...\n", packageName)
-			if err := updatePackageFromOriginalSource(packageName); err != nil {
-				fmt.Printf("warning: failed to auto-update %s: %v\n", packageName, err)
-			} else {
-				fmt.Printf("✓ %s updated successfully\n", packageName)
-			}
-		}
-	}
-	
-	// Update timestamp after check
-	updateCoreCheckTimestamp()
-}
-
-func shouldSkipCoreCheck() bool {
-	packPath, err := getPackDir()
-	if err != nil {
-		return false
-	}
-	
-	timestampFile := filepath.Join(packPath, "cache", "core_check_timestamp")
-	data, err := os.ReadFile(timestampFile)
-	if err != nil {
-		return false
-	}
-	
-	timestamp, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
-	if err != nil {
-		return false
-	}
-	
-	// Skip if checked within last core check interval
-	return time.Since(timestamp) < coreCheckIntervalHours*time.Hour
-}
-
-func updateCoreCheckTimestamp() {
-	packPath, err := getPackDir()
-	if err != nil {
-		return
-	}
-	
-	cacheDir := filepath.Join(packPath, "cache")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return
-	}
-	
-	timestampFile := filepath.Join(cacheDir, "core_check_timestamp")
-	timestamp := time.Now().Format(time.RFC3339)
-	os.WriteFile(timestampFile, []byte(timestamp), 0644)
-}
-
-// updateCorePackagesFirst prioritizes pack and boxlang updates during pack update
-func updateCorePackagesFirst() {
-	fmt.Println("checking for core package updates...")
-	
-	corePackages := []string{"pack", "boxlang"}
-	coreUpdatesNeeded := false
-	
-	for _, packageName := range corePackages {
-		if hasUpdate, err := checkCorePackageForUpdate(packageName); err == nil && hasUpdate {
-			coreUpdatesNeeded = true
-			fmt.Printf("🔄 updating %s...\n", packageName)
-			if err := updatePackageFromOriginalSource(packageName); err != nil {
-				fmt.Printf("error updating %s: %v\n", packageName, err)
-			} else {
-				fmt.Printf("✓ %s updated successfully\n", packageName)
-			}
-		}
-	}
-	
-	if coreUpdatesNeeded {
-		fmt.Println("core package updates complete, continuing with other packages...")
-	}
-}
-
-// checkCorePackageForUpdate checks if a core package (pack/boxlang) has updates available
-func checkCorePackageForUpdate(packageName string) (bool, error) {
-	lockPath, err := getLockFilePath(packageName)
-	if err != nil {
-		return false, err
-	}
-	
-	if _, err := os.Stat(lockPath); os.IsNotExist(err) {
-		return false, nil // Package not installed
-	}
-	
-	// Read lock file to get current info
-	lockData, err := readLockFileToMap(lockPath)
-	if err != nil {
-		return false, err
-	}
-	
-	// Use existing update checking logic
-	_, hasUpdate, err := checkPackageForUpdate(packageName, lockData)
-	return hasUpdate, err
-}
-
-// readLockFileToMap reads a lock file and returns it as a map
-func readLockFileToMap(lockPath string) (map[string]string, error) {
-	content, err := os.ReadFile(lockPath)
-	if err != nil {
-		return nil, err
-	}
-	
-	result := make(map[string]string)
-	lines := strings.Split(string(content), "\n")
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			result[key] = value
-		}
-	}
-	
-	return result, nil
-}
-
-
-
-// isPackageUpToDate checks if a package is installed and up to date
-func isPackageUpToDate(packageName string) bool {
-	lockPath, err := getLockFilePath(packageName)
-	if err != nil {
-		return false
-	}
-
-	if _, err := os.Stat(lockPath); os.IsNotExist(err) {
-		return false // Package not installed
-	}
-
-	// Use existing update check logic
-	lockData, err := readLockFileToMap(lockPath)
-	if err != nil {
-		return false
-	}
-
-	_, hasUpdate, err := checkPackageForUpdate(packageName, lockData)
-	return err == nil && !hasUpdate
-}
-
 // Repository management commands
 
 // handleRepoCommand handles the pack repo subcommands
@@ -4592,11 +2415,13 @@ func handleRepoCommand(args []string) {
 		repoKeygen(args[1:])
 	case "sign":
 		repoSign(args[1:])
+	case "index":
+		repoIndex(args[1:])
 	case "help":
 		showRepoHelp()
 	default:
 		fmt.Printf("error: unknown repo subcommand '%s'\n", subcommand)
-		fmt.Println("usage: pack repo <create|keygen|sign>")
+		fmt.Println("usage: pack repo <create|keygen|sign|index>")
 		os.Exit(1)
 	}
 }
@@ -4609,6 +2434,7 @@ func showRepoHelp() {
 	fmt.Println("  pack repo create   create a new pack repository")
 	fmt.Println("  pack repo keygen   generate keys for current repository")
 	fmt.Println("  pack repo sign     sign all packages in current repository")
+	fmt.Println("  pack repo index    write the signed package index")
 	fmt.Println("  pack repo help     show this help")
 	fmt.Println()
 	fmt.Println("DESCRIPTION:")
@@ -4638,95 +2464,28 @@ func repoCreate(args []string) {
 
 	fmt.Println("Creating new pack repository...")
 
-	// Check if we're already in a pack repo
-	if _, err := os.Stat("keys"); err == nil {
-		fmt.Println("error: keys/ directory already exists - already a pack repository?")
-		os.Exit(1)
-	}
-
-	// Create directory structure
-	dirs := []string{"keys", "lang", "utils", "toys", "misc"}
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Printf("error creating directory %s: %v\n", dir, err)
-			os.Exit(1)
-		}
-	}
-
-	// Create README.md
-	readmeContent := `# Pack Repository
-
-This is a pack repository containing packages that can be installed with the pack package manager.
-
-## Repository Structure
-
-- ` + "`lang/`" + ` - Programming languages and development tools
-- ` + "`utils/`" + ` - System utilities and tools
-- ` + "`toys/`" + ` - Fun and recreational packages
-- ` + "`misc/`" + ` - Miscellaneous packages
-- ` + "`keys/`" + ` - Signing keys for package verification
-
-Each package is stored in ` + "`section/package-name/package-name.box`" + ` with a corresponding ` + "`.box.sig`" + ` signature file.
-
-## Adding Packages
-
-1. Create a directory: ` + "`section/package-name/`" + `
-2. Add your recipe: ` + "`package-name.box`" + `
-3. Sign the package: ` + "`pack repo sign`" + `
-
-## Repository Management
-
-- ` + "`pack repo create`" + ` - Create new repository
-- ` + "`pack repo keygen`" + ` - Generate signing keys
-- ` + "`pack repo sign`" + ` - Sign all packages
-
-## Usage
-
-Add this repository to pack:
-` + "```bash" + `
-pack add-source https://github.com/username/repo-name
-` + "```" + `
-
-Install packages:
-` + "```bash" + `
-pack open package-name
-` + "```" + `
-`
-
-	if err := os.WriteFile("README.md", []byte(readmeContent), 0644); err != nil {
-		fmt.Printf("error creating README.md: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Create .gitignore
-	gitignoreContent := `# Pack repository gitignore
-*.tmp
-.pack/
-*.bak
-*~
-.DS_Store
-`
-
-	if err := os.WriteFile(".gitignore", []byte(gitignoreContent), 0644); err != nil {
-		fmt.Printf("error creating .gitignore: %v\n", err)
+	gitInitialized, err := pack.CreateRepo(".")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Initialize git repository
-	if err := exec.Command("git", "init").Run(); err != nil {
+	if !gitInitialized {
 		fmt.Println("warning: failed to initialize git repository")
 	}
 
 	fmt.Println("✓ Repository structure created")
 	fmt.Println("✓ README.md created")
 	fmt.Println("✓ .gitignore created")
-	fmt.Println("✓ Git repository initialized")
+	if gitInitialized {
+		fmt.Println("✓ Git repository initialized")
+	}
 	fmt.Println()
 	fmt.Println("Next steps:")
 	fmt.Println("1. Generate signing keys: pack repo keygen")
 	fmt.Println("2. Add your packages to the appropriate sections")
 	fmt.Println("3. Sign packages: pack repo sign")
-	fmt.Println("4. Commit and push to a git hosting service")
+	fmt.Println("4. Write the package index: pack repo index")
+	fmt.Println("5. Commit and push to a git hosting service")
 }
 
 // repoKeygen generates keys for the current repository
@@ -4745,7 +2504,7 @@ func repoKeygen(args []string) {
 	}
 
 	// Check if we're in a pack repository
-	if _, err := os.Stat("keys"); os.IsNotExist(err) {
+	if !pack.IsRepo(".") {
 		fmt.Println("error: not in a pack repository (no keys/ directory found)")
 		fmt.Println("run 'pack repo create' to create a new repository")
 		os.Exit(1)
@@ -4753,44 +2512,12 @@ func repoKeygen(args []string) {
 
 	fmt.Println("Generating Ed25519 key pair for repository...")
 
-	// Generate key pair
-	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	privateB64, err := pack.RepoKeygen(".")
 	if err != nil {
-		fmt.Printf("Failed to generate keys: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Base64 encode keys
-	publicB64 := base64.StdEncoding.EncodeToString(publicKey)
-	privateB64 := base64.StdEncoding.EncodeToString(privateKey)
-
-	// Create keys/pack.box with proper structure
-	now := time.Now().Unix()
-	expires := time.Now().Add(2 * 365 * 24 * time.Hour).Unix() // 2 years
-
-	keyContent := fmt.Sprintf(`[data -c keyinfo]
-  version     2
-  issued_at   %d
-  expires_at  %d
-  algorithm   ed25519
-end
-
-[data -c pubkey]
-  key %s
-end
-`, now, expires, publicB64)
-
-	if err := os.WriteFile("keys/pack.box", []byte(keyContent), 0644); err != nil {
-		fmt.Printf("Failed to write keys/pack.box: %v\n", err)
+		fmt.Printf("%v\n", err)
 		os.Exit(1)
 	}
 
-	// Remove legacy pack.pub if it exists
-	if _, err := os.Stat("keys/pack.pub"); err == nil {
-		os.Remove("keys/pack.pub")
-		fmt.Println("✓ Removed legacy keys/pack.pub")
-	}
-
 	fmt.Println()
 	fmt.Println("🔑 Key pair generated successfully!")
 	fmt.Println()
@@ -4802,6 +2529,7 @@ end
 	fmt.Println("1. Commit keys/pack.box to your repository")
 	fmt.Println("2. Store the private key safely (you'll need it to sign packages)")
 	fmt.Println("3. Sign packages: pack repo sign <private_key>")
+	fmt.Println("4. Write the package index: pack repo index <private_key>")
 }
 
 // repoSign signs all packages in the current repository
@@ -4826,47 +2554,22 @@ func repoSign(args []string) {
 	}
 
 	// Check if we're in a pack repository
-	if _, err := os.Stat("keys"); os.IsNotExist(err) {
+	if !pack.IsRepo(".") {
 		fmt.Println("error: not in a pack repository (no keys/ directory found)")
 		fmt.Println("run 'pack repo create' to create a new repository")
 		os.Exit(1)
 	}
 
-	privateKeyB64 := args[0]
-
-	// Decode private key
-	privateKeyBytes, err := base64.StdEncoding.DecodeString(privateKeyB64)
+	privateKey, err := pack.ParsePrivateKey(args[0])
 	if err != nil {
-		fmt.Printf("Failed to decode private key: %v\n", err)
-		os.Exit(1)
-	}
-
-	if len(privateKeyBytes) != ed25519.PrivateKeySize {
-		fmt.Printf("Invalid private key size: expected %d, got %d\n", ed25519.PrivateKeySize, len(privateKeyBytes))
+		fmt.Printf("%v\n", err)
 		os.Exit(1)
 	}
 
-	privateKey := ed25519.PrivateKey(privateKeyBytes)
-
 	fmt.Println("Signing all packages in repository...")
 
 	// Find all .box files in the repository
-	var boxFiles []string
-
-	// Check repository root
-	if files, err := filepath.Glob("*.box"); err == nil {
-		boxFiles = append(boxFiles, files...)
-	}
-
-	// Check all sections
-	sections := []string{"lang", "utils", "toys", "misc"}
-	for _, section := range sections {
-		pattern := filepath.Join(section, "*", "*.box")
-		if files, err := filepath.Glob(pattern); err == nil {
-			boxFiles = append(boxFiles, files...)
-		}
-	}
-
+	boxFiles := pack.RepoRecipes(".")
 	if len(boxFiles) == 0 {
 		fmt.Println("No .box files found in repository")
 		return
@@ -4884,8 +2587,8 @@ func repoSign(args []string) {
 
 	for _, boxFile := range boxFiles {
 		fmt.Printf("Signing %s...", boxFile)
-		
-		if err := signFile(privateKey, boxFile); err != nil {
+
+		if _, err := pack.SignFile(privateKey, boxFile); err != nil {
 			fmt.Printf(" ✗ failed: %v\n", err)
 			failedCount++
 		} else {
@@ -4900,8 +2603,74 @@ func repoSign(args []string) {
 		fmt.Printf("✗ Failed to sign: %d packages\n", failedCount)
 		os.Exit(1)
 	}
-	
+
 	fmt.Println()
 	fmt.Println("All packages signed successfully!")
-	fmt.Println("Commit the .sig files to your repository.")
+	fmt.Println("Run 'pack repo index' to update the package index, then")
+	fmt.Println("commit the .sig files to your repository.")
+}
+
+// repoIndex writes and signs index.box for the current repository
+func repoIndex(args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		fmt.Println("pack repo index - write the signed package index")
+		fmt.Println()
+		fmt.Println("USAGE:")
+		fmt.Println("  pack repo index <private_key>")
+		fmt.Println()
+		fmt.Println("DESCRIPTION:")
+		fmt.Println("  Reads every .box file in the repository and writes index.box")
+		fmt.Println("  with each package's name, section, version, description,")
+		fmt.Println("  license, recipe hash and signature path, then signs it.")
+		fmt.Println("  pack list, pack seek and pack open read this index instead")
+		fmt.Println("  of probing for recipes. Run it again whenever packages change.")
+		return
+	}
+
+	if len(args) == 0 {
+		fmt.Println("error: private key required")
+		fmt.Println("usage: pack repo index <private_key>")
+		os.Exit(1)
+	}
+
+	// Check if we're in a pack repository
+	if !pack.IsRepo(".") {
+		fmt.Println("error: not in a pack repository (no keys/ directory found)")
+		fmt.Println("run 'pack repo create' to create a new repository")
+		os.Exit(1)
+	}
+
+	privateKey, err := pack.ParsePrivateKey(args[0])
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Indexing packages in repository...")
+
+	idx, err := pack.WriteRepoIndex(privateKey, ".")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	unsigned := 0
+	for _, entry := range idx.Packages {
+		section := entry.Section
+		if section == "" {
+			section = "-"
+		}
+		fmt.Printf("  %-15s %-6s %s\n", entry.Name, section, entry.Version)
+		if entry.Signature == "" {
+			unsigned++
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("✓ Indexed %d package(s) in index.box\n", len(idx.Packages))
+	fmt.Println("✓ index.box.sig written")
+	if unsigned > 0 {
+		fmt.Printf("warning: %d package(s) have no signature yet, run 'pack repo sign' first\n", unsigned)
+	}
+	fmt.Println("Commit index.box and index.box.sig to your repository.")
 }
@@ -0,0 +1,208 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+func findBoxExecutable() (string, error) {
+	// Try to find box in PATH
+	if boxPath, err := exec.LookPath("box"); err == nil {
+		return boxPath, nil
+	}
+
+	// Try relative path to boxlang directory
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	// Try ../boxlang/box (assuming pack is sibling to boxlang)
+	relativePath := filepath.Join(filepath.Dir(currentDir), "boxlang", "box")
+	if _, err := os.Stat(relativePath); err == nil {
+		absPath, err := filepath.Abs(relativePath)
+		if err == nil {
+			return absPath, nil
+		}
+	}
+
+	// Try ./box in current directory
+	localPath := "./box"
+	if _, err := os.Stat(localPath); err == nil {
+		return localPath, nil
+	}
+
+	return "", fmt.Errorf("box executable not found in PATH or relative paths")
+}
+
+// runBox runs box with args inside dir, streaming its output to m.out and
+// to log when it is set
+func (m *Manager) runBox(dir string, env []string, log io.Writer, args ...string) error {
+	boxPath, err := findBoxExecutable()
+	if err != nil {
+		return fmt.Errorf("box executable not found: %v", err)
+	}
+
+	execCmd := exec.Command(boxPath, args...)
+
+	// Set working directory to the temp directory to contain build debris because random source trees are fucking annoying right
+	execCmd.Dir = dir
+	out := m.out
+	if log != nil {
+		out = io.MultiWriter(m.out, log)
+	}
+	execCmd.Stdout = out
+	execCmd.Stderr = out
+	execCmd.Stdin = m.stdin
+	if len(env) > 0 {
+		execCmd.Env = append(os.Environ(), env...)
+	}
+
+	return execCmd.Run()
+}
+
+// EnsureBox bootstraps the box interpreter if it cannot be found
+func (m *Manager) EnsureBox() error {
+	// Check if box is available in PATH
+	if _, err := exec.LookPath("box"); err == nil {
+		return nil // box is available
+	}
+
+	fmt.Fprintln(m.out, "box interpreter not found, bootstrapping...")
+
+	// Check if box exists in the bin directory
+	boxPath := filepath.Join(m.binDir, "box")
+	if _, err := os.Stat(boxPath); err == nil {
+		fmt.Fprintf(m.out, "found box at %s\n", boxPath)
+		return nil // box exists in ~/.local/bin
+	}
+
+	fmt.Fprintln(m.out, "installing box interpreter...")
+
+	// Create a minimal box bootstrap without using box itself
+	return m.bootstrapBoxMinimal()
+}
+
+func (m *Manager) bootstrapBoxMinimal() (err error) {
+	defer m.journal("install", "boxlang")(&err)
+
+	fmt.Fprintln(m.out, "bootstrapping box interpreter...")
+
+	// For bootstrapping, we'll download and build box directly
+	m.showProgress(1, 6, "creating temporary directory...")
+	tempDir, err := os.MkdirTemp("", "box-bootstrap-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Clone boxlang repository
+	m.showProgress(2, 6, "cloning boxlang repository...")
+	cloneCmd := exec.Command("git", "clone", "https://github.com/shrub4thedub/boxlang.git", tempDir)
+	cloneCmd.Stdout = nil // Suppress git output
+	cloneCmd.Stderr = nil
+	if err := cloneCmd.Run(); err != nil {
+		return fmt.Errorf("failed to clone boxlang repository: %v", err)
+	}
+
+	// Build box
+	m.showProgress(3, 6, "building box interpreter...")
+	buildCmd := exec.Command("go", "build", "-o", "box", "cmd/box/main.go")
+	buildCmd.Dir = tempDir
+	buildCmd.Stdout = nil // Suppress build output
+	buildCmd.Stderr = nil
+	if err := buildCmd.Run(); err != nil {
+		return fmt.Errorf("failed to build box: %v", err)
+	}
+
+	// Create shelf and bin directories
+	m.showProgress(4, 6, "creating installation directories...")
+	shelfDir := filepath.Join(m.shelfPath(), "boxlang")
+
+	if err := os.MkdirAll(shelfDir, publicDirPerms); err != nil {
+		return fmt.Errorf("failed to create shelf directory: %v", err)
+	}
+	if err := os.MkdirAll(m.binDir, publicDirPerms); err != nil {
+		return fmt.Errorf("failed to create %s: %v", m.binDir, err)
+	}
+
+	// Install box to shelf
+	m.showProgress(5, 6, "installing box binary...")
+	boxSrc := filepath.Join(tempDir, "box")
+	boxDst := filepath.Join(shelfDir, "box")
+	symlinkDst := filepath.Join(m.binDir, "box")
+
+	srcFile, err := os.Open(boxSrc)
+	if err != nil {
+		return fmt.Errorf("failed to open source box binary: %v", err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(boxDst)
+	if err != nil {
+		return fmt.Errorf("failed to create destination box binary: %v", err)
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return fmt.Errorf("failed to copy box binary: %v", err)
+	}
+
+	// Make box executable
+	if err := os.Chmod(boxDst, publicDirPerms); err != nil {
+		return fmt.Errorf("failed to make box executable: %v", err)
+	}
+
+	// Create symlink
+	if err := createSymlink(boxDst, symlinkDst); err != nil {
+		return fmt.Errorf("failed to create symlink: %v", err)
+	}
+
+	// Create lock file for proper package management
+	m.showProgress(6, 6, "creating package lock file...")
+	if err := m.writeBootstrapLock(tempDir, shelfDir); err != nil {
+		return fmt.Errorf("failed to create lock file: %v", err)
+	}
+
+	fmt.Fprintf(m.out, "✓ box interpreter installed to %s\n", boxDst)
+	fmt.Fprintf(m.out, "✓ symlink created at %s\n", symlinkDst)
+	fmt.Fprintf(m.out, "add %s to your PATH if it's not already included\n", m.binDir)
+
+	return nil
+}
+
+// createSymlink creates a symlink, removing existing one if needed
+func createSymlink(target, link string) error {
+	// Remove existing symlink if it exists
+	if _, err := os.Lstat(link); err == nil {
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+	}
+
+	return os.Symlink(target, link)
+}
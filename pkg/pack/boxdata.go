@@ -0,0 +1,306 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BoxFile holds the data blocks of a box script. Function blocks and [main]
+// are skipped; only [data ...] blocks are kept.
+type BoxFile struct {
+	Blocks []*DataBlock
+}
+
+// DataBlock is one [data -c name] block. When a key is set more than once
+// the first field counts; All returns every one of them.
+type DataBlock struct {
+	Name   string
+	Line   int
+	Fields []Field
+}
+
+// Field is a single key line inside a data block. Values holds the words
+// after the key with quotes removed; a quoted string is one word.
+type Field struct {
+	Key    string
+	Values []string
+	Line   int
+}
+
+// Value returns the words of the field joined by single spaces
+func (f Field) Value() string {
+	return strings.Join(f.Values, " ")
+}
+
+// BoxSyntaxError reports where a box file could not be parsed
+type BoxSyntaxError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *BoxSyntaxError) Error() string {
+	if e.Col == 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// ParseBox parses the data blocks out of box script content. A data block
+// ends at its end line, at the next block header or at the end of the file.
+func ParseBox(content []byte) (*BoxFile, error) {
+	file := &BoxFile{}
+	var current *DataBlock
+
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		lineNum := i + 1
+		trimmed := strings.TrimSpace(line)
+
+		// Any block header closes the previous block
+		if strings.HasPrefix(trimmed, "[") {
+			current = nil
+			name, isData, err := parseBlockHeader(trimmed)
+			if err != nil {
+				return nil, &BoxSyntaxError{Line: lineNum, Col: indentOf(line) + 1, Msg: err.Error()}
+			}
+			if isData {
+				current = &DataBlock{Name: name, Line: lineNum}
+				file.Blocks = append(file.Blocks, current)
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if trimmed == "end" {
+			current = nil
+			continue
+		}
+
+		words, err := splitBoxWords(line)
+		if err != nil {
+			err.Line = lineNum
+			return nil, err
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		current.Fields = append(current.Fields, Field{
+			Key:    words[0],
+			Values: words[1:],
+			Line:   lineNum,
+		})
+	}
+
+	return file, nil
+}
+
+// ParseBoxFile reads and parses the box script at path
+func ParseBoxFile(path string) (*BoxFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ParseBox(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return file, nil
+}
+
+// parseBlockHeader reads a [kind flags... name] header and returns the
+// name if it opens a data block
+func parseBlockHeader(header string) (string, bool, error) {
+	if !strings.HasSuffix(header, "]") {
+		return "", false, fmt.Errorf("unterminated block header %q", header)
+	}
+
+	parts := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(header, "["), "]"))
+	if len(parts) == 0 {
+		return "", false, fmt.Errorf("empty block header")
+	}
+	if parts[0] != "data" {
+		return "", false, nil
+	}
+
+	// The block name is the last word, after flags like -c
+	name := parts[len(parts)-1]
+	if len(parts) == 1 || strings.HasPrefix(name, "-") {
+		return "", false, fmt.Errorf("data block has no name")
+	}
+	return name, true, nil
+}
+
+// splitBoxWords splits a data line into words. Double quoted words may
+// contain \", \\ and \n escapes, single quoted words are taken literally,
+// and a # at the start of a word begins a comment.
+func splitBoxWords(line string) ([]string, *BoxSyntaxError) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '#' && !inWord:
+			return words, nil
+		case c == '"' || c == '\'':
+			start := i
+			inWord = true
+			for i++; ; i++ {
+				if i >= len(line) {
+					return nil, &BoxSyntaxError{Col: start + 1, Msg: "unterminated quoted string"}
+				}
+				if line[i] == c {
+					break
+				}
+				if c == '"' && line[i] == '\\' && i+1 < len(line) {
+					switch line[i+1] {
+					case '"', '\\':
+						i++
+					case 'n':
+						i++
+						word.WriteByte('\n')
+						continue
+					}
+				}
+				word.WriteByte(line[i])
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// quoteBoxValue quotes value if it would not read back as a single word.
+// Newlines are escaped so the value stays on its line.
+func quoteBoxValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n\"'#\\") {
+		return value
+	}
+
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	escaped = strings.ReplaceAll(escaped, "\n", `\n`)
+	return `"` + escaped + `"`
+}
+
+// indentOf returns the number of leading spaces and tabs in line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// Block returns the first data block called name, or nil
+func (f *BoxFile) Block(name string) *DataBlock {
+	for _, block := range f.Blocks {
+		if block.Name == name {
+			return block
+		}
+	}
+	return nil
+}
+
+// Field returns the first field called key
+func (b *DataBlock) Field(key string) (Field, bool) {
+	for _, field := range b.Fields {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Has reports whether the block sets key
+func (b *DataBlock) Has(key string) bool {
+	_, ok := b.Field(key)
+	return ok
+}
+
+// String returns the value of key, or "" if it is not set
+func (b *DataBlock) String(key string) string {
+	field, _ := b.Field(key)
+	return field.Value()
+}
+
+// List returns the words of key, for fields like "os linux freebsd"
+func (b *DataBlock) List(key string) []string {
+	field, _ := b.Field(key)
+	return field.Values
+}
+
+// All returns the value of every field called key, in file order
+func (b *DataBlock) All(key string) []string {
+	var values []string
+	for _, field := range b.Fields {
+		if field.Key == key {
+			values = append(values, field.Value())
+		}
+	}
+	return values
+}
+
+// Int returns key as an integer; a missing key is 0
+func (b *DataBlock) Int(key string) (int64, error) {
+	field, ok := b.Field(key)
+	if !ok {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(field.Value(), 10, 64)
+	if err != nil {
+		return 0, &BoxSyntaxError{Line: field.Line, Msg: fmt.Sprintf("%s: invalid integer %q", key, field.Value())}
+	}
+	return value, nil
+}
+
+// Map returns the block as key/value pairs, taking the first field of
+// each key as String does
+func (b *DataBlock) Map() map[string]string {
+	values := make(map[string]string, len(b.Fields))
+	for _, field := range b.Fields {
+		if _, ok := values[field.Key]; !ok {
+			values[field.Key] = field.Value()
+		}
+	}
+	return values
+}
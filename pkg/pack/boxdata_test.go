@@ -0,0 +1,201 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBoxWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		err  string
+	}{
+		{"  name edith", []string{"name", "edith"}, ""},
+		{"\tos linux  freebsd\r", []string{"os", "linux", "freebsd"}, ""},
+		{`  desc "a text editor"`, []string{"desc", "a text editor"}, ""},
+		{`  desc 'no \escapes "here"'`, []string{"desc", `no \escapes "here"`}, ""},
+		{`  desc "say \"hi\" \\ bye"`, []string{"desc", `say "hi" \ bye`}, ""},
+		{`  desc "two\nlines"`, []string{"desc", "two\nlines"}, ""},
+		{`  path "C:\dir"`, []string{"path", `C:\dir`}, ""},
+		{`  url https://x.org/#frag # a comment`, []string{"url", "https://x.org/#frag"}, ""},
+		{"  # only a comment", nil, ""},
+		{`  desc "" empty`, []string{"desc", "", "empty"}, ""},
+		{`  desc "open`, nil, "unterminated quoted string"},
+		{`  desc 'open`, nil, "unterminated quoted string"},
+	}
+
+	for _, tt := range tests {
+		words, err := splitBoxWords(tt.line)
+		if tt.err != "" {
+			if err == nil || err.Msg != tt.err || err.Col != 8 {
+				t.Errorf("splitBoxWords(%q) error = %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitBoxWords(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(words, tt.want) {
+			t.Errorf("splitBoxWords(%q) = %q, want %q", tt.line, words, tt.want)
+		}
+	}
+}
+
+func TestParseBox(t *testing.T) {
+	type block struct {
+		name   string
+		fields map[string]string
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    []block
+		err     string
+	}{
+		{
+			name: "data blocks only",
+			content: `# recipe
+[data -c pkg]
+  name edith
+  # a comment line
+  version 1.0 # trailing comment
+end
+
+[fn install]
+  name not-data
+end
+
+[main]
+  install
+end
+`,
+			want: []block{{"pkg", map[string]string{"name": "edith", "version": "1.0"}}},
+		},
+		{
+			name:    "duplicate keys keep the first",
+			content: "[data -c pkg]\n  name first\n  name second\nend\n",
+			want:    []block{{"pkg", map[string]string{"name": "first"}}},
+		},
+		{
+			name:    "a block without end stops at the next header",
+			content: "[data -c a]\n  x 1\n[data -c b]\n  y 2\n",
+			want:    []block{{"a", map[string]string{"x": "1"}}, {"b", map[string]string{"y": "2"}}},
+		},
+		{
+			name:    "fields after end belong to no block",
+			content: "[data -c a]\n  x 1\nend\n  y 2\n",
+			want:    []block{{"a", map[string]string{"x": "1"}}},
+		},
+		{
+			name:    "unterminated header",
+			content: "[data -c pkg\n  name edith\nend\n",
+			err:     `line 1, column 1: unterminated block header "[data -c pkg"`,
+		},
+		{
+			name:    "data block without a name",
+			content: "  [data -c]\n",
+			err:     "line 1, column 3: data block has no name",
+		},
+		{
+			name:    "unterminated string",
+			content: "[data -c pkg]\n  name \"edith\nend\n",
+			err:     "line 2, column 8: unterminated quoted string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ParseBox([]byte(tt.content))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []block
+			for _, b := range file.Blocks {
+				got = append(got, block{b.Name, b.Map()})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("blocks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataBlockDuplicateKeys(t *testing.T) {
+	file, err := ParseBox([]byte("[data -c sources]\n  repo one\n  repo two three\n  n 1\n  n x\nend\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := file.Block("sources")
+
+	if got := block.String("repo"); got != "one" {
+		t.Errorf("String = %q, want the first", got)
+	}
+	if got := block.Map()["repo"]; got != "one" {
+		t.Errorf("Map = %q, want the first", got)
+	}
+	if got := block.All("repo"); !reflect.DeepEqual(got, []string{"one", "two three"}) {
+		t.Errorf("All = %q", got)
+	}
+	if n, err := block.Int("n"); n != 1 || err != nil {
+		t.Errorf("Int = %d, %v, want the first", n, err)
+	}
+}
+
+func TestQuoteBoxValueRoundTrip(t *testing.T) {
+	values := []string{
+		"plain",
+		"",
+		"two words",
+		"tab\there",
+		`quote " inside`,
+		"it's",
+		"#hash",
+		`back\slash`,
+		`trailing\`,
+		"new\nline",
+		"\n",
+		`literal \n`,
+		"carriage\rreturn",
+	}
+
+	for _, value := range values {
+		line := "  key " + quoteBoxValue(value)
+		words, err := splitBoxWords(line)
+		if err != nil {
+			t.Errorf("%q quoted as %q: %v", value, line, err)
+			continue
+		}
+		if len(words) != 2 || words[1] != value {
+			t.Errorf("%q quoted as %q reads back as %q", value, line, words)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// buildLogTimeFormat names log files so they sort by time
+	buildLogTimeFormat = "20060102T150405.000Z"
+	// buildLogTailLines are shown when a recipe fails
+	buildLogTailLines = 20
+	// logPrefix marks the lines WriteBuildLog prints around box output
+	logPrefix = "== "
+)
+
+// Actions a build log records
+const (
+	LogInstall   = "install"
+	LogUpdate    = "update"
+	LogUninstall = "uninstall"
+)
+
+// BuildLog describes one recorded box run
+type BuildLog struct {
+	Package string
+	Action  string
+	Path    string
+	Started time.Time
+	// Duration and Exit are unknown for a run that never finished
+	Duration     time.Duration
+	RecipeSHA256 string
+	// Exit is 0 on success, the box exit code, or -1 if box didn't run
+	Exit     int
+	Finished bool
+}
+
+// OK reports whether the run finished successfully
+func (l *BuildLog) OK() bool {
+	return l.Finished && l.Exit == 0
+}
+
+func (m *Manager) buildLogDir(packageName string) string {
+	return m.path("logs", packageName)
+}
+
+// buildLogHeaderPath is where what pack knows about the run logged at
+// logPath is kept. It is a separate file so nothing box prints can pass
+// for it.
+func buildLogHeaderPath(logPath string) string {
+	return strings.TrimSuffix(logPath, ".log") + ".box"
+}
+
+// writeHeader saves what is known about the run so far next to its log
+func (l *BuildLog) writeHeader() error {
+	var b strings.Builder
+	b.WriteString("[data -c buildlog]\n")
+	fmt.Fprintf(&b, "  package %s\n", quoteBoxValue(l.Package))
+	fmt.Fprintf(&b, "  action %s\n", quoteBoxValue(l.Action))
+	fmt.Fprintf(&b, "  started %s\n", l.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "  recipe %s\n", quoteBoxValue(l.RecipeSHA256))
+	if l.Finished {
+		fmt.Fprintf(&b, "  exit %d\n", l.Exit)
+		fmt.Fprintf(&b, "  duration %s\n", l.Duration)
+	}
+	b.WriteString("end\n")
+
+	path := buildLogHeaderPath(l.Path)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), publicFilePerms); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// runLogged runs box like runBox, teeing its output to a new build log for
+// packageName and recording the run in a header next to it. When box fails
+// the end of the log is shown along with where to find the rest.
+func (m *Manager) runLogged(packageName, action, dir string, env []string, scriptPath string, args ...string) error {
+	logDir := m.buildLogDir(packageName)
+	if err := os.MkdirAll(logDir, publicDirPerms); err != nil {
+		return fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	started := time.Now().UTC()
+	logPath := filepath.Join(logDir, started.Format(buildLogTimeFormat)+".log")
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, publicFilePerms)
+	if err != nil {
+		return fmt.Errorf("failed to create build log: %v", err)
+	}
+
+	log := &BuildLog{Package: packageName, Action: action, Path: logPath, Started: started}
+	log.RecipeSHA256, err = calculateRecipeVersion(scriptPath)
+	if err != nil {
+		log.RecipeSHA256 = "unknown"
+	}
+	if err := log.writeHeader(); err != nil {
+		logFile.Close()
+		os.Remove(logPath)
+		return fmt.Errorf("failed to create build log: %v", err)
+	}
+
+	runErr := m.runBox(dir, env, logFile, append([]string{scriptPath}, args...)...)
+
+	log.Exit = 0
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		log.Exit = exitErr.ExitCode()
+	} else if runErr != nil {
+		log.Exit = -1
+		fmt.Fprintf(logFile, "%v\n", runErr)
+	}
+	logFile.Close()
+	log.Finished = true
+	log.Duration = time.Since(started).Round(time.Millisecond)
+	if err := log.writeHeader(); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to finish build log: %v\n", err)
+	}
+
+	m.pruneBuildLogs(packageName)
+
+	if runErr != nil {
+		if tail, err := logTail(logPath, buildLogTailLines); err == nil && len(tail) > 0 {
+			fmt.Fprintf(m.out, "last lines from %s %s:\n", action, packageName)
+			for _, line := range tail {
+				fmt.Fprintf(m.out, "  %s\n", line)
+			}
+		}
+		fmt.Fprintf(m.out, "full log: %s\n", logPath)
+	}
+	return runErr
+}
+
+// logTail returns the last n lines of the log at path
+func logTail(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// pruneBuildLogs keeps the newest keepBuildLogs logs for packageName
+func (m *Manager) pruneBuildLogs(packageName string) {
+	names, err := m.buildLogNames(packageName)
+	if err != nil || len(names) <= keepBuildLogs {
+		return
+	}
+	for _, name := range names[:len(names)-keepBuildLogs] {
+		path := filepath.Join(m.buildLogDir(packageName), name)
+		os.Remove(path)
+		os.Remove(buildLogHeaderPath(path))
+	}
+}
+
+// buildLogNames lists packageName's log files, oldest first
+func (m *Manager) buildLogNames(packageName string) ([]string, error) {
+	entries, err := os.ReadDir(m.buildLogDir(packageName))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// BuildLogs returns the recorded box runs for packageName, oldest first.
+// Logs are kept after the package is closed.
+func (m *Manager) BuildLogs(packageName string) ([]*BuildLog, error) {
+	names, err := m.buildLogNames(packageName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no build logs for %s", packageName)
+		}
+		return nil, err
+	}
+
+	var logs []*BuildLog
+	for _, name := range names {
+		log, err := readBuildLog(filepath.Join(m.buildLogDir(packageName), name))
+		if err != nil {
+			continue
+		}
+		log.Package = packageName
+		logs = append(logs, log)
+	}
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no build logs for %s", packageName)
+	}
+	return logs, nil
+}
+
+// readBuildLog reads the header of the log at path
+func readBuildLog(path string) (*BuildLog, error) {
+	file, err := ParseBoxFile(buildLogHeaderPath(path))
+	if err != nil {
+		return nil, err
+	}
+	block := file.Block("buildlog")
+	if block == nil || block.String("action") == "" {
+		return nil, fmt.Errorf("%s is not a build log", path)
+	}
+
+	log := &BuildLog{
+		Package:      block.String("package"),
+		Action:       block.String("action"),
+		Path:         path,
+		RecipeSHA256: block.String("recipe"),
+		Exit:         -1,
+	}
+	log.Started, _ = time.Parse(time.RFC3339, block.String("started"))
+	if block.Has("exit") {
+		exit, err := block.Int("exit")
+		if err != nil {
+			return nil, err
+		}
+		log.Exit = int(exit)
+		log.Finished = true
+	}
+	log.Duration, _ = time.ParseDuration(block.String("duration"))
+	return log, nil
+}
+
+// WriteBuildLog copies the log to w between lines saying what ran and how
+// it ended
+func WriteBuildLog(w io.Writer, log *BuildLog) error {
+	f, err := os.Open(log.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(w, "%s%s %s\n", logPrefix, log.Action, log.Package)
+	fmt.Fprintf(w, "%sstarted %s\n", logPrefix, log.Started.Format(time.RFC3339))
+	fmt.Fprintf(w, "%srecipe %s\n", logPrefix, log.RecipeSHA256)
+	if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+	if log.Finished {
+		fmt.Fprintf(w, "%sexit %d\n", logPrefix, log.Exit)
+		fmt.Fprintf(w, "%sduration %s\n", logPrefix, log.Duration)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBuildLogOutputCannotForgeResult runs a recipe that prints what looks
+// like a successful footer and then fails
+func TestBuildLogOutputCannotForgeResult(t *testing.T) {
+	binDir := t.TempDir()
+	box := "#!/bin/sh\necho building\necho '== exit 0'\necho '== duration 1s'\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(binDir, "box"), []byte(box), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	m := testManager(t)
+	scriptPath := filepath.Join(t.TempDir(), "edith.box")
+	if err := os.WriteFile(scriptPath, []byte("[data -c pkg]\n  name edith\nend\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.runLogged("edith", LogInstall, t.TempDir(), nil, scriptPath); err == nil {
+		t.Fatal("runLogged succeeded, want the exit status")
+	}
+
+	logs, err := m.BuildLogs("edith")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(logs))
+	}
+	log := logs[0]
+	if !log.Finished || log.Exit != 3 || log.OK() || log.Action != LogInstall {
+		t.Errorf("log = %+v, want a finished install that exited 3", log)
+	}
+
+	var out strings.Builder
+	if err := WriteBuildLog(&out, log); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if !strings.HasPrefix(lines[0], "== install edith") || lines[len(lines)-2] != "== exit 3" {
+		t.Errorf("printed log:\n%s", out.String())
+	}
+}
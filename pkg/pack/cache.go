@@ -0,0 +1,273 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheMode controls when package metadata is fetched from the network
+type CacheMode int
+
+const (
+	// CacheDefault answers from the cache while it is fresh and
+	// revalidates it with the source once it is older than the TTL
+	CacheDefault CacheMode = iota
+	// CacheRefresh revalidates with the source no matter how fresh the cache is
+	CacheRefresh
+	// CacheOffline never touches the network and uses the cache however old
+	CacheOffline
+)
+
+// PackageCache is the verified index of one source as last fetched
+type PackageCache struct {
+	Source    string
+	FetchedAt time.Time
+	Index     *RepoIndex
+}
+
+// isCacheExpired reports whether the cache is older than the TTL
+func (c *PackageCache) isCacheExpired() bool {
+	return time.Since(c.FetchedAt) > cacheExpiryMinutes*time.Minute
+}
+
+// sourceCacheDir returns the cache directory of a source
+func (m *Manager) sourceCacheDir(sourceURL string) string {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceURL)))
+	return m.path("cache", "sources", hash[:16])
+}
+
+// getCacheFilePath returns where the index of a source is cached
+func (m *Manager) getCacheFilePath(sourceURL string) string {
+	return filepath.Join(m.sourceCacheDir(sourceURL), indexFile)
+}
+
+// noIndexPath marks a source that had no index when it was last asked, so
+// lookups don't ask again until the TTL runs out
+func (m *Manager) noIndexPath(sourceURL string) string {
+	return filepath.Join(m.sourceCacheDir(sourceURL), "noindex")
+}
+
+// knownWithoutIndex reports whether the source had no index within the TTL
+func (m *Manager) knownWithoutIndex(sourceURL string) bool {
+	stat, err := os.Stat(m.noIndexPath(sourceURL))
+	return err == nil && time.Since(stat.ModTime()) <= cacheExpiryMinutes*time.Minute
+}
+
+// markNoIndex remembers that the source has no index
+func (m *Manager) markNoIndex(sourceURL string) {
+	path := m.noIndexPath(sourceURL)
+	if err := os.MkdirAll(filepath.Dir(path), publicDirPerms); err != nil {
+		return
+	}
+	os.WriteFile(path, nil, publicFilePerms)
+}
+
+// loadPackageCache reads the cached index of a source
+func (m *Manager) loadPackageCache(sourceURL string) (*PackageCache, error) {
+	cachePath := m.getCacheFilePath(sourceURL)
+	stat, err := os.Stat(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := ParseRepoIndex(content)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt cache %s: %v", cachePath, err)
+	}
+
+	return &PackageCache{
+		Source:    sourceURL,
+		FetchedAt: stat.ModTime(),
+		Index:     idx,
+	}, nil
+}
+
+// savePackageCache stores a verified index for a source
+func (m *Manager) savePackageCache(sourceURL string, content []byte) error {
+	cachePath := m.getCacheFilePath(sourceURL)
+	if err := os.MkdirAll(filepath.Dir(cachePath), publicDirPerms); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	// Write beside the cache and rename so readers never see half a file
+	tmpPath := cachePath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, publicFilePerms); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		return err
+	}
+	os.Remove(m.noIndexPath(sourceURL))
+	return nil
+}
+
+// sourceIndex returns the index of a source, from the cache when the
+// cache mode allows it and from the network otherwise. A stale cache is
+// still used if the source cannot be reached, and a source found to have
+// no index isn't asked again until the TTL runs out.
+func (m *Manager) sourceIndex(source Source) (*RepoIndex, error) {
+	cache, cacheErr := m.loadPackageCache(source.URL)
+
+	switch m.cache {
+	case CacheOffline:
+		if cacheErr != nil {
+			return nil, fmt.Errorf("no cached index for %s (run once without --offline)", source.URL)
+		}
+		return cache.Index, nil
+	case CacheDefault:
+		if cacheErr == nil && !cache.isCacheExpired() {
+			return cache.Index, nil
+		}
+		if cacheErr != nil && m.knownWithoutIndex(source.URL) {
+			return nil, fmt.Errorf("%s has no index", source.URL)
+		}
+	}
+
+	idx, err := m.refreshPackageCache(source, cacheErr == nil)
+	if err != nil && cacheErr == nil {
+		fmt.Fprintf(m.out, "warning: using cached index for %s from %s: %v\n",
+			source.URL, cache.FetchedAt.Format("2006-01-02 15:04"), err)
+		return cache.Index, nil
+	}
+	return idx, err
+}
+
+// refreshPackageCache fetches a source's index, revalidating the cached
+// copy with its ETag when there is one
+func (m *Manager) refreshPackageCache(source Source, haveCache bool) (*RepoIndex, error) {
+	provider, err := NewRepoProvider(source.Provider, source.URL, source.Branch)
+	if err != nil {
+		return nil, err
+	}
+
+	indexURL := provider.IndexURL()
+
+	var etag string
+	if haveCache {
+		etag, _ = m.loadETag(indexURL)
+	}
+
+	content, newETag, notModified, err := m.fetchIfModified(indexURL, etag)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			m.markNoIndex(source.URL)
+		}
+		return nil, fmt.Errorf("failed to fetch index: %v", err)
+	}
+
+	if notModified {
+		// Still current, restart the TTL
+		now := time.Now()
+		os.Chtimes(m.getCacheFilePath(source.URL), now, now)
+
+		cache, err := m.loadPackageCache(source.URL)
+		if err != nil {
+			return nil, err
+		}
+		return cache.Index, nil
+	}
+
+	idx, err := m.verifyIndex(source, provider, content)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only remember what passed verification
+	if err := m.savePackageCache(source.URL, content); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to cache index for %s: %v\n", source.URL, err)
+	} else if newETag != "" {
+		m.saveETag(indexURL, newETag)
+	}
+
+	return idx, nil
+}
+
+// recipeCachePath returns where a source's copy of packageName's recipe is cached
+func (m *Manager) recipeCachePath(sourceURL, packageName string) string {
+	return filepath.Join(m.sourceCacheDir(sourceURL), "recipes", packageName+".box")
+}
+
+// fetchRecipeCached copies the recipe of a remote package source to
+// scriptPath. A cached copy is used as long as it still has the hash the
+// source's index lists for it.
+func (m *Manager) fetchRecipeCached(selectedSource PackageSource, packageName, scriptPath string) error {
+	if selectedSource.Type == "local" {
+		return copyFile(selectedSource.URL, scriptPath)
+	}
+
+	cachePath := m.recipeCachePath(selectedSource.Name, packageName)
+
+	var expectedHash string
+	if source, ok := m.configuredSource(selectedSource.Name); ok {
+		if idx, err := m.sourceIndex(source); err == nil {
+			if entry, ok := idx.Lookup(packageName); ok {
+				expectedHash = entry.RecipeSHA256
+			}
+		}
+	}
+
+	if cachedHash, err := calculateRecipeVersion(cachePath); err == nil {
+		if m.cache == CacheOffline || (expectedHash != "" && cachedHash == expectedHash) {
+			return copyFile(cachePath, scriptPath)
+		}
+	}
+
+	if m.cache == CacheOffline {
+		return fmt.Errorf("%s is not cached (run once without --offline)", packageName)
+	}
+
+	if err := m.downloadFile(selectedSource.URL, scriptPath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), publicDirPerms); err == nil {
+		copyFile(scriptPath, cachePath)
+	}
+
+	return nil
+}
+
+// configuredSource returns the source in sources.box with sourceURL
+func (m *Manager) configuredSource(sourceURL string) (Source, bool) {
+	config, err := m.loadConfig()
+	if err != nil {
+		return Source{}, false
+	}
+
+	for _, source := range config.Sources {
+		if source.URL == sourceURL {
+			return source, true
+		}
+	}
+	return Source{}, false
+}
@@ -0,0 +1,311 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Config struct {
+	Sources []Source
+}
+
+// Source is a repository configured in sources.box. Provider and Branch
+// are empty unless set in the file.
+type Source struct {
+	URL      string
+	Name     string
+	Provider string
+	Branch   string
+}
+
+func (m *Manager) sourcesFile() string {
+	return filepath.Join(m.configPath(), "sources.box")
+}
+
+// Sources returns the configured remote repositories in order
+func (m *Manager) Sources() ([]Source, error) {
+	config, err := m.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Sources, nil
+}
+
+func (m *Manager) ensureConfigExists() error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	configFile := m.sourcesFile()
+
+	// Check if config file exists
+	if _, err := os.Stat(configFile); err == nil {
+		return nil // Config exists
+	}
+
+	// Create default config and fetch public key dynamically
+	fmt.Fprintln(m.out, "Setting up default pack configuration...")
+
+	// Try to fetch the public key from the default repository. The provider
+	// is built here because looking it up would read this very config.
+	provider, err := NewRepoProvider("", DefaultRepo, "")
+	if err != nil {
+		return err
+	}
+	pubkey, err := m.fetchPublicKeyWithProvider(DefaultRepo, provider)
+	if err != nil {
+		fmt.Fprintf(m.out, "Warning: could not fetch public key from %s: %v\n", DefaultRepo, err)
+		fmt.Fprintln(m.out, "Creating minimal config without public key verification.")
+
+		// Create minimal config without pubkey
+		defaultConfig := `[data -c sources]
+  repo ` + DefaultRepo + `
+end`
+		return os.WriteFile(configFile, []byte(defaultConfig), publicFilePerms)
+	}
+
+	// Create config with fetched public key
+	defaultConfig := `[data -c sources]
+  repo ` + DefaultRepo + `
+  pubkey ` + pubkey + `
+end`
+
+	fmt.Fprintf(m.out, "✓ Configured default source with public key verification\n")
+	return os.WriteFile(configFile, []byte(defaultConfig), publicFilePerms)
+}
+
+func (m *Manager) loadConfig() (*Config, error) {
+	if err := m.ensureConfigExists(); err != nil {
+		return nil, err
+	}
+
+	file, err := ParseBoxFile(m.sourcesFile())
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	block := file.Block("sources")
+	if block == nil {
+		return config, nil
+	}
+
+	// provider and branch lines belong to the repo line before them
+	for _, field := range block.Fields {
+		switch field.Key {
+		case "repo":
+			config.Sources = append(config.Sources, Source{
+				URL:  field.Value(),
+				Name: fmt.Sprintf("source-%d", len(config.Sources)+1),
+			})
+		case "provider", "branch":
+			if len(config.Sources) == 0 {
+				return nil, fmt.Errorf("%s: line %d: %s before any repo", m.sourcesFile(), field.Line, field.Key)
+			}
+			source := &config.Sources[len(config.Sources)-1]
+			if field.Key == "branch" {
+				source.Branch = field.Value()
+				continue
+			}
+			if _, err := NewRepoProvider(field.Value(), source.URL, ""); err != nil {
+				return nil, fmt.Errorf("%s: line %d: %v", m.sourcesFile(), field.Line, err)
+			}
+			source.Provider = field.Value()
+		}
+	}
+
+	return config, nil
+}
+
+// AddSource adds a repository to sources.box, fetching its public key first.
+// source.Provider and source.Branch are optional. If the key cannot be
+// fetched the prompter decides whether to add the source unverified. The
+// returned bool reports whether a key was stored.
+func (m *Manager) AddSource(source Source) (stored bool, err error) {
+	defer m.journal("add-source", source.URL)(&err)
+
+	provider, err := NewRepoProvider(source.Provider, source.URL, source.Branch)
+	if err != nil {
+		return false, err
+	}
+
+	// Try to fetch public key from the repository cuz we dont play about security
+	fmt.Fprintf(m.out, "Fetching public key for %s...\n", source.URL)
+	pubkey, err := m.fetchPublicKeyWithProvider(source.URL, provider)
+	if err != nil {
+		fmt.Fprintf(m.out, "Warning: could not fetch public key: %v\n", err)
+		ok, err := m.prompt.ConfirmUnverified("Add source without public key verification?")
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, ErrCancelled
+		}
+
+		// Add without public key
+		return false, m.addSourceWithKeyToConfig(source, "")
+	}
+
+	// Add with public key
+	return true, m.addSourceWithKeyToConfig(source, pubkey)
+}
+
+func (m *Manager) addSourceWithKeyToConfig(source Source, pubkey string) error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	configFile := m.sourcesFile()
+
+	// Lines describing the new source
+	sourceLines := []string{"  repo " + source.URL}
+	if source.Provider != "" {
+		sourceLines = append(sourceLines, "  provider "+source.Provider)
+	}
+	if source.Branch != "" {
+		sourceLines = append(sourceLines, "  branch "+source.Branch)
+	}
+	if pubkey != "" {
+		sourceLines = append(sourceLines, "  pubkey "+pubkey)
+	}
+
+	// Read existing config
+	content, err := os.ReadFile(configFile)
+	if err != nil || len(strings.TrimSpace(string(content))) == 0 {
+		// If no existing config, create new one
+		newConfig := "[data -c sources]\n" + strings.Join(sourceLines, "\n") + "\nend\n"
+		return os.WriteFile(configFile, []byte(newConfig), publicFilePerms)
+	}
+
+	file, err := ParseBox(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", configFile, err)
+	}
+
+	block := file.Block("sources")
+	if block == nil {
+		// Keep whatever else is in the file and add a sources block
+		newConfig := strings.TrimRight(string(content), "\n") + "\n\n[data -c sources]\n" + strings.Join(sourceLines, "\n") + "\nend\n"
+		return os.WriteFile(configFile, []byte(newConfig), publicFilePerms)
+	}
+
+	// Check if source already exists
+	for _, existing := range block.All("repo") {
+		if existing == source.URL {
+			return fmt.Errorf("source already exists")
+		}
+	}
+
+	// Insert new source at the end of the sources block
+	insertAt := block.Line
+	if len(block.Fields) > 0 {
+		insertAt = block.Fields[len(block.Fields)-1].Line
+	}
+
+	lines := strings.Split(string(content), "\n")
+	newLines := append([]string{}, lines[:insertAt]...)
+	newLines = append(newLines, sourceLines...)
+	newLines = append(newLines, lines[insertAt:]...)
+
+	return os.WriteFile(configFile, []byte(strings.Join(newLines, "\n")), publicFilePerms)
+}
+
+// updateSourcePublicKey updates the public key for a source in sources.box
+func (m *Manager) updateSourcePublicKey(sourceRepo string, newPubKey string) error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	configFile := m.sourcesFile()
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	file, err := ParseBox(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", configFile, err)
+	}
+
+	block := file.Block("sources")
+	if block == nil {
+		return nil
+	}
+
+	// Find the pubkey line that follows the matching repo line
+	pubkeyLine := 0
+	var currentRepo string
+	for _, field := range block.Fields {
+		switch field.Key {
+		case "repo":
+			currentRepo = field.Value()
+		case "pubkey":
+			if currentRepo == sourceRepo && pubkeyLine == 0 {
+				pubkeyLine = field.Line
+			}
+		}
+	}
+	if pubkeyLine == 0 {
+		return nil
+	}
+
+	// Replace with new public key, preserving indentation
+	lines := strings.Split(string(content), "\n")
+	line := lines[pubkeyLine-1]
+	lines[pubkeyLine-1] = line[:indentOf(line)] + "pubkey " + newPubKey
+
+	// Write updated configuration back
+	return os.WriteFile(configFile, []byte(strings.Join(lines, "\n")), publicFilePerms)
+}
+
+// getPublicKeyForSource gets the public key for a given source repository
+func (m *Manager) getPublicKeyForSource(sourceRepo string) (string, error) {
+	// First try to get key from repository's keys directory
+	if pubkey, err := m.fetchPublicKeyFromRepo(sourceRepo); err == nil {
+		return pubkey, nil
+	}
+
+	// Fallback to local config for backward compatibility
+	file, err := ParseBoxFile(m.sourcesFile())
+	if err != nil {
+		return "", err
+	}
+
+	// A pubkey line belongs to the repo line before it
+	if block := file.Block("sources"); block != nil {
+		var currentRepo string
+		for _, field := range block.Fields {
+			switch field.Key {
+			case "repo":
+				currentRepo = field.Value()
+			case "pubkey":
+				if currentRepo == sourceRepo {
+					return field.Value(), nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("public key not found for source %s", sourceRepo)
+}
@@ -0,0 +1,296 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Conflict is an installed package that a new one cannot sit alongside
+type Conflict struct {
+	Installed string   `json:"installed"`
+	Reasons   []string `json:"reasons"`
+}
+
+// recipeProvides returns the names a recipe makes available: the package
+// itself, every binary in its bin field and anything in provides
+func recipeProvides(packageName string, block *DataBlock) []string {
+	names := []string{packageName}
+	for _, name := range append(recipeList(block, "bin"), recipeList(block, "provides")...) {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lockProvides returns what an installed package provides. Locks written
+// before provides was recorded fall back to the package and its symlink.
+func lockProvides(lock *Lockfile) []string {
+	if len(lock.Provides) > 0 {
+		return lock.Provides
+	}
+
+	names := []string{lock.Package}
+	if lock.SymlinkPath != "" {
+		if bin := filepath.Base(lock.SymlinkPath); bin != lock.Package {
+			names = append(names, bin)
+		}
+	}
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// providers maps every name provided by an installed package to the
+// packages providing it
+func (m *Manager) providers() map[string][]string {
+	provided := make(map[string][]string)
+
+	installed, err := m.Installed()
+	if err != nil {
+		return provided
+	}
+
+	for _, pkg := range installed {
+		if pkg.Err != nil {
+			continue
+		}
+		for _, name := range lockProvides(pkg.Lock) {
+			provided[name] = append(provided[name], pkg.Name)
+		}
+	}
+	return provided
+}
+
+// findConflicts checks the recipe at scriptPath against every installed
+// lock other than packageName's own
+func (m *Manager) findConflicts(packageName, scriptPath string) ([]Conflict, error) {
+	block, err := recipeData(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	provides := recipeProvides(packageName, block)
+	conflicts := recipeList(block, "conflicts")
+
+	installed, err := m.Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Conflict
+	for _, pkg := range installed {
+		if pkg.Err != nil || pkg.Name == packageName {
+			continue
+		}
+		reasons := conflictReasons(packageName, provides, conflicts, pkg.Name, lockProvides(pkg.Lock), pkg.Lock.Conflicts)
+		if len(reasons) > 0 {
+			found = append(found, Conflict{Installed: pkg.Name, Reasons: reasons})
+		}
+	}
+
+	return found, nil
+}
+
+// conflictReasons says why packageName, with what it provides and
+// conflicts with, cannot sit alongside other
+func conflictReasons(packageName string, provides, conflicts []string, other string, otherProvides, otherConflicts []string) []string {
+	var reasons []string
+	for _, name := range otherProvides {
+		if containsString(conflicts, name) {
+			reasons = append(reasons, fmt.Sprintf("%s conflicts with %s", packageName, name))
+		}
+		if containsString(provides, name) {
+			reasons = append(reasons, fmt.Sprintf("both provide %s", name))
+		}
+	}
+	for _, name := range provides {
+		if containsString(otherConflicts, name) {
+			reasons = append(reasons, fmt.Sprintf("%s conflicts with %s", other, name))
+		}
+	}
+	return reasons
+}
+
+// checkPlanConflicts fails when two steps of a plan cannot be installed
+// together, since the later one would overwrite the other's links
+func checkPlanConflicts(steps []*PlanStep) error {
+	for i, a := range steps {
+		for _, b := range steps[i+1:] {
+			if reasons := conflictReasons(b.Package, b.provides, b.conflicts, a.Package, a.provides, a.conflicts); len(reasons) > 0 {
+				return fmt.Errorf("%s and %s cannot both be installed: %s", a.Package, b.Package, strings.Join(reasons, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// confirmConflicts asks whether each installed package that conflicts with
+// the recipe at scriptPath may be replaced, failing if any has to stay. It
+// returns the packages to close; nothing is removed until closeReplaced.
+func (m *Manager) confirmConflicts(packageName, scriptPath string) ([]string, error) {
+	conflicts, err := m.findConflicts(packageName, scriptPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var replaces []string
+	for _, conflict := range conflicts {
+		fmt.Fprintf(m.out, "⚠️  %s conflicts with installed package %s: %s\n", packageName, conflict.Installed, strings.Join(conflict.Reasons, ", "))
+		ok, err := m.prompt.Confirm(fmt.Sprintf("remove %s and install %s in its place?", conflict.Installed, packageName))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s conflicts with installed package %s", packageName, conflict.Installed)
+		}
+		replaces = append(replaces, conflict.Installed)
+	}
+
+	return replaces, nil
+}
+
+// replacedPackage is an installed package closed to make room for another,
+// with a copy of its recipe to put it back with
+type replacedPackage struct {
+	lock       *Lockfile
+	tempDir    string
+	scriptPath string
+}
+
+// closeReplaced closes the packages in names right before packageName is
+// built. Each one's recipe is kept under tempDir first so restoreReplaced
+// can reopen it if the build fails; a package that couldn't be put back
+// is not closed.
+func (m *Manager) closeReplaced(packageName string, names []string, tempDir string) ([]*replacedPackage, error) {
+	var closed []*replacedPackage
+	for _, name := range names {
+		lock, err := m.Lock(name)
+		if err != nil {
+			// Already gone, nothing in the way
+			continue
+		}
+
+		r := &replacedPackage{lock: lock}
+		r.tempDir, err = os.MkdirTemp(tempDir, "replaced-"+name)
+		if err == nil {
+			r.scriptPath = filepath.Join(r.tempDir, name+".box")
+			if err = m.storedRecipe(lock, r.scriptPath); err != nil {
+				_, err = m.fetchRecipe(lock.Repo, name, r.scriptPath)
+			}
+		}
+		if err != nil {
+			m.restoreReplaced(closed)
+			return nil, fmt.Errorf("cannot replace %s: no recipe to put it back with: %v", name, err)
+		}
+
+		fmt.Fprintf(m.out, "closing %s to make room for %s...\n", name, packageName)
+		if err := m.Uninstall(name); err != nil {
+			m.restoreReplaced(closed)
+			return nil, fmt.Errorf("failed to remove %s: %v", name, err)
+		}
+		closed = append(closed, r)
+	}
+	return closed, nil
+}
+
+// restoreReplaced reopens packages closed by closeReplaced from the recipes
+// they were installed with, newest closed first
+func (m *Manager) restoreReplaced(closed []*replacedPackage) {
+	for i := len(closed) - 1; i >= 0; i-- {
+		r := closed[i]
+		name := r.lock.Package
+		fmt.Fprintf(m.out, "putting %s back...\n", name)
+
+		source := PackageSource{Name: r.lock.Repo, URL: r.lock.RecipeURL, Type: "remote"}
+		if r.lock.Repo == "local" {
+			source.Type = "local"
+		}
+		step := &PlanStep{Package: name, Source: source, Requested: r.lock.InstallReason != ReasonDependency}
+		if r.lock.Pinned {
+			step.Ref = r.lock.SrcRef
+		}
+
+		generation, err := m.runRecipe(step, LogInstall, r.tempDir, r.scriptPath)
+		if err == nil {
+			var lock *Lockfile
+			if lock, err = m.writeLock(step, r.scriptPath, generation); err == nil && lock.Held != r.lock.Held {
+				lock.Held = r.lock.Held
+				err = m.saveLock(lock)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(m.out, "✗ could not put %s back: %v\n", name, err)
+			continue
+		}
+		fmt.Fprintf(m.out, "✓ %s put back\n", name)
+	}
+}
+
+// orphanedDependents returns, for each name only lock provides, the
+// installed packages that depend on it
+func (m *Manager) orphanedDependents(lock *Lockfile) map[string][]string {
+	provided := m.providers()
+	orphaned := make(map[string][]string)
+
+	installed, err := m.Installed()
+	if err != nil {
+		return orphaned
+	}
+
+	for _, name := range lockProvides(lock) {
+		others := 0
+		for _, provider := range provided[name] {
+			if provider != lock.Package {
+				others++
+			}
+		}
+		if others > 0 {
+			continue
+		}
+
+		for _, pkg := range installed {
+			if pkg.Err != nil || pkg.Name == lock.Package {
+				continue
+			}
+			if containsString(pkg.Lock.Deps, name) {
+				orphaned[name] = append(orphaned[name], pkg.Name)
+			}
+		}
+	}
+
+	for name := range orphaned {
+		sort.Strings(orphaned[name])
+	}
+	return orphaned
+}
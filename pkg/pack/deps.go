@@ -0,0 +1,257 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Install reasons recorded in the lock
+const (
+	ReasonExplicit   = "explicit"
+	ReasonDependency = "dependency"
+)
+
+// PlanStep is one package an InstallPlan will install
+type PlanStep struct {
+	Package string
+	Source  PackageSource
+	// Ref is the git ref the package is pinned to, if any
+	Ref string
+	// Deps and BuildDeps are what the recipe declares
+	Deps      []string
+	BuildDeps []string
+	// Requested is false for packages only pulled in as dependencies
+	Requested bool
+	// RequiredBy lists the planned packages that need this one
+	RequiredBy []string
+
+	// foreign reports paths another package running alongside this one
+	// claims, so they stay out of this package's manifest
+	foreign func(path string) bool
+	// provides and conflicts are checked against the plan's other steps
+	provides  []string
+	conflicts []string
+}
+
+// InstallPlan lists packages in the order they have to be installed so that
+// every package comes after its dependencies
+type InstallPlan struct {
+	AlreadyInstalled []string
+	Steps            []*PlanStep
+}
+
+// recipeList returns the words of a list field, allowing commas between them
+func recipeList(block *DataBlock, key string) []string {
+	var values []string
+	for _, value := range block.List(key) {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+// resolver walks recipe dependencies depth first to build an InstallPlan
+type resolver struct {
+	m       *Manager
+	tempDir string
+	plan    *InstallPlan
+	// provided maps names provided by installed packages to their packages
+	provided map[string][]string
+	steps    map[string]*PlanStep
+	done     map[string]bool
+	stack    []string
+}
+
+// Plan resolves packageNames and their missing dependencies across every
+// configured source. Names may be pinned as name@ref. Requested packages
+// that are already installed, at the same pin if one was given, are listed
+// in AlreadyInstalled; dependency cycles and steps that cannot be installed
+// together are an error.
+func (m *Manager) Plan(packageNames []string) (*InstallPlan, error) {
+	return m.plan(packageNames, false)
+}
+
+// plan is Plan, optionally planning requested packages even when they are
+// already installed
+func (m *Manager) plan(packageNames []string, reinstall bool) (*InstallPlan, error) {
+	tempDir, err := os.MkdirTemp(m.path("tmp"), "pack-plan")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	r := &resolver{
+		m:        m,
+		tempDir:  tempDir,
+		plan:     &InstallPlan{},
+		provided: m.providers(),
+		steps:    make(map[string]*PlanStep),
+		done:     make(map[string]bool),
+	}
+
+	for _, spec := range packageNames {
+		packageName, ref, err := SplitPin(spec)
+		if err != nil {
+			return nil, err
+		}
+		if !reinstall && m.installedAt(packageName, ref) {
+			r.plan.AlreadyInstalled = append(r.plan.AlreadyInstalled, packageName)
+			r.done[packageName] = true
+			continue
+		}
+		if err := r.visit(spec, ""); err != nil {
+			return nil, err
+		}
+		if step := r.steps[packageName]; step != nil {
+			step.Requested = true
+		}
+	}
+
+	if err := checkPlanConflicts(r.plan.Steps); err != nil {
+		return nil, err
+	}
+	return r.plan, nil
+}
+
+// visit plans the package spec names after everything it depends on
+func (r *resolver) visit(spec, requiredBy string) error {
+	packageName, ref, err := SplitPin(spec)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range r.stack {
+		if name == packageName {
+			cycle := append(append([]string{}, r.stack[i:]...), packageName)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if r.done[packageName] {
+		if step := r.steps[packageName]; step != nil && requiredBy != "" {
+			step.RequiredBy = append(step.RequiredBy, requiredBy)
+		}
+		return nil
+	}
+
+	// Dependencies that are already there, under their own name or provided
+	// by another package, are left alone
+	if requiredBy != "" && (r.m.installedAt(packageName, ref) || (ref == "" && len(r.provided[packageName]) > 0)) {
+		r.done[packageName] = true
+		return nil
+	}
+
+	source, err := r.m.chooseSource(packageName)
+	if err != nil {
+		if requiredBy != "" {
+			return fmt.Errorf("%s (needed by %s): %v", packageName, requiredBy, err)
+		}
+		return err
+	}
+
+	scriptPath := filepath.Join(r.tempDir, packageName+".box")
+	if err := r.m.fetchRecipeCached(source, packageName, scriptPath); err != nil {
+		return fmt.Errorf("failed to download recipe for %s: %v", packageName, err)
+	}
+
+	block, err := recipeData(scriptPath)
+	if err != nil {
+		return fmt.Errorf("%s: %v", packageName, err)
+	}
+
+	step := &PlanStep{
+		Package:   packageName,
+		Source:    source,
+		Ref:       ref,
+		Deps:      recipeList(block, "deps"),
+		BuildDeps: recipeList(block, "build-deps"),
+		provides:  recipeProvides(packageName, block),
+		conflicts: recipeList(block, "conflicts"),
+	}
+	if requiredBy != "" {
+		step.RequiredBy = []string{requiredBy}
+	}
+
+	r.stack = append(r.stack, packageName)
+	for _, dep := range append(append([]string{}, step.BuildDeps...), step.Deps...) {
+		if err := r.visit(dep, packageName); err != nil {
+			return err
+		}
+	}
+	r.stack = r.stack[:len(r.stack)-1]
+
+	r.done[packageName] = true
+	r.steps[packageName] = step
+	r.plan.Steps = append(r.plan.Steps, step)
+	return nil
+}
+
+// printPlan shows every step of plan and why it is there
+func (m *Manager) printPlan(plan *InstallPlan) {
+	fmt.Fprintf(m.out, "To install (%d):\n", len(plan.Steps))
+	for i, step := range plan.Steps {
+		name := step.Package
+		if step.Ref != "" {
+			name += "@" + step.Ref
+		}
+		if step.Requested {
+			fmt.Fprintf(m.out, "  %d. %s\n", i+1, name)
+		} else {
+			fmt.Fprintf(m.out, "  %d. %s (dependency of %s)\n", i+1, name, strings.Join(step.RequiredBy, ", "))
+		}
+	}
+}
+
+// markExplicit records that a package installed as a dependency has now
+// been asked for by name, so it is no longer treated as a dependency
+func (m *Manager) markExplicit(packageName string) {
+	lock, err := m.Lock(packageName)
+	if err != nil || lock.InstallReason != ReasonDependency {
+		return
+	}
+
+	lock.InstallReason = ReasonExplicit
+	if err := m.saveLock(lock); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to update lock for %s: %v\n", packageName, err)
+	}
+}
+
+// installedAt reports whether packageName is installed and, when ref is
+// set, pinned to it
+func (m *Manager) installedAt(packageName, ref string) bool {
+	if !m.IsInstalled(packageName) {
+		return false
+	}
+	if ref == "" {
+		return true
+	}
+
+	lock, err := m.Lock(packageName)
+	return err == nil && lock.Pinned && lock.SrcRef == ref
+}
@@ -0,0 +1,221 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testManager returns a Manager rooted in a fresh temporary directory
+func testManager(t *testing.T) *Manager {
+	t.Helper()
+	root := t.TempDir()
+	m, err := New(Options{Root: root, BinDir: filepath.Join(root, "bin")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(m.path("locks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// localRepoManager returns a manager whose only source is a local repo
+// holding a recipe for each name in recipes, with the given pkg fields
+func localRepoManager(t *testing.T, recipes map[string]string) *Manager {
+	t.Helper()
+	m := testManager(t)
+	for _, dir := range []string{m.configPath(), m.localRepoPath(), m.path("tmp")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(m.sourcesFile(), []byte("[data -c sources]\n  repo local\nend\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, fields := range recipes {
+		recipe := fmt.Sprintf("[data -c pkg]\n  name %s\n  %s\nend\n", name, fields)
+		if err := os.WriteFile(filepath.Join(m.localRepoPath(), name+".box"), []byte(recipe), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		// recipes maps package names to their deps and build-deps fields
+		recipes   map[string][2]string
+		installed []string
+		request   []string
+		// want is the plan as name<-requiredBy,... in install order
+		want string
+		err  string
+	}{
+		{
+			name:    "dependencies come first",
+			recipes: map[string][2]string{"app": {"lib, util", ""}, "lib": {"", ""}, "util": {"", ""}},
+			request: []string{"app"},
+			want:    "lib<-app util<-app app",
+		},
+		{
+			name:    "build deps before deps",
+			recipes: map[string][2]string{"app": {"lib", "cc"}, "lib": {"", ""}, "cc": {"", ""}},
+			request: []string{"app"},
+			want:    "cc<-app lib<-app app",
+		},
+		{
+			name:    "shared dependencies are planned once",
+			recipes: map[string][2]string{"a": {"c", ""}, "b": {"c", ""}, "c": {"", ""}},
+			request: []string{"a", "b"},
+			want:    "c<-a,b a b",
+		},
+		{
+			name:      "installed dependencies are left alone",
+			recipes:   map[string][2]string{"app": {"lib", ""}, "lib": {"", ""}},
+			installed: []string{"lib"},
+			request:   []string{"app"},
+			want:      "app",
+		},
+		{
+			name:      "requested packages that are installed are skipped",
+			recipes:   map[string][2]string{"app": {"", ""}},
+			installed: []string{"app"},
+			request:   []string{"app"},
+			want:      "",
+		},
+		{
+			name:    "cycle",
+			recipes: map[string][2]string{"a": {"b", ""}, "b": {"c", ""}, "c": {"a", ""}},
+			request: []string{"a"},
+			err:     "dependency cycle: a -> b -> c -> a",
+		},
+		{
+			name:    "cycle through build deps",
+			recipes: map[string][2]string{"a": {"", "a"}},
+			request: []string{"a"},
+			err:     "dependency cycle: a -> a",
+		},
+		{
+			name:    "missing dependency",
+			recipes: map[string][2]string{"app": {"nope", ""}},
+			request: []string{"app"},
+			err:     "nope (needed by app)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipes := make(map[string]string)
+			for name, deps := range tt.recipes {
+				recipes[name] = fmt.Sprintf("deps %q\n  build-deps %q", deps[0], deps[1])
+			}
+			m := localRepoManager(t, recipes)
+			for _, name := range tt.installed {
+				lock := &Lockfile{Package: name, Repo: "local"}
+				if err := lock.Write(m.getLockFilePath(name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plan, err := m.Plan(tt.request)
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, step := range plan.Steps {
+				entry := step.Package
+				if len(step.RequiredBy) > 0 {
+					entry += "<-" + strings.Join(step.RequiredBy, ",")
+				}
+				if step.Requested != (len(step.RequiredBy) == 0) {
+					t.Errorf("%s: Requested = %v", step.Package, step.Requested)
+				}
+				got = append(got, entry)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("plan = %q, want %q", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipes map[string]string
+		request []string
+		err     string
+	}{
+		{
+			name:    "same bin",
+			recipes: map[string]string{"vim": "bin vi", "nvi": "bin vi"},
+			request: []string{"vim", "nvi"},
+			err:     "vim and nvi cannot both be installed: both provide vi",
+		},
+		{
+			name:    "declared conflict",
+			recipes: map[string]string{"vim": "bin vim", "nvi": "bin nvi\n  conflicts vim"},
+			request: []string{"vim", "nvi"},
+			err:     "vim and nvi cannot both be installed: nvi conflicts with vim",
+		},
+		{
+			name:    "conflict with a dependency",
+			recipes: map[string]string{"app": "deps ssl", "ssl": "provides tls", "tls-lite": "conflicts tls"},
+			request: []string{"app", "tls-lite"},
+			err:     "ssl and tls-lite cannot both be installed: tls-lite conflicts with tls",
+		},
+		{
+			name:    "no overlap",
+			recipes: map[string]string{"vim": "bin vim", "nvi": "bin nvi"},
+			request: []string{"vim", "nvi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := localRepoManager(t, tt.recipes)
+			_, err := m.Plan(tt.request)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
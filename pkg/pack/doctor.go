@@ -0,0 +1,294 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// staleTempAge is how old a pack-* temp directory or lockless shelf
+// directory has to be before doctor assumes no running pack is using it
+const staleTempAge = time.Hour
+
+// Doctor report categories
+const (
+	CheckLocks  = "locks"
+	CheckShelf  = "shelf"
+	CheckBin    = "bin"
+	CheckTemp   = "tmp"
+	CheckBox    = "box"
+	CheckConfig = "config"
+	CheckKeys   = "keys"
+)
+
+// Finding is one problem doctor found
+type Finding struct {
+	Category string
+	Problem  string
+	// Hint says what to do about problems doctor will not fix itself
+	Hint string
+	// Fixed is set once --fix repaired it; FixErr when that failed
+	Fixed  bool
+	FixErr error
+
+	fix func() error
+}
+
+// Fixable reports whether doctor knows how to repair the finding
+func (f *Finding) Fixable() bool {
+	return f.fix != nil
+}
+
+// DoctorReport lists everything doctor found, grouped by category in the
+// order the checks ran
+type DoctorReport struct {
+	Findings []*Finding
+}
+
+func (r *DoctorReport) add(category, problem, hint string, fix func() error) {
+	r.Findings = append(r.Findings, &Finding{Category: category, Problem: problem, Hint: hint, fix: fix})
+}
+
+// Doctor inspects the pack directory and bin directory for drift. With fix
+// set it repairs what it safely can and records the outcome on each
+// finding.
+func (m *Manager) Doctor(fix bool) (report *DoctorReport, err error) {
+	if fix {
+		defer m.journal("doctor", "--fix")(&err)
+	}
+	report = &DoctorReport{}
+
+	installed, err := m.Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	m.checkLocks(report, installed)
+	m.checkShelf(report, installed)
+	m.checkBin(report)
+	m.checkTemp(report)
+	m.checkEnvironment(report)
+	m.checkKeyCache(report)
+
+	if fix {
+		for _, finding := range report.Findings {
+			if finding.fix == nil {
+				continue
+			}
+			if err := finding.fix(); err != nil {
+				finding.FixErr = err
+			} else {
+				finding.Fixed = true
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// checkLocks looks for locks that cannot be read, were written by an older
+// pack or whose shelf is gone
+func (m *Manager) checkLocks(report *DoctorReport, installed []InstalledPackage) {
+	for _, pkg := range installed {
+		if pkg.Err != nil {
+			report.add(CheckLocks, fmt.Sprintf("%s: unreadable lock: %v", pkg.Name, pkg.Err),
+				fmt.Sprintf("fix or remove %s", m.getLockFilePath(pkg.Name)), nil)
+			continue
+		}
+
+		if pkg.Lock.Schema < lockSchemaVersion {
+			lock := pkg.Lock
+			report.add(CheckLocks, fmt.Sprintf("%s: lock uses schema %d", pkg.Name, lock.Schema), "",
+				func() error { return m.saveLock(lock) })
+		}
+
+		// Without a generation the shelf path is only where the recipe
+		// may have installed, which many recipes never touch
+		if pkg.Lock.ShelfPath == "" || pkg.Lock.Generation == 0 {
+			continue
+		}
+		if _, err := os.Stat(pkg.Lock.ShelfPath); os.IsNotExist(err) {
+			report.add(CheckLocks, fmt.Sprintf("%s: lock points at missing shelf directory %s", pkg.Name, pkg.Lock.ShelfPath),
+				fmt.Sprintf("reinstall with 'pack open %s' or remove with 'pack close %s'", pkg.Name, pkg.Name), nil)
+		}
+	}
+}
+
+// checkShelf looks for shelf directories no lock accounts for. A package
+// being installed has no lock yet, so recently changed ones are left alone.
+func (m *Manager) checkShelf(report *DoctorReport, installed []InstalledPackage) {
+	entries, err := os.ReadDir(m.shelfPath())
+	if err != nil {
+		return
+	}
+
+	locked := make(map[string]bool)
+	for _, pkg := range installed {
+		locked[pkg.Name] = true
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || locked[entry.Name()] {
+			continue
+		}
+		dir := filepath.Join(m.shelfPath(), entry.Name())
+		if changedWithin(dir, staleTempAge) {
+			continue
+		}
+		report.add(CheckShelf, fmt.Sprintf("%s has no lock file", dir), "",
+			func() error { return os.RemoveAll(dir) })
+	}
+}
+
+// changedWithin reports whether dir or anything directly inside it was
+// modified in the last age
+func changedWithin(dir string, age time.Duration) bool {
+	paths := []string{dir}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	for _, path := range paths {
+		if info, err := os.Lstat(path); err == nil && time.Since(info.ModTime()) < age {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBin makes sure the bin directory is on PATH and looks for symlinks
+// in it whose target is gone
+func (m *Manager) checkBin(report *DoctorReport) {
+	onPath := false
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(dir) == filepath.Clean(m.binDir) {
+			onPath = true
+			break
+		}
+	}
+	if !onPath {
+		report.add(CheckBin, fmt.Sprintf("%s is not on PATH", m.binDir),
+			fmt.Sprintf("add 'export PATH=\"%s:$PATH\"' to your shell profile", m.binDir), nil)
+	}
+
+	entries, err := os.ReadDir(m.binDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		link := filepath.Join(m.binDir, entry.Name())
+		target, err := os.Readlink(link)
+		if err != nil {
+			continue // not a symlink
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(m.binDir, target)
+		}
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+
+		// Only links into the pack directory are ours to remove
+		if strings.HasPrefix(target, m.root+string(filepath.Separator)) {
+			report.add(CheckBin, fmt.Sprintf("%s points at missing %s", link, target), "",
+				func() error { return os.Remove(link) })
+		} else {
+			report.add(CheckBin, fmt.Sprintf("%s points at missing %s", link, target),
+				"it was not made by pack, remove it yourself if it is not needed", nil)
+		}
+	}
+}
+
+// checkTemp looks for temp directories left behind by runs that died
+func (m *Manager) checkTemp(report *DoctorReport) {
+	tmpDir := m.path("tmp")
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "pack-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		path := filepath.Join(tmpDir, entry.Name())
+		report.add(CheckTemp, fmt.Sprintf("leftover temp directory %s", path), "",
+			func() error { return os.RemoveAll(path) })
+	}
+}
+
+// checkEnvironment checks the box interpreter and sources.box
+func (m *Manager) checkEnvironment(report *DoctorReport) {
+	if _, err := findBoxExecutable(); err != nil {
+		if _, err := os.Stat(filepath.Join(m.binDir, "box")); err != nil {
+			report.add(CheckBox, "no box interpreter found", "", m.EnsureBox)
+		}
+	}
+
+	if _, err := m.loadConfig(); err != nil {
+		report.add(CheckConfig, fmt.Sprintf("cannot read sources.box: %v", err),
+			fmt.Sprintf("fix %s by hand", m.sourcesFile()), nil)
+	}
+}
+
+// checkKeyCache looks for cached keys that are past their expiry or
+// cannot be parsed; removing them makes pack fetch them again
+func (m *Manager) checkKeyCache(report *DoctorReport) {
+	keysDir := m.path("cache", "keys")
+	entries, err := os.ReadDir(keysDir)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".box") {
+			continue
+		}
+		path := filepath.Join(keysDir, entry.Name())
+		remove := func() error { return os.Remove(path) }
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			report.add(CheckKeys, fmt.Sprintf("unreadable cached key %s: %v", path, err), "", remove)
+			continue
+		}
+		metadata, err := parseKeyMetadata(string(content))
+		if err != nil {
+			report.add(CheckKeys, fmt.Sprintf("corrupt cached key %s: %v", path, err), "", remove)
+			continue
+		}
+		if metadata.ExpiresAt > 0 && metadata.ExpiresAt < now {
+			expired := time.Unix(metadata.ExpiresAt, 0).Format("2006-01-02")
+			report.add(CheckKeys, fmt.Sprintf("cached key %s expired on %s", path, expired), "", remove)
+		}
+	}
+}
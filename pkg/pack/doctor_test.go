@@ -0,0 +1,62 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckShelf(t *testing.T) {
+	m := testManager(t)
+	old := time.Now().Add(-2 * staleTempAge)
+
+	// An abandoned install, one still running and an installed package
+	abandoned := m.generationDir("gone", 1)
+	running := m.generationDir("building", 1)
+	installed := m.generationDir("edith", 1)
+	for _, dir := range []string{abandoned, running, installed} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{abandoned, filepath.Dir(abandoned), filepath.Dir(running)} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := &DoctorReport{}
+	m.checkShelf(report, []InstalledPackage{{Name: "edith"}})
+
+	var problems []string
+	for _, finding := range report.Findings {
+		problems = append(problems, finding.Problem)
+	}
+	want := []string{filepath.Dir(abandoned) + " has no lock file"}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
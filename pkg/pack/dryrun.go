@@ -0,0 +1,422 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// What a dry run step would do to its package
+const (
+	ActionInstall = "install"
+	ActionUpdate  = "update"
+	ActionRemove  = "remove"
+)
+
+// How a dry run step would change a lock
+const (
+	LockCreate = "create"
+	LockUpdate = "update"
+	LockRemove = "remove"
+)
+
+// DryRun is everything pack would do for an open, close or update, worked
+// out without running any recipe
+type DryRun struct {
+	AlreadyInstalled []string `json:"already_installed"`
+	// Held lists packages with updates that would be left alone
+	Held  []string      `json:"held"`
+	Steps []*DryRunStep `json:"steps"`
+}
+
+// DryRunStep is what would happen to one package
+type DryRunStep struct {
+	Package    string   `json:"package"`
+	Action     string   `json:"action"`
+	Source     string   `json:"source"`
+	RecipeURL  string   `json:"recipe_url"`
+	Ref        string   `json:"ref,omitempty"`
+	RequiredBy []string `json:"required_by"`
+	// Verified is whether the recipe's signature checks out
+	Verified    bool   `json:"verified"`
+	VerifyError string `json:"verify_error,omitempty"`
+	// Replaces are installed packages that would have to be closed first
+	Replaces []Conflict   `json:"replaces"`
+	Links    []LinkChange `json:"links"`
+	// Remove lists what close would delete
+	Remove []string   `json:"remove"`
+	Lock   LockChange `json:"lock"`
+	Notes  []string   `json:"notes"`
+}
+
+// LinkChange is a link in the bin directory a step would create, replace
+// or remove
+type LinkChange struct {
+	Path string `json:"path"`
+	// Current is where the link points now, the path itself when a plain
+	// file is in the way and empty when nothing is there
+	Current string `json:"current,omitempty"`
+	// Owner is the installed package that put Current there, if known
+	Owner string `json:"owner,omitempty"`
+}
+
+// LockChange is how a step would change its package's lock
+type LockChange struct {
+	Path   string        `json:"path"`
+	Change string        `json:"change"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is one lock field that would change
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// WriteDryRun writes run as JSON
+func WriteDryRun(w io.Writer, run *DryRun) error {
+	return writeJSON(w, run)
+}
+
+func newDryRun() *DryRun {
+	return &DryRun{AlreadyInstalled: []string{}, Held: []string{}, Steps: []*DryRunStep{}}
+}
+
+// DryRunInstall works out what Install would do for packageName and its
+// missing dependencies
+func (m *Manager) DryRunInstall(packageName string) (*DryRun, error) {
+	plan, err := m.plan([]string{packageName}, true)
+	if err != nil {
+		return nil, err
+	}
+	return m.dryRunPlan(plan)
+}
+
+// DryRunInstallAll works out what InstallAll would do for packageNames
+func (m *Manager) DryRunInstallAll(packageNames []string) (*DryRun, error) {
+	plan, err := m.Plan(packageNames)
+	if err != nil {
+		return nil, err
+	}
+	return m.dryRunPlan(plan)
+}
+
+func (m *Manager) dryRunPlan(plan *InstallPlan) (*DryRun, error) {
+	run := newDryRun()
+	run.AlreadyInstalled = append(run.AlreadyInstalled, plan.AlreadyInstalled...)
+
+	for _, step := range plan.Steps {
+		tempDir, err := os.MkdirTemp(m.path("tmp"), "pack-dry-run-"+step.Package)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %v", err)
+		}
+		scriptPath := filepath.Join(tempDir, step.Package+".box")
+
+		err = m.downloadFrom(step.Source, scriptPath)
+		if err == nil {
+			err = checkPin(step.Package, step.Ref, scriptPath)
+		}
+		var dryStep *DryRunStep
+		if err == nil {
+			dryStep, err = m.dryRunStep(step, ActionInstall, scriptPath)
+		}
+		os.RemoveAll(tempDir)
+		if err != nil {
+			return nil, &PackageError{Package: step.Package, Err: err}
+		}
+		run.Steps = append(run.Steps, dryStep)
+	}
+
+	return run, nil
+}
+
+// DryRunUpdate works out what UpdateAll would do, without updating pack
+// and boxlang first or refreshing keys
+func (m *Manager) DryRunUpdate(opts UpdateOptions) (*DryRun, error) {
+	result, err := m.Outdated(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Failed) > 0 {
+		return nil, result.Failed[0]
+	}
+
+	run := newDryRun()
+	for _, update := range result.Held {
+		run.Held = append(run.Held, update.PackageName)
+	}
+
+	for _, update := range result.Available {
+		packageName := update.PackageName
+		lock, err := m.Lock(packageName)
+		if err != nil {
+			return nil, &PackageError{Package: packageName, Err: err}
+		}
+
+		tempDir, err := os.MkdirTemp(m.path("tmp"), "pack-dry-run-"+packageName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %v", err)
+		}
+		scriptPath := filepath.Join(tempDir, packageName+".box")
+
+		step, stored, err := m.updateStep(packageName, lock, scriptPath)
+		var dryStep *DryRunStep
+		if err == nil {
+			dryStep, err = m.dryRunStep(step, ActionUpdate, scriptPath)
+		}
+		os.RemoveAll(tempDir)
+		if err != nil {
+			return nil, &PackageError{Package: packageName, Err: err}
+		}
+
+		dryStep.Notes = append(dryStep.Notes, update.UpdateType)
+		if stored {
+			dryStep.Notes = append(dryStep.Notes, "source unreachable, the stored recipe would be rerun")
+		}
+		run.Steps = append(run.Steps, dryStep)
+	}
+
+	return run, nil
+}
+
+// dryRunStep checks the recipe at scriptPath for step the way installing
+// it would, and predicts its links and lock assuming it installs into its
+// stage
+func (m *Manager) dryRunStep(step *PlanStep, action, scriptPath string) (*DryRunStep, error) {
+	dryStep := &DryRunStep{
+		Package:    step.Package,
+		Action:     action,
+		Source:     step.Source.Name,
+		RecipeURL:  constructRecipeURL(step.Source),
+		Ref:        step.Ref,
+		RequiredBy: append([]string{}, step.RequiredBy...),
+		Replaces:   []Conflict{},
+		Links:      []LinkChange{},
+		Remove:     []string{},
+		Notes:      []string{},
+	}
+
+	conflicts, err := m.findConflicts(step.Package, scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	dryStep.Replaces = append(dryStep.Replaces, conflicts...)
+
+	if err := m.VerifyRecipe(scriptPath, step.Source.Name); err != nil {
+		dryStep.VerifyError = err.Error()
+	} else {
+		dryStep.Verified = true
+	}
+	if step.Source.Type == "local" {
+		dryStep.Notes = append(dryStep.Notes, "local recipe, signatures aren't checked")
+	}
+
+	block, err := recipeData(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, bin := range recipeList(block, "bin") {
+		dryStep.Links = append(dryStep.Links, m.linkChange(filepath.Join(m.binDir, bin), step.Package))
+	}
+
+	generation, err := m.nextGeneration(step.Package)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := m.buildLock(step, scriptPath, generation)
+	if err != nil {
+		return nil, err
+	}
+	dryStep.Lock = m.lockChange(step.Package, lock)
+
+	return dryStep, nil
+}
+
+// DryRunUninstall works out what Uninstall would remove for packageName
+func (m *Manager) DryRunUninstall(packageName string) (*DryRun, error) {
+	lock, err := m.Lock(packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	dryStep := &DryRunStep{
+		Package:    packageName,
+		Action:     ActionRemove,
+		Source:     lock.Repo,
+		RecipeURL:  lock.RecipeURL,
+		RequiredBy: []string{},
+		Replaces:   []Conflict{},
+		Links:      []LinkChange{},
+		Remove:     []string{},
+		Notes:      []string{},
+	}
+
+	orphaned := m.orphanedDependents(lock)
+	names := make([]string, 0, len(orphaned))
+	for name := range orphaned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dryStep.Notes = append(dryStep.Notes, fmt.Sprintf("only provider of %s, which %s depend(s) on", name, strings.Join(orphaned[name], ", ")))
+	}
+
+	tempDir, err := os.MkdirTemp(m.path("tmp"), "pack-dry-run-"+packageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	scriptPath := filepath.Join(tempDir, packageName+".box")
+	recipeErr := m.storedRecipe(lock, scriptPath)
+	if recipeErr != nil {
+		_, recipeErr = m.fetchRecipe(lock.Repo, packageName, scriptPath)
+	}
+	if recipeErr != nil {
+		dryStep.VerifyError = recipeErr.Error()
+		dryStep.Notes = append(dryStep.Notes, "recipe unavailable, recorded files would be removed")
+	} else {
+		if err := m.VerifyRecipe(scriptPath, lock.Repo); err != nil {
+			dryStep.VerifyError = err.Error()
+		} else {
+			dryStep.Verified = true
+		}
+		if recipeHasFn(scriptPath, "uninstall") {
+			dryStep.Notes = append(dryStep.Notes, "the recipe's uninstall function would run first")
+		} else {
+			dryStep.Notes = append(dryStep.Notes, "recipe has no uninstall function, recorded files would be removed")
+		}
+	}
+
+	m.dryRunRemoval(lock, dryStep)
+	dryStep.Lock = LockChange{Path: m.getLockFilePath(packageName), Change: LockRemove, Fields: []FieldChange{}}
+
+	run := newDryRun()
+	run.Steps = append(run.Steps, dryStep)
+	return run, nil
+}
+
+// dryRunRemoval fills in the links and paths removeInstalledFiles and
+// removeGenerations would delete for lock
+func (m *Manager) dryRunRemoval(lock *Lockfile, dryStep *DryRunStep) {
+	mf, err := m.Files(lock.Package)
+	if err != nil {
+		if lock.SymlinkPath != "" {
+			if _, err := os.Lstat(lock.SymlinkPath); err == nil {
+				dryStep.Links = append(dryStep.Links, m.linkChange(lock.SymlinkPath, ""))
+			}
+		}
+		if _, err := os.Stat(m.packageShelf(lock.Package)); err == nil {
+			dryStep.Remove = append(dryStep.Remove, m.packageShelf(lock.Package))
+		}
+		return
+	}
+
+	// Generational installs lose the whole shelf, so nothing in it is listed
+	shelf := m.packageShelf(lock.Package)
+	if lock.Generation > 0 {
+		dryStep.Remove = append(dryStep.Remove, shelf)
+	}
+
+	for _, entry := range mf.Entries {
+		if lock.ConfigDir != "" && (entry.Path == lock.ConfigDir || strings.HasPrefix(entry.Path, lock.ConfigDir+string(filepath.Separator))) {
+			continue
+		}
+		if lock.Generation > 0 && (entry.Path == shelf || strings.HasPrefix(entry.Path, shelf+string(filepath.Separator))) {
+			continue
+		}
+		if entry.Modified {
+			continue
+		}
+		if _, err := os.Lstat(entry.Path); err != nil {
+			continue
+		}
+		if entry.Kind == EntryLink && filepath.Dir(entry.Path) == m.binDir {
+			dryStep.Links = append(dryStep.Links, m.linkChange(entry.Path, ""))
+		} else {
+			dryStep.Remove = append(dryStep.Remove, entry.Path)
+		}
+	}
+	if lock.ConfigDir != "" {
+		if _, err := os.Stat(lock.ConfigDir); err == nil {
+			dryStep.Notes = append(dryStep.Notes, "configuration in "+lock.ConfigDir+" would be kept")
+		}
+	}
+}
+
+// linkChange describes the link at path, naming whoever owns what is
+// there now unless it is packageName itself
+func (m *Manager) linkChange(path, packageName string) LinkChange {
+	change := LinkChange{Path: path}
+	if target, err := os.Readlink(path); err == nil {
+		change.Current = target
+	} else if _, err := os.Lstat(path); err == nil {
+		change.Current = path
+	}
+	if change.Current != "" {
+		if owners, err := m.Owns(path); err == nil {
+			for _, owner := range owners {
+				if owner != packageName {
+					change.Owner = owner
+					break
+				}
+			}
+		}
+	}
+	return change
+}
+
+// lockChange compares the lock packageName has now with lock
+func (m *Manager) lockChange(packageName string, lock *Lockfile) LockChange {
+	change := LockChange{Path: m.getLockFilePath(packageName), Change: LockCreate}
+
+	old := &Lockfile{}
+	if previous, err := m.Lock(packageName); err == nil {
+		old = previous
+		change.Change = LockUpdate
+	}
+	change.Fields = lockFieldChanges(old, lock)
+
+	return change
+}
+
+// lockFieldChanges lists the fields that differ between old and lock
+func lockFieldChanges(old, lock *Lockfile) []FieldChange {
+	changes := []FieldChange{}
+	oldFields := old.fields()
+	for i, field := range lock.fields() {
+		// The install time always changes and the schema never does
+		if field[0] == "installed_at" || field[0] == "schema" {
+			continue
+		}
+		if field[1] != oldFields[i][1] {
+			changes = append(changes, FieldChange{Field: field[0], Old: oldFields[i][1], New: field[1]})
+		}
+	}
+	return changes
+}
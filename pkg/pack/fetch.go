@@ -0,0 +1,242 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func copyFile(src, dst string) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	_, err = io.Copy(destFile, sourceFile)
+	return err
+}
+
+// localURLPath returns the path behind a file:// URL
+func localURLPath(url string) (string, bool) {
+	if !strings.HasPrefix(url, "file://") {
+		return "", false
+	}
+	return strings.TrimPrefix(url, "file://"), true
+}
+
+func (m *Manager) downloadFile(url, dest string) error {
+	return m.downloadFileWithCache(url, dest, false)
+}
+
+// downloadFileWithCache downloads a file with optional ETag caching
+func (m *Manager) downloadFileWithCache(url, dest string, useCache bool) error {
+	if path, ok := localURLPath(url); ok {
+		return copyFile(path, dest)
+	}
+
+	// Prepare request
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Use ETag caching if enabled and cache exists
+	if useCache {
+		if etag, err := m.loadETag(url); err == nil && etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Handle 304 Not Modified
+	if resp.StatusCode == http.StatusNotModified {
+		// File hasn't changed, use cached version
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	// Create output file
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Download content
+	_, err = io.Copy(out, resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Save ETag for future requests if caching is enabled
+	if useCache {
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			m.saveETag(url, etag)
+		}
+	}
+
+	return nil
+}
+
+// fetchBytes downloads url into memory, failing on anything but a 200
+func (m *Manager) fetchBytes(url string) ([]byte, error) {
+	if path, ok := localURLPath(url); ok {
+		return os.ReadFile(path)
+	}
+
+	resp, err := m.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("not found at %s (status: %d)", url, resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// errNotFound is wrapped by fetchIfModified when the file doesn't exist,
+// as opposed to the source being unreachable
+var errNotFound = errors.New("not found")
+
+// fetchIfModified downloads url unless it still matches etag. notModified
+// is set when the server answered 304; the returned tag is the new ETag.
+func (m *Manager) fetchIfModified(url, etag string) (content []byte, newETag string, notModified bool, err error) {
+	if path, ok := localURLPath(url); ok {
+		content, err = os.ReadFile(path)
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("%w at %s", errNotFound, url)
+		}
+		return content, "", false, err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, true, nil
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", false, fmt.Errorf("%w at %s (status: %d)", errNotFound, url, resp.StatusCode)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", false, fmt.Errorf("not found at %s (status: %d)", url, resp.StatusCode)
+	}
+
+	content, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	return content, resp.Header.Get("ETag"), false, nil
+}
+
+// etagPath returns where the ETag for url is cached
+func (m *Manager) etagPath(url string) (string, error) {
+	cacheDir := m.path("cache")
+	if err := os.MkdirAll(cacheDir, publicDirPerms); err != nil {
+		return "", err
+	}
+
+	// Create filename from URL hash
+	hash := sha256.Sum256([]byte(url))
+	filename := hex.EncodeToString(hash[:])[:16] + ".etag"
+	return filepath.Join(cacheDir, filename), nil
+}
+
+// loadETag loads the cached ETag for a URL
+func (m *Manager) loadETag(url string) (string, error) {
+	etagPath, err := m.etagPath(url)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(etagPath)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// saveETag saves the ETag for a URL
+func (m *Manager) saveETag(url, etag string) error {
+	etagPath, err := m.etagPath(url)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(etagPath, []byte(etag), publicFilePerms)
+}
+
+// testPackageExists does a lightweight test to see if a package exists at a URL
+func (m *Manager) testPackageExists(url string) bool {
+	if path, ok := localURLPath(url); ok {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	resp, err := m.client.Head(url)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == 200
+}
@@ -0,0 +1,352 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format selects how command output is written
+type Format int
+
+const (
+	// FormatText is the aligned tables people read
+	FormatText Format = iota
+	// FormatJSON writes one JSON document per command
+	FormatJSON
+	// FormatTSV writes a header line and one tab separated line per record
+	FormatTSV
+)
+
+// ParseFormat turns text, json or tsv into a Format
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	case "tsv":
+		return FormatTSV, nil
+	}
+	return FormatText, fmt.Errorf("unknown format '%s' (use text, json or tsv)", name)
+}
+
+// PackageRecord is a package offered by a source
+type PackageRecord struct {
+	Source      string `json:"source"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	License     string `json:"license"`
+}
+
+// NewPackageRecord returns the record for info as listed by source
+func NewPackageRecord(source Source, info PackageInfo) PackageRecord {
+	return PackageRecord{
+		Source:      source.URL,
+		Name:        info.Name,
+		Description: info.Description,
+		Version:     info.Version,
+		License:     info.License,
+	}
+}
+
+var packageColumns = []string{"source", "name", "description", "version", "license"}
+
+func (r PackageRecord) row() []string {
+	return []string{r.Source, r.Name, r.Description, r.Version, r.License}
+}
+
+// InstalledRecord is an installed package as its lock describes it
+type InstalledRecord struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Repo        string   `json:"repo"`
+	SourceURL   string   `json:"source_url"`
+	SourceType  string   `json:"source_type"`
+	Ref         string   `json:"ref"`
+	Pinned      bool     `json:"pinned"`
+	Held        bool     `json:"held"`
+	Reason      string   `json:"reason"`
+	InstalledAt string   `json:"installed_at"`
+	Generation  int      `json:"generation"`
+	Bins        []string `json:"bins"`
+	Deps        []string `json:"deps"`
+}
+
+// NewInstalledRecord returns the record for an installed package with a
+// readable lock
+func NewInstalledRecord(pkg InstalledPackage) InstalledRecord {
+	lock := pkg.Lock
+	record := InstalledRecord{
+		Name:       pkg.Name,
+		Version:    lock.SrcRefUsed,
+		Repo:       lock.Repo,
+		SourceURL:  lock.SrcURL,
+		SourceType: lock.SrcType,
+		Ref:        lock.SrcRef,
+		Pinned:     lock.Pinned,
+		Held:       lock.Held,
+		Reason:     lock.InstallReason,
+		Generation: lock.Generation,
+		Bins:       nonNil(lock.Bins),
+		Deps:       nonNil(lock.Deps),
+	}
+	if record.Reason == "" {
+		record.Reason = ReasonExplicit
+	}
+	if !lock.InstalledAt.IsZero() {
+		record.InstalledAt = lock.InstalledAt.UTC().Format(time.RFC3339)
+	}
+	return record
+}
+
+var installedColumns = []string{"name", "version", "repo", "source_url", "source_type", "ref",
+	"pinned", "held", "reason", "installed_at", "generation", "bins", "deps"}
+
+func (r InstalledRecord) row() []string {
+	return []string{r.Name, r.Version, r.Repo, r.SourceURL, r.SourceType, r.Ref,
+		strconv.FormatBool(r.Pinned), strconv.FormatBool(r.Held), r.Reason, r.InstalledAt,
+		strconv.Itoa(r.Generation), strings.Join(r.Bins, " "), strings.Join(r.Deps, " ")}
+}
+
+// Update statuses in UpdateRecord
+const (
+	UpdateAvailable = "available"
+	UpdateHeld      = "held"
+	UpdateDone      = "updated"
+	UpdateFailed    = "failed"
+)
+
+// UpdateRecord is an available update and what became of it
+type UpdateRecord struct {
+	Name           string `json:"name"`
+	CurrentVersion string `json:"current_version"`
+	NewVersion     string `json:"new_version"`
+	Reason         string `json:"reason"`
+	Pin            string `json:"pin"`
+	Held           bool   `json:"held"`
+	Status         string `json:"status"`
+}
+
+// NewUpdateRecord returns the record for update with status
+func NewUpdateRecord(update PackageUpdate, status string) UpdateRecord {
+	return UpdateRecord{
+		Name:           update.PackageName,
+		CurrentVersion: update.CurrentVersion,
+		NewVersion:     update.NewVersion,
+		Reason:         update.UpdateType,
+		Pin:            update.Pin,
+		Held:           update.Held,
+		Status:         status,
+	}
+}
+
+// UpdateRecords returns a record for every update in result, held ones last
+func UpdateRecords(result *UpdateResult) []UpdateRecord {
+	updated := make(map[string]bool)
+	for _, name := range result.Updated {
+		updated[name] = true
+	}
+	failed := make(map[string]bool)
+	for _, pkgErr := range result.Failed {
+		failed[pkgErr.Package] = true
+	}
+
+	var records []UpdateRecord
+	for _, update := range result.Available {
+		status := UpdateAvailable
+		if updated[update.PackageName] {
+			status = UpdateDone
+		} else if failed[update.PackageName] {
+			status = UpdateFailed
+		}
+		records = append(records, NewUpdateRecord(update, status))
+	}
+	for _, update := range result.Held {
+		records = append(records, NewUpdateRecord(update, UpdateHeld))
+	}
+	return records
+}
+
+var updateColumns = []string{"name", "current_version", "new_version", "reason", "pin", "held", "status"}
+
+func (r UpdateRecord) row() []string {
+	return []string{r.Name, r.CurrentVersion, r.NewVersion, r.Reason, r.Pin, strconv.FormatBool(r.Held), r.Status}
+}
+
+// ErrorRecord is an error as written to stderr in machine-readable output
+type ErrorRecord struct {
+	Error   string `json:"error"`
+	Package string `json:"package,omitempty"`
+	Source  string `json:"source,omitempty"`
+}
+
+// NewErrorRecord returns the record for err, filling in the package when
+// err is a PackageError
+func NewErrorRecord(err error) ErrorRecord {
+	record := ErrorRecord{Error: err.Error()}
+	var pkgErr *PackageError
+	if errors.As(err, &pkgErr) {
+		record.Package = pkgErr.Package
+		record.Error = pkgErr.Err.Error()
+	}
+	return record
+}
+
+// peekFieldOrder is the order pkg block fields are shown in
+var peekFieldOrder = []string{"name", "desc", "ver", "src-type", "src-url", "src-ref", "bin", "license"}
+
+// FieldOrder returns the keys of fields with the usual pkg block fields
+// first and the rest sorted after them
+func FieldOrder(fields map[string]string) []string {
+	var keys []string
+	known := make(map[string]bool)
+	for _, key := range peekFieldOrder {
+		known[key] = true
+		if _, ok := fields[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	var rest []string
+	for key := range fields {
+		if !known[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
+
+// WritePackages writes packages in format
+func WritePackages(w io.Writer, format Format, packages []PackageRecord) error {
+	rows := make([][]string, 0, len(packages))
+	for _, pkg := range packages {
+		rows = append(rows, pkg.row())
+	}
+	if packages == nil {
+		packages = []PackageRecord{}
+	}
+	return writeRecords(w, format, packages, packageColumns, rows)
+}
+
+// WriteInstalled writes installed packages in format
+func WriteInstalled(w io.Writer, format Format, installed []InstalledRecord) error {
+	rows := make([][]string, 0, len(installed))
+	for _, pkg := range installed {
+		rows = append(rows, pkg.row())
+	}
+	if installed == nil {
+		installed = []InstalledRecord{}
+	}
+	return writeRecords(w, format, installed, installedColumns, rows)
+}
+
+// WriteUpdates writes available updates in format
+func WriteUpdates(w io.Writer, format Format, updates []UpdateRecord) error {
+	rows := make([][]string, 0, len(updates))
+	for _, update := range updates {
+		rows = append(rows, update.row())
+	}
+	if updates == nil {
+		updates = []UpdateRecord{}
+	}
+	return writeRecords(w, format, updates, updateColumns, rows)
+}
+
+// WritePeek writes a package's pkg block fields in format, as an object in
+// JSON and key/value lines in TSV
+func WritePeek(w io.Writer, format Format, packageName string, fields map[string]string) error {
+	if format == FormatJSON {
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		return writeJSON(w, struct {
+			Package string            `json:"package"`
+			Fields  map[string]string `json:"fields"`
+		}{packageName, fields})
+	}
+
+	var rows [][]string
+	for _, key := range FieldOrder(fields) {
+		rows = append(rows, []string{key, fields[key]})
+	}
+	return writeRecords(w, format, nil, []string{"key", "value"}, rows)
+}
+
+// WriteError writes err as one JSON object or TSV line, meant for stderr
+func WriteError(w io.Writer, format Format, record ErrorRecord) error {
+	if format == FormatTSV {
+		_, err := fmt.Fprintln(w, strings.Join([]string{"error", tsvField(record.Package), tsvField(record.Source), tsvField(record.Error)}, "\t"))
+		return err
+	}
+	return writeJSON(w, record)
+}
+
+func writeRecords(w io.Writer, format Format, records interface{}, columns []string, rows [][]string) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, records)
+	case FormatTSV:
+		if _, err := fmt.Fprintln(w, strings.Join(columns, "\t")); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			fields := make([]string, len(row))
+			for i, field := range row {
+				fields[i] = tsvField(field)
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("format has no machine-readable writer")
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// tsvField keeps a value on one line and in one column
+func tsvField(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r").Replace(value)
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
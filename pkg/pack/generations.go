@@ -0,0 +1,309 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Environment handed to box scripts. A recipe that installs into
+// $PACK_STAGE gets a new shelf generation on every install or update, and
+// pack links its bin entries into the bin directory itself. $PACK_SHELF is
+// where the stage ends up, for recipes that need to know their final path.
+const (
+	stageEnvVar = "PACK_STAGE"
+	shelfEnvVar = "PACK_SHELF"
+)
+
+// packageShelf is the directory holding every generation of packageName
+func (m *Manager) packageShelf(packageName string) string {
+	return filepath.Join(m.shelfPath(), packageName)
+}
+
+// generationDir is the shelf directory of one generation
+func (m *Manager) generationDir(packageName string, generation int) string {
+	return filepath.Join(m.packageShelf(packageName), strconv.Itoa(generation))
+}
+
+// generationLockPath is where the lock of one generation is kept
+func (m *Manager) generationLockPath(packageName string, generation int) string {
+	return m.path("locks", packageName, "gen-"+strconv.Itoa(generation)+".lock")
+}
+
+// generations returns the generation numbers on packageName's shelf,
+// oldest first
+func (m *Manager) generations(packageName string) ([]int, error) {
+	entries, err := os.ReadDir(m.packageShelf(packageName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var gens []int
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if gen, err := strconv.Atoi(entry.Name()); err == nil && gen > 0 {
+			gens = append(gens, gen)
+		}
+	}
+	sort.Ints(gens)
+	return gens, nil
+}
+
+// nextGeneration returns the number packageName's next generation gets
+func (m *Manager) nextGeneration(packageName string) (int, error) {
+	gens, err := m.generations(packageName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read shelf: %v", err)
+	}
+
+	if len(gens) == 0 {
+		return 1, nil
+	}
+	return gens[len(gens)-1] + 1, nil
+}
+
+// isEmptyDir reports whether dir exists and has nothing in it
+func isEmptyDir(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) == 0
+}
+
+// findBin returns the path of binary name inside a generation, looking in
+// its bin directory first
+func findBin(dir, name string) (string, bool) {
+	for _, path := range []string{filepath.Join(dir, "bin", name), filepath.Join(dir, name)} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// checkStage makes sure every declared binary was staged and is executable
+func checkStage(stageDir string, bins []string) error {
+	for _, name := range bins {
+		path, ok := findBin(stageDir, name)
+		if !ok {
+			return fmt.Errorf("bin %s was not installed", name)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&0111 == 0 {
+			return fmt.Errorf("bin %s is not executable", name)
+		}
+	}
+	return nil
+}
+
+// replaceSymlink points link at target by renaming a new link over it, so
+// the old target stays in place until the new one is
+func replaceSymlink(target, link string) error {
+	tmp := filepath.Join(filepath.Dir(link), "."+filepath.Base(link)+".pack-new")
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// linkGeneration points the bin directory at the binaries of lock's
+// generation
+func (m *Manager) linkGeneration(lock *Lockfile) error {
+	if err := os.MkdirAll(m.binDir, publicDirPerms); err != nil {
+		return err
+	}
+
+	for _, name := range lock.Bins {
+		target, ok := findBin(lock.ShelfPath, name)
+		if !ok {
+			return fmt.Errorf("%s not found in %s", name, lock.ShelfPath)
+		}
+		if err := replaceSymlink(target, filepath.Join(m.binDir, name)); err != nil {
+			return fmt.Errorf("failed to link %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// saveGeneration keeps a copy of lock and of the stored recipe for rolling
+// back to later and drops generations beyond the newest keepGenerations,
+// never the current one
+func (m *Manager) saveGeneration(lock *Lockfile) error {
+	path := m.generationLockPath(lock.Package, lock.Generation)
+	if err := os.MkdirAll(filepath.Dir(path), publicDirPerms); err != nil {
+		return err
+	}
+	if err := lock.Write(path); err != nil {
+		return err
+	}
+	if err := copyRecipe(m.storedRecipePath(lock.Package), m.generationRecipePath(lock.Package, lock.Generation)); err != nil {
+		return fmt.Errorf("failed to keep the recipe of generation %d: %v", lock.Generation, err)
+	}
+
+	gens, err := m.generations(lock.Package)
+	if err != nil {
+		return err
+	}
+	var pruned []string
+	for len(gens) > keepGenerations {
+		gen := gens[0]
+		gens = gens[1:]
+		if gen == lock.Generation {
+			continue
+		}
+		pruned = append(pruned, m.generationDir(lock.Package, gen))
+		os.RemoveAll(m.generationDir(lock.Package, gen))
+		os.Remove(m.generationLockPath(lock.Package, gen))
+		os.Remove(m.generationRecipePath(lock.Package, gen))
+		os.Remove(m.generationRecipePath(lock.Package, gen) + ".sig")
+	}
+	return m.syncManifest(lock.Package, nil, pruned)
+}
+
+// Generations returns the saved locks of packageName's shelf generations,
+// oldest first
+func (m *Manager) Generations(packageName string) ([]*Lockfile, error) {
+	gens, err := m.generations(packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []*Lockfile
+	for _, gen := range gens {
+		lock, err := ReadLockfile(m.generationLockPath(packageName, gen))
+		if err != nil {
+			continue
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+// Rollback switches packageName back to an earlier shelf generation by
+// re-pointing its links and restoring that generation's lock. A generation
+// of 0 means the one before the current one.
+func (m *Manager) Rollback(packageName string, generation int) (target *Lockfile, err error) {
+	args := []string{packageName}
+	if generation != 0 {
+		args = append(args, strconv.Itoa(generation))
+	}
+	defer m.journal("rollback", args...)(&err)
+
+	current, err := m.Lock(packageName)
+	if err != nil {
+		return nil, err
+	}
+	if current.Generation == 0 {
+		return nil, fmt.Errorf("%s was not installed into a shelf generation, nothing to roll back to", packageName)
+	}
+
+	locks, err := m.Generations(packageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generations: %v", err)
+	}
+
+	var available []string
+	for _, lock := range locks {
+		available = append(available, strconv.Itoa(lock.Generation))
+		if generation == 0 && lock.Generation < current.Generation {
+			target = lock
+		} else if generation != 0 && lock.Generation == generation {
+			target = lock
+		}
+	}
+
+	if target == nil {
+		if generation == 0 {
+			return nil, fmt.Errorf("%s has no generation older than %d", packageName, current.Generation)
+		}
+		return nil, fmt.Errorf("%s has no generation %d (have: %s)", packageName, generation, strings.Join(available, ", "))
+	}
+	if target.Generation == current.Generation {
+		return nil, fmt.Errorf("%s is already at generation %d", packageName, generation)
+	}
+
+	if err := m.linkGeneration(target); err != nil {
+		return nil, err
+	}
+
+	// Links that only the newer generation had would dangle
+	var unlinked []string
+	for _, name := range current.Bins {
+		if !containsString(target.Bins, name) {
+			link := filepath.Join(m.binDir, name)
+			if os.Remove(link) == nil {
+				unlinked = append(unlinked, link)
+			}
+		}
+	}
+
+	// A hold is about the package, not the generation
+	target.Held = current.Held
+	if err := m.saveLock(target); err != nil {
+		return nil, fmt.Errorf("failed to restore lock: %v", err)
+	}
+	if err := m.restoreGenerationRecipe(target); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to restore the recipe of generation %d: %v\n", target.Generation, err)
+	}
+
+	if err := m.syncManifest(packageName, target, unlinked); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to update file manifest: %v\n", err)
+	}
+
+	return target, nil
+}
+
+// removeGenerations deletes every shelf generation of lock's package along
+// with the links pointing into them
+func (m *Manager) removeGenerations(lock *Lockfile) {
+	shelf := m.packageShelf(lock.Package)
+
+	for _, name := range lock.Bins {
+		link := filepath.Join(m.binDir, name)
+		if target, err := os.Readlink(link); err == nil && strings.HasPrefix(target, shelf+string(filepath.Separator)) {
+			if err := os.Remove(link); err != nil {
+				fmt.Fprintf(m.out, "warning: failed to remove %s: %v\n", link, err)
+			}
+		}
+	}
+
+	if err := os.RemoveAll(shelf); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to remove %s: %v\n", shelf, err)
+	}
+	os.RemoveAll(m.path("locks", lock.Package))
+}
@@ -0,0 +1,55 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import "fmt"
+
+// Hold keeps packageName out of updates until it is released with Unhold.
+// It reports whether the package was not already held.
+func (m *Manager) Hold(packageName string) (changed bool, err error) {
+	defer m.journal("hold", packageName)(&err)
+	return m.setHeld(packageName, true)
+}
+
+// Unhold lets packageName be updated again. It reports whether the package
+// was held.
+func (m *Manager) Unhold(packageName string) (changed bool, err error) {
+	defer m.journal("unhold", packageName)(&err)
+	return m.setHeld(packageName, false)
+}
+
+func (m *Manager) setHeld(packageName string, held bool) (bool, error) {
+	lock, err := m.Lock(packageName)
+	if err != nil {
+		return false, err
+	}
+
+	if lock.Held == held {
+		return false, nil
+	}
+
+	lock.Held = held
+	if err := m.saveLock(lock); err != nil {
+		return false, fmt.Errorf("failed to update lock file: %v", err)
+	}
+	return true, nil
+}
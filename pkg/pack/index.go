@@ -0,0 +1,245 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexFile is the name of the repository index at the repository root
+const indexFile = "index.box"
+
+// IndexEntry describes one recipe in a repository index
+type IndexEntry struct {
+	Name        string
+	Section     string
+	Version     string
+	Description string
+	License     string
+	// RecipeSHA256 is the recipe hash as calculated for lock files
+	RecipeSHA256 string
+	// Signature is the repository path of the recipe's .sig file, if any
+	Signature string
+}
+
+// Info returns the entry as PackageInfo
+func (e IndexEntry) Info() PackageInfo {
+	return PackageInfo{
+		Name:        e.Name,
+		Description: e.Description,
+		Version:     e.Version,
+		License:     e.License,
+	}
+}
+
+// RepoIndex lists every recipe in a repository so clients do not have to
+// probe for them one by one
+type RepoIndex struct {
+	GeneratedAt time.Time
+	Packages    []IndexEntry
+}
+
+// Lookup returns the entry for packageName
+func (idx *RepoIndex) Lookup(packageName string) (IndexEntry, bool) {
+	for _, entry := range idx.Packages {
+		if entry.Name == packageName {
+			return entry, true
+		}
+	}
+	return IndexEntry{}, false
+}
+
+// Marshal renders the index as a box file with one package block per recipe
+func (idx *RepoIndex) Marshal() []byte {
+	var b strings.Builder
+	b.WriteString("[data -c index]\n")
+	fmt.Fprintf(&b, "  generated_at %s\n", idx.GeneratedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "  count %d\n", len(idx.Packages))
+	b.WriteString("end\n")
+
+	for _, entry := range idx.Packages {
+		fields := [][2]string{
+			{"name", entry.Name},
+			{"section", entry.Section},
+			{"ver", entry.Version},
+			{"desc", entry.Description},
+			{"license", entry.License},
+			{"sha256", entry.RecipeSHA256},
+			{"sig", entry.Signature},
+		}
+
+		b.WriteString("\n[data -c package]\n")
+		for _, field := range fields {
+			if field[1] == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "  %s %s\n", field[0], quoteBoxValue(field[1]))
+		}
+		b.WriteString("end\n")
+	}
+
+	return []byte(b.String())
+}
+
+// ParseRepoIndex reads an index written by Marshal
+func ParseRepoIndex(content []byte) (*RepoIndex, error) {
+	file, err := ParseBox(content)
+	if err != nil {
+		return nil, err
+	}
+
+	header := file.Block("index")
+	if header == nil {
+		return nil, fmt.Errorf("no index data block found")
+	}
+
+	idx := &RepoIndex{}
+	if generatedAt := header.String("generated_at"); generatedAt != "" {
+		idx.GeneratedAt, err = time.Parse(time.RFC3339, generatedAt)
+		if err != nil {
+			return nil, &BoxSyntaxError{Line: header.Line, Msg: fmt.Sprintf("invalid generated_at %q", generatedAt)}
+		}
+	}
+
+	for _, block := range file.Blocks {
+		if block.Name != "package" {
+			continue
+		}
+
+		entry := IndexEntry{
+			Name:         block.String("name"),
+			Section:      block.String("section"),
+			Version:      block.String("ver"),
+			Description:  block.String("desc"),
+			License:      block.String("license"),
+			RecipeSHA256: block.String("sha256"),
+			Signature:    block.String("sig"),
+		}
+		if entry.Name == "" {
+			return nil, &BoxSyntaxError{Line: block.Line, Msg: "package block has no name"}
+		}
+		idx.Packages = append(idx.Packages, entry)
+	}
+
+	return idx, nil
+}
+
+// BuildRepoIndex reads every recipe in the repository at dir
+func BuildRepoIndex(dir string) (*RepoIndex, error) {
+	idx := &RepoIndex{GeneratedAt: time.Now().UTC()}
+
+	for _, recipe := range RepoRecipes(dir) {
+		rel, err := filepath.Rel(dir, recipe)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		entry := IndexEntry{
+			Name: strings.TrimSuffix(filepath.Base(recipe), ".box"),
+		}
+
+		// Recipes in a section live at section/name/name.box
+		if parts := strings.Split(rel, "/"); len(parts) == 3 {
+			entry.Section = parts[0]
+		}
+
+		block, err := recipeData(recipe)
+		if err != nil {
+			return nil, err
+		}
+		if name := block.String("name"); name != "" && name != entry.Name {
+			return nil, fmt.Errorf("%s: recipe name %q does not match its file name", rel, name)
+		}
+		entry.Version = block.String("ver")
+		entry.Description = block.String("desc")
+		entry.License = block.String("license")
+
+		if entry.RecipeSHA256, err = calculateRecipeVersion(recipe); err != nil {
+			return nil, fmt.Errorf("%s: %v", rel, err)
+		}
+
+		if _, err := os.Stat(recipe + ".sig"); err == nil {
+			entry.Signature = rel + ".sig"
+		}
+
+		idx.Packages = append(idx.Packages, entry)
+	}
+
+	sort.Slice(idx.Packages, func(i, j int) bool {
+		return idx.Packages[i].Name < idx.Packages[j].Name
+	})
+
+	return idx, nil
+}
+
+// WriteRepoIndex builds the index of the repository at dir, writes it to
+// index.box and signs it with privateKey
+func WriteRepoIndex(privateKey ed25519.PrivateKey, dir string) (*RepoIndex, error) {
+	idx, err := BuildRepoIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	indexPath := filepath.Join(dir, indexFile)
+	if err := os.WriteFile(indexPath, idx.Marshal(), publicFilePerms); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", indexFile, err)
+	}
+
+	if _, err := SignFile(privateKey, indexPath); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// verifyIndex checks the signature of a downloaded index against the
+// source's keys and parses it
+func (m *Manager) verifyIndex(source Source, provider RepoProvider, content []byte) (*RepoIndex, error) {
+	sigBytes, err := m.fetchBytes(provider.IndexURL() + ".sig")
+	if err != nil {
+		return nil, fmt.Errorf("index is not signed: %v", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 index signature: %v", err)
+	}
+
+	if err := m.verifyWithKeyChain(content, signature, source.URL, provider); err != nil {
+		return nil, fmt.Errorf("index %v", err)
+	}
+
+	idx, err := ParseRepoIndex(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index: %v", err)
+	}
+
+	return idx, nil
+}
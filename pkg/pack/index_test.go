@@ -0,0 +1,122 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRepoIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *RepoIndex
+		err     string
+	}{
+		{
+			name: "header and packages",
+			content: `[data -c index]
+  generated_at 2025-03-04T05:06:07Z
+  count 2
+end
+
+[data -c package]
+  name edith
+  section utils
+  ver 1.2.0
+  desc "a small text editor"
+  license GPL-3.0
+  sha256 abc123
+  sig c2ln
+end
+
+[data -c package]
+  name fisk
+end
+`,
+			want: &RepoIndex{
+				GeneratedAt: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC),
+				Packages: []IndexEntry{
+					{Name: "edith", Section: "utils", Version: "1.2.0", Description: "a small text editor", License: "GPL-3.0", RecipeSHA256: "abc123", Signature: "c2ln"},
+					{Name: "fisk"},
+				},
+			},
+		},
+		{
+			name:    "no generation time",
+			content: "[data -c index]\nend\n",
+			want:    &RepoIndex{},
+		},
+		{
+			name:    "bad generation time",
+			content: "[data -c index]\n  generated_at yesterday\nend\n",
+			err:     `line 1: invalid generated_at "yesterday"`,
+		},
+		{
+			name:    "package without a name",
+			content: "[data -c index]\nend\n[data -c package]\n  ver 1\nend\n",
+			err:     "line 3: package block has no name",
+		},
+		{
+			name:    "no index block",
+			content: "[data -c package]\n  name edith\nend\n",
+			err:     "no index data block found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx, err := ParseRepoIndex([]byte(tt.content))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(idx, tt.want) {
+				t.Errorf("index = %+v, want %+v", idx, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepoIndexRoundTrip(t *testing.T) {
+	idx := &RepoIndex{
+		GeneratedAt: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC),
+		Packages: []IndexEntry{
+			{Name: "edith", Section: "utils", Version: "1.2.0", Description: `edits "text" # quickly`},
+			{Name: "fisk", Description: "line one\nline two"},
+		},
+	}
+	got, err := ParseRepoIndex(idx.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, idx) {
+		t.Errorf("round trip = %+v, want %+v", got, idx)
+	}
+}
@@ -0,0 +1,394 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstallOptions controls how packages are installed
+type InstallOptions struct {
+	// Verbose announces the script run in more detail
+	Verbose bool
+	// Jobs is how many recipes may run at once, one at a time when zero.
+	// Their output is interleaved when more than one runs.
+	Jobs int
+}
+
+// InstallResult describes one installed package
+type InstallResult struct {
+	Package  string
+	Source   PackageSource
+	Verified bool
+	Lock     *Lockfile
+}
+
+// BatchResult describes the outcome of InstallAll
+type BatchResult struct {
+	AlreadyInstalled []string
+	Installed        []*InstallResult
+	Failed           []*PackageError
+	// Skipped packages were not tried because a dependency failed
+	Skipped []*PackageError
+}
+
+// Install downloads, verifies, reviews and runs the recipe for packageName,
+// then writes its lock file. packageName may be pinned to a git ref as
+// name@ref. Missing dependencies are installed first once the prompter
+// confirms the plan.
+func (m *Manager) Install(packageName string, opts InstallOptions) (result *InstallResult, err error) {
+	defer m.journal("install", packageName)(&err)
+
+	plan, err := m.plan([]string{packageName}, true)
+	if err != nil {
+		return nil, err
+	}
+	packageName, _, _ = SplitPin(packageName)
+
+	if len(plan.Steps) > 1 {
+		m.printPlan(plan)
+		ok, err := m.prompt.Confirm(fmt.Sprintf("\nInstall %d package(s)?", len(plan.Steps)))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrCancelled
+		}
+		fmt.Fprintln(m.out)
+	}
+
+	batch := &BatchResult{}
+	m.runPlan(plan, opts, batch)
+	for _, installed := range batch.Installed {
+		if installed.Package == packageName {
+			return installed, nil
+		}
+	}
+	for _, pkgErr := range batch.Failed {
+		if pkgErr.Package == packageName {
+			return nil, pkgErr.Err
+		}
+	}
+	return nil, fmt.Errorf("dependencies of %s were not installed", packageName)
+}
+
+// InstallAll installs several packages and their missing dependencies in
+// dependency order once the prompter confirms the plan. Packages that are
+// already installed are skipped, and a failure skips only the packages
+// that depend on it.
+func (m *Manager) InstallAll(packageNames []string, opts InstallOptions) (batch *BatchResult, err error) {
+	defer m.journal("install", packageNames...)(&err)
+
+	fmt.Fprintf(m.out, "Planning to install %d package(s): %s\n", len(packageNames), strings.Join(packageNames, ", "))
+
+	plan, err := m.Plan(packageNames)
+	if err != nil {
+		return nil, err
+	}
+
+	batch = &BatchResult{AlreadyInstalled: plan.AlreadyInstalled}
+
+	// Show status
+	if len(batch.AlreadyInstalled) > 0 {
+		fmt.Fprintf(m.out, "Already installed: %s\n", strings.Join(batch.AlreadyInstalled, ", "))
+		for _, packageName := range batch.AlreadyInstalled {
+			m.markExplicit(packageName)
+		}
+	}
+
+	if len(plan.Steps) == 0 {
+		return batch, nil
+	}
+
+	m.printPlan(plan)
+
+	// Ask for confirmation
+	ok, err := m.prompt.Confirm(fmt.Sprintf("\nInstall %d package(s)?", len(plan.Steps)))
+	if err != nil {
+		return batch, err
+	}
+	if !ok {
+		return batch, ErrCancelled
+	}
+
+	fmt.Fprintf(m.out, "\nInstalling %d package(s)...\n\n", len(plan.Steps))
+	m.runPlan(plan, opts, batch)
+
+	return batch, nil
+}
+
+// verifyAndReview checks the recipe signature, asking whether to continue
+// when it does not verify, and then hands the recipe to the prompter for
+// review. action names what is cancelled if verification is refused.
+func (m *Manager) verifyAndReview(packageName, scriptPath, sourceRepo, action string) (bool, error) {
+	// verify recipe integrity
+	fmt.Fprintln(m.out, "verifying recipe integrity...")
+	verifyErr := m.VerifyRecipe(scriptPath, sourceRepo)
+	if verifyErr == nil {
+		fmt.Fprintln(m.out, "✓ recipe integrity verified")
+	}
+
+	return m.review(packageName, scriptPath, verifyErr, action)
+}
+
+// review asks whether to continue when verifyErr says the recipe did not
+// verify and then hands it to the prompter. It reports whether the recipe
+// was verified.
+func (m *Manager) review(packageName, scriptPath string, verifyErr error, action string) (bool, error) {
+	verified := verifyErr == nil
+	if !verified {
+		fmt.Fprintf(m.out, "⚠️  warning: %v\n", verifyErr)
+		ok, err := m.prompt.ConfirmUnverified("continue anyway?")
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, fmt.Errorf("%s cancelled due to verification failure", action)
+		}
+	}
+
+	// What gets stored and hashed is the recipe as fetched, not what the
+	// review may turn it into
+	if err := keepFetchedRecipe(scriptPath); err != nil {
+		return false, fmt.Errorf("failed to keep a copy of the recipe: %v", err)
+	}
+
+	// Show recipe and get user confirmation
+	ok, err := m.prompt.Review(packageName, scriptPath)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, ErrCancelled
+	}
+
+	return verified, nil
+}
+
+// runRecipe runs the recipe at scriptPath for step with a staging
+// directory to install into. A non-empty stage is checked, renamed into a
+// new shelf generation and its binaries linked; if anything fails on the
+// way the stage is thrown away and nothing else is touched. It returns the
+// generation, or 0 when the recipe installed elsewhere. What it wrote is
+// recorded in the package's file manifest.
+func (m *Manager) runRecipe(step *PlanStep, action, tempDir, scriptPath string) (generation int, err error) {
+	block, blockErr := recipeData(scriptPath)
+	var bins []string
+	if blockErr == nil {
+		bins = recipeList(block, "bin")
+	}
+
+	roots := m.managedPaths(step.Package, bins)
+	before := snapshot(roots)
+	defer func() {
+		if err != nil {
+			return
+		}
+		if err := m.recordManifest(step.Package, roots, before, step.foreign); err != nil {
+			fmt.Fprintf(m.out, "warning: failed to record installed files: %v\n", err)
+		}
+	}()
+
+	stageDir := filepath.Join(tempDir, "pack-stage")
+	if err := os.MkdirAll(stageDir, publicDirPerms); err != nil {
+		return 0, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	defer os.RemoveAll(stageDir)
+
+	generation, err = m.nextGeneration(step.Package)
+	if err != nil {
+		return 0, err
+	}
+	genDir := m.generationDir(step.Package, generation)
+
+	env := append(pinEnv(step.Ref), stageEnvVar+"="+stageDir, shelfEnvVar+"="+genDir)
+	if err := m.runLogged(step.Package, action, tempDir, env, scriptPath); err != nil {
+		return 0, fmt.Errorf("script execution failed: %v", err)
+	}
+	// The recipe fetches and installs in one run, so a wrong checkout can
+	// only be caught afterwards. Take back what it put outside the stage.
+	if step.Ref != "" {
+		if err := checkPinnedCheckout(scriptPath, step.Ref); err != nil {
+			removeCreated(roots, before)
+			return 0, err
+		}
+	}
+
+	// Recipes that manage the shelf themselves leave the stage empty
+	if isEmptyDir(stageDir) {
+		return 0, nil
+	}
+
+	if blockErr != nil {
+		return 0, blockErr
+	}
+	if err := checkStage(stageDir, bins); err != nil {
+		return 0, fmt.Errorf("staged install is incomplete: %v", err)
+	}
+
+	if err := os.MkdirAll(m.packageShelf(step.Package), publicDirPerms); err != nil {
+		return 0, fmt.Errorf("failed to create shelf: %v", err)
+	}
+	if err := os.Rename(stageDir, genDir); err != nil {
+		return 0, fmt.Errorf("failed to move staged install into the shelf: %v", err)
+	}
+
+	if err := m.linkGeneration(&Lockfile{ShelfPath: genDir, Bins: bins}); err != nil {
+		// Put back whatever links the previous generation had
+		if previous, lockErr := m.Lock(step.Package); lockErr == nil && previous.Generation > 0 {
+			m.linkGeneration(previous)
+		}
+		os.RemoveAll(genDir)
+		return 0, err
+	}
+
+	return generation, nil
+}
+
+// writeLock records where step's package came from after its recipe ran
+// into generation
+func (m *Manager) writeLock(step *PlanStep, scriptPath string, generation int) (*Lockfile, error) {
+	lock, err := m.buildLock(step, scriptPath, generation)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.saveLock(lock); err != nil {
+		return nil, err
+	}
+
+	// Keep what ran so close and update do not depend on the source
+	if err := m.storeRecipe(step.Package, scriptPath); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to store recipe: %v\n", err)
+	}
+
+	if generation > 0 {
+		if err := m.saveGeneration(lock); err != nil {
+			fmt.Fprintf(m.out, "warning: failed to save shelf generation: %v\n", err)
+		}
+	}
+
+	return lock, nil
+}
+
+// discardGeneration takes back generation of packageName when its lock
+// could not be written: previous, if there was one, is linked again and the
+// manifest goes back to describing it
+func (m *Manager) discardGeneration(packageName string, generation int, previous *Lockfile) {
+	// Recipes that manage the shelf themselves leave nothing to take back
+	if generation == 0 {
+		return
+	}
+	genDir := m.generationDir(packageName, generation)
+
+	if previous != nil {
+		m.linkGeneration(previous)
+	}
+	// Bins only the discarded generation had
+	entries, _ := os.ReadDir(m.binDir)
+	for _, entry := range entries {
+		link := filepath.Join(m.binDir, entry.Name())
+		if target, err := os.Readlink(link); err == nil && underAny(target, []string{genDir}) {
+			os.Remove(link)
+		}
+	}
+	os.RemoveAll(genDir)
+
+	if previous == nil {
+		os.Remove(m.manifestPath(packageName))
+		// Only removed if nothing else is there
+		os.Remove(filepath.Dir(m.manifestPath(packageName)))
+		os.Remove(m.packageShelf(packageName))
+		return
+	}
+	if err := m.syncManifest(packageName, previous, []string{genDir}); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to update file manifest: %v\n", err)
+	}
+}
+
+// buildLock returns the lock writeLock would save for step
+func (m *Manager) buildLock(step *PlanStep, scriptPath string, generation int) (*Lockfile, error) {
+	packageName, selectedSource := step.Package, step.Source
+	lock, err := m.newLock(packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	lock.Pinned = step.Ref != ""
+	// A hold outlives updates and reinstalls until it is released
+	if previous, err := m.Lock(packageName); err == nil {
+		lock.Held = previous.Held
+	}
+	lock.InstallReason = ReasonExplicit
+	if !step.Requested {
+		lock.InstallReason = ReasonDependency
+	}
+	if block, err := recipeData(scriptPath); err == nil {
+		lock.Deps = recipeList(block, "deps")
+		lock.Provides = recipeProvides(packageName, block)
+		lock.Conflicts = recipeList(block, "conflicts")
+		if bins := recipeList(block, "bin"); len(bins) > 0 {
+			lock.SymlinkPath = filepath.Join(m.binDir, bins[0])
+			lock.Bins = bins
+		}
+	}
+	if generation > 0 {
+		lock.Generation = generation
+		lock.ShelfPath = m.generationDir(packageName, generation)
+	}
+
+	// Extract source information based on the standard
+	lock.SrcType, lock.SrcURL, lock.SrcRef, lock.SrcRefUsed, err = detectSourceTypeAndVersion(scriptPath, step.Ref)
+	if err != nil {
+		fmt.Fprintf(m.out, "warning: failed to extract source info: %v\n", err)
+		// Fall back to legacy extraction
+		lock.SrcURL, err = extractRecipeURL(scriptPath)
+		if err != nil {
+			fmt.Fprintf(m.out, "warning: failed to extract source URL: %v\n", err)
+			lock.SrcURL = "unknown"
+		}
+		lock.SrcType = "unknown"
+		lock.SrcRefUsed = "unknown"
+		lock.SrcRef = "unknown"
+	}
+	// What the recipe actually checked out beats what the ref resolves to
+	if _, commit, ok := recipeCheckout(scriptPath); ok {
+		lock.SrcRefUsed = shortCommit(commit)
+	}
+
+	// Calculate recipe version (content hash without the c-sha256 field)
+	// of the recipe as it was fetched, which is the one that gets stored
+	lock.RecipeSHA256, err = calculateRecipeVersion(recipeAsFetched(scriptPath))
+	if err != nil {
+		fmt.Fprintf(m.out, "warning: failed to calculate recipe version: %v\n", err)
+		lock.RecipeSHA256 = "unknown"
+	}
+
+	// Construct recipe URL from selected source
+	lock.RecipeURL = constructRecipeURL(selectedSource)
+	lock.Repo = selectedSource.Name
+
+	return lock, nil
+}
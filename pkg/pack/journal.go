@@ -0,0 +1,532 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transaction is one operation recorded in the history journal along with
+// every lock and the sources it changed
+type Transaction struct {
+	ID     int
+	Time   time.Time
+	User   string
+	Action string
+	Args   []string
+	// Error is set when the operation failed part way through
+	Error   string
+	Changes []*PackageChange
+	// SourcesChanged is set when sources.box was different afterwards
+	SourcesChanged bool
+
+	dir string
+}
+
+// PackageChange is a package's lock before and after a transaction, nil
+// where it wasn't installed
+type PackageChange struct {
+	Package string
+	Before  *Lockfile
+	After   *Lockfile
+}
+
+// Fields lists the lock fields the transaction changed
+func (c *PackageChange) Fields() []FieldChange {
+	before, after := c.Before, c.After
+	if before == nil {
+		before = &Lockfile{}
+	}
+	if after == nil {
+		after = &Lockfile{}
+	}
+	return lockFieldChanges(before, after)
+}
+
+// packState is the raw content of every lock and of sources.box
+type packState struct {
+	locks   map[string][]byte
+	sources []byte
+}
+
+func (m *Manager) historyPath() string {
+	return m.path("history")
+}
+
+// readState reads the locks and sources a transaction could change
+func (m *Manager) readState() packState {
+	state := packState{locks: make(map[string][]byte)}
+	entries, _ := os.ReadDir(m.path("locks"))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lock") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(m.path("locks"), entry.Name()))
+		if err == nil {
+			state.locks[strings.TrimSuffix(entry.Name(), ".lock")] = content
+		}
+	}
+	state.sources, _ = os.ReadFile(m.sourcesFile())
+	return state
+}
+
+// journal starts recording a transaction and returns the function that
+// finishes it, to be deferred with a pointer to the operation's error.
+// Operations started inside another one belong to the outer transaction.
+func (m *Manager) journal(action string, args ...string) func(*error) {
+	if m.inTransaction {
+		return func(*error) {}
+	}
+	m.inTransaction = true
+	before := m.readState()
+	started := time.Now().UTC()
+
+	return func(errp *error) {
+		m.inTransaction = false
+		txn := &Transaction{Time: started, User: currentUser(), Action: action, Args: args}
+		if errp != nil && *errp != nil {
+			txn.Error = (*errp).Error()
+		}
+		if err := m.recordTransaction(txn, before, m.readState()); err != nil {
+			fmt.Fprintf(m.out, "warning: failed to record history: %v\n", err)
+		}
+	}
+}
+
+// currentUser names who ran pack, and who they sudo'd from if they did
+func currentUser() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		name += " (via sudo from " + sudoUser + ")"
+	}
+	return name
+}
+
+// recordTransaction writes txn to the journal if anything changed between
+// before and after. Each transaction gets a directory named by its id
+// holding entry.box and the before and after copies of what changed.
+func (m *Manager) recordTransaction(txn *Transaction, before, after packState) error {
+	var changed []string
+	for name, content := range before.locks {
+		if !bytes.Equal(content, after.locks[name]) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range after.locks {
+		if _, ok := before.locks[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	txn.SourcesChanged = !bytes.Equal(before.sources, after.sources)
+
+	if len(changed) == 0 && !txn.SourcesChanged {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.historyPath(), publicDirPerms); err != nil {
+		return err
+	}
+	ids, err := m.transactionIDs()
+	if err != nil {
+		return err
+	}
+	txn.ID = 1
+	if len(ids) > 0 {
+		txn.ID = ids[len(ids)-1] + 1
+	}
+
+	// Another pack may be writing its own transaction at the same time
+	for {
+		txn.dir = filepath.Join(m.historyPath(), strconv.Itoa(txn.ID))
+		err := os.Mkdir(txn.dir, publicDirPerms)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		txn.ID++
+	}
+
+	for _, side := range []string{"before", "after"} {
+		if err := os.Mkdir(filepath.Join(txn.dir, side), publicDirPerms); err != nil {
+			return err
+		}
+	}
+	for _, name := range changed {
+		if content, ok := before.locks[name]; ok {
+			if err := os.WriteFile(filepath.Join(txn.dir, "before", name+".lock"), content, publicFilePerms); err != nil {
+				return err
+			}
+		}
+		if content, ok := after.locks[name]; ok {
+			if err := os.WriteFile(filepath.Join(txn.dir, "after", name+".lock"), content, publicFilePerms); err != nil {
+				return err
+			}
+		}
+	}
+	if txn.SourcesChanged {
+		if err := os.WriteFile(filepath.Join(txn.dir, "before", "sources.box"), before.sources, publicFilePerms); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(txn.dir, "after", "sources.box"), after.sources, publicFilePerms); err != nil {
+			return err
+		}
+	}
+
+	// entry.box goes last so a half written transaction is never read
+	var b strings.Builder
+	b.WriteString("[data -c transaction]\n")
+	fmt.Fprintf(&b, "  id %d\n", txn.ID)
+	fmt.Fprintf(&b, "  time %s\n", txn.Time.Format(time.RFC3339))
+	fmt.Fprintf(&b, "  user %s\n", quoteBoxValue(txn.User))
+	fmt.Fprintf(&b, "  action %s\n", txn.Action)
+	if len(txn.Args) > 0 {
+		quoted := make([]string, len(txn.Args))
+		for i, arg := range txn.Args {
+			quoted[i] = quoteBoxValue(arg)
+		}
+		fmt.Fprintf(&b, "  args %s\n", strings.Join(quoted, " "))
+	}
+	if len(changed) > 0 {
+		fmt.Fprintf(&b, "  packages %s\n", strings.Join(changed, " "))
+	}
+	if txn.SourcesChanged {
+		b.WriteString("  sources changed\n")
+	}
+	if txn.Error != "" {
+		fmt.Fprintf(&b, "  error %s\n", quoteBoxValue(txn.Error))
+	}
+	b.WriteString("end\n")
+
+	return os.WriteFile(filepath.Join(txn.dir, "entry.box"), []byte(b.String()), publicFilePerms)
+}
+
+// transactionIDs lists the ids in the journal, oldest first
+func (m *Manager) transactionIDs() ([]int, error) {
+	entries, err := os.ReadDir(m.historyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		if id, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// History returns every recorded transaction, oldest first
+func (m *Manager) History() ([]*Transaction, error) {
+	ids, err := m.transactionIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+
+	var history []*Transaction
+	for _, id := range ids {
+		txn, err := m.readTransaction(id)
+		if err != nil {
+			continue
+		}
+		history = append(history, txn)
+	}
+	return history, nil
+}
+
+// Transaction returns transaction id from the journal, or the newest one
+// when id is 0
+func (m *Manager) Transaction(id int) (*Transaction, error) {
+	if id == 0 {
+		history, err := m.History()
+		if err != nil {
+			return nil, err
+		}
+		if len(history) == 0 {
+			return nil, fmt.Errorf("history is empty")
+		}
+		return history[len(history)-1], nil
+	}
+
+	txn, err := m.readTransaction(id)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no transaction %d in history", id)
+	}
+	return txn, err
+}
+
+func (m *Manager) readTransaction(id int) (*Transaction, error) {
+	dir := filepath.Join(m.historyPath(), strconv.Itoa(id))
+	file, err := ParseBoxFile(filepath.Join(dir, "entry.box"))
+	if err != nil {
+		return nil, err
+	}
+	block := file.Block("transaction")
+	if block == nil {
+		return nil, fmt.Errorf("transaction %d: no transaction data block found", id)
+	}
+
+	txn := &Transaction{ID: id, dir: dir}
+	var packages []string
+	for _, field := range block.Fields {
+		switch field.Key {
+		case "time":
+			txn.Time, _ = time.Parse(time.RFC3339, field.Value())
+		case "user":
+			txn.User = field.Value()
+		case "action":
+			txn.Action = field.Value()
+		case "args":
+			txn.Args = field.Values
+		case "packages":
+			packages = field.Values
+		case "sources":
+			txn.SourcesChanged = true
+		case "error":
+			txn.Error = field.Value()
+		}
+	}
+
+	for _, name := range packages {
+		change := &PackageChange{Package: name}
+		change.Before, _ = ReadLockfile(filepath.Join(dir, "before", name+".lock"))
+		change.After, _ = ReadLockfile(filepath.Join(dir, "after", name+".lock"))
+		txn.Changes = append(txn.Changes, change)
+	}
+	return txn, nil
+}
+
+// How Undo puts a package back
+const (
+	undoUninstall = iota
+	undoSources
+	undoRestore
+	undoReinstall
+)
+
+// undoStep is one thing Undo does, in the order of its kind
+type undoStep struct {
+	kind   int
+	change *PackageChange
+}
+
+func (s undoStep) String() string {
+	switch s.kind {
+	case undoUninstall:
+		return "close " + s.change.Package
+	case undoSources:
+		return "restore sources.box"
+	case undoReinstall:
+		if s.change.Before.Pinned && s.change.Before.SrcRef != "" {
+			return fmt.Sprintf("reopen %s@%s", s.change.Package, s.change.Before.SrcRef)
+		}
+		return "reopen " + s.change.Package
+	}
+	if s.change.Before.Generation != s.change.After.Generation {
+		return fmt.Sprintf("roll %s back to generation %d", s.change.Package, s.change.Before.Generation)
+	}
+	return "restore the lock of " + s.change.Package
+}
+
+// undoSteps works out how to reverse txn. Anything that has changed again
+// since is left alone and reported.
+func (m *Manager) undoSteps(txn *Transaction) ([]undoStep, []*PackageError) {
+	current := m.readState()
+	var steps []undoStep
+	var stuck []*PackageError
+
+	if txn.SourcesChanged {
+		after, _ := os.ReadFile(filepath.Join(txn.dir, "after", "sources.box"))
+		if bytes.Equal(after, current.sources) {
+			steps = append(steps, undoStep{kind: undoSources})
+		} else {
+			stuck = append(stuck, &PackageError{Package: "sources.box", Err: fmt.Errorf("changed since transaction %d", txn.ID)})
+		}
+	}
+
+	for _, change := range txn.Changes {
+		after, _ := os.ReadFile(filepath.Join(txn.dir, "after", change.Package+".lock"))
+		now, installed := current.locks[change.Package]
+		if (change.After == nil && installed) || (change.After != nil && !bytes.Equal(after, now)) {
+			stuck = append(stuck, &PackageError{Package: change.Package, Err: fmt.Errorf("changed since transaction %d", txn.ID)})
+			continue
+		}
+
+		switch {
+		case change.Before == nil && change.After == nil:
+			continue
+		case change.Before == nil:
+			steps = append(steps, undoStep{kind: undoUninstall, change: change})
+		case change.After == nil:
+			steps = append(steps, undoStep{kind: undoReinstall, change: change})
+		case change.Before.Generation == change.After.Generation &&
+			change.Before.RecipeSHA256 == change.After.RecipeSHA256 &&
+			change.Before.SrcRefUsed == change.After.SrcRefUsed:
+			// Only the lock itself changed, like a hold
+			steps = append(steps, undoStep{kind: undoRestore, change: change})
+		case change.Before.Generation > 0 && isDir(m.generationDir(change.Package, change.Before.Generation)):
+			steps = append(steps, undoStep{kind: undoRestore, change: change})
+		default:
+			stuck = append(stuck, &PackageError{Package: change.Package, Err: fmt.Errorf("the earlier build is no longer on the shelf")})
+		}
+	}
+
+	// Dependents are closed before their dependencies
+	sort.SliceStable(steps, func(i, j int) bool {
+		if steps[i].kind != steps[j].kind {
+			return steps[i].kind < steps[j].kind
+		}
+		if steps[i].kind == undoUninstall {
+			return steps[i].change.After.InstallReason != ReasonDependency && steps[j].change.After.InstallReason == ReasonDependency
+		}
+		return false
+	})
+	return steps, stuck
+}
+
+// isDir reports whether path is a directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isFile reports whether path is a regular file
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Undo reverses transaction id, or the newest one when id is 0, once the
+// prompter confirms. Packages it installed are closed, ones it closed are
+// reopened from their source, and updates and rollbacks go back to the
+// earlier shelf generation. Packages that have changed again since, or
+// whose earlier build is gone, are left alone and returned as failed. The
+// undo is itself recorded as a transaction.
+func (m *Manager) Undo(id int) (txn *Transaction, failed []*PackageError, err error) {
+	txn, err = m.Transaction(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	steps, failed := m.undoSteps(txn)
+	for _, pkgErr := range failed {
+		fmt.Fprintf(m.out, "⚠️  can't undo %v\n", pkgErr)
+	}
+	if len(steps) == 0 {
+		return txn, failed, fmt.Errorf("nothing in transaction %d can be undone", txn.ID)
+	}
+
+	fmt.Fprintf(m.out, "undoing transaction %d (%s):\n", txn.ID, strings.TrimSpace(txn.Action+" "+strings.Join(txn.Args, " ")))
+	for _, step := range steps {
+		fmt.Fprintf(m.out, "- %s\n", step)
+	}
+	ok, err := m.prompt.Confirm("\ncontinue?")
+	if err != nil {
+		return txn, failed, err
+	}
+	if !ok {
+		return txn, failed, ErrCancelled
+	}
+
+	defer m.journal("undo", strconv.Itoa(txn.ID))(&err)
+
+	for _, step := range steps {
+		if err := m.undoStep(txn, step); err != nil {
+			name := "sources.box"
+			if step.change != nil {
+				name = step.change.Package
+			}
+			fmt.Fprintf(m.out, "✗ %s: %v\n", step, err)
+			failed = append(failed, &PackageError{Package: name, Err: err})
+		}
+	}
+
+	if len(failed) > 0 {
+		err = fmt.Errorf("%d change(s) in transaction %d could not be undone", len(failed), txn.ID)
+	}
+	return txn, failed, err
+}
+
+func (m *Manager) undoStep(txn *Transaction, step undoStep) error {
+	switch step.kind {
+	case undoSources:
+		content, err := os.ReadFile(filepath.Join(txn.dir, "before", "sources.box"))
+		if err != nil {
+			return err
+		}
+		tmp := m.sourcesFile() + ".tmp"
+		if err := os.WriteFile(tmp, content, publicFilePerms); err != nil {
+			return err
+		}
+		return os.Rename(tmp, m.sourcesFile())
+
+	case undoUninstall:
+		return m.Uninstall(step.change.Package)
+
+	case undoReinstall:
+		before := step.change.Before
+		name := step.change.Package
+		if before.Pinned && before.SrcRef != "" {
+			name += "@" + before.SrcRef
+		}
+		result, err := m.Install(name, InstallOptions{})
+		if err != nil {
+			return err
+		}
+		if result.Lock == nil {
+			return fmt.Errorf("%s was reopened but has no lock", step.change.Package)
+		}
+		if before.SrcRefUsed != "" && result.Lock.SrcRefUsed != before.SrcRefUsed {
+			fmt.Fprintf(m.out, "note: %s was at %s before, reopened at %s\n", step.change.Package, before.SrcRefUsed, result.Lock.SrcRefUsed)
+		}
+		// Keep why it was installed and whether it was held
+		result.Lock.InstallReason = before.InstallReason
+		result.Lock.Held = before.Held
+		return m.saveLock(result.Lock)
+	}
+
+	before := step.change.Before
+	if before.Generation != step.change.After.Generation {
+		if _, err := m.Rollback(step.change.Package, before.Generation); err != nil {
+			return err
+		}
+	}
+	return m.saveLock(before)
+}
@@ -0,0 +1,147 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUndoSteps(t *testing.T) {
+	lock := func(name string, generation int, reason string) *Lockfile {
+		return &Lockfile{Package: name, Repo: "local", Generation: generation, RecipeSHA256: name + "-sha", InstallReason: reason}
+	}
+	updated := func(name string, generation int) *Lockfile {
+		l := lock(name, generation, ReasonExplicit)
+		l.RecipeSHA256 += "2"
+		return l
+	}
+	held := func(l *Lockfile) *Lockfile {
+		l.Held = true
+		return l
+	}
+
+	tests := []struct {
+		name    string
+		changes []*PackageChange
+		// current locks that differ from what the transaction left
+		current map[string]*Lockfile
+		// generations still on the shelf
+		shelf []string
+		steps []string
+		stuck []string
+	}{
+		{
+			name:    "opened packages are closed, dependencies last",
+			changes: []*PackageChange{{Package: "lib", After: lock("lib", 1, ReasonDependency)}, {Package: "app", After: lock("app", 1, ReasonExplicit)}},
+			steps:   []string{"close app", "close lib"},
+		},
+		{
+			name:    "closed packages are reopened after anything is closed",
+			changes: []*PackageChange{{Package: "old", Before: lock("old", 1, ReasonExplicit)}, {Package: "new", After: lock("new", 1, ReasonExplicit)}},
+			steps:   []string{"close new", "reopen old"},
+		},
+		{
+			name:    "pinned packages are reopened at their pin",
+			changes: []*PackageChange{{Package: "old", Before: &Lockfile{Package: "old", Pinned: true, SrcRef: "v1"}}},
+			steps:   []string{"reopen old@v1"},
+		},
+		{
+			name:    "a hold only restores the lock",
+			changes: []*PackageChange{{Package: "app", Before: lock("app", 1, ReasonExplicit), After: held(lock("app", 1, ReasonExplicit))}},
+			steps:   []string{"restore the lock of app"},
+		},
+		{
+			name:    "an update rolls back while the generation is kept",
+			changes: []*PackageChange{{Package: "app", Before: lock("app", 1, ReasonExplicit), After: updated("app", 2)}},
+			shelf:   []string{"app/1"},
+			steps:   []string{"roll app back to generation 1"},
+		},
+		{
+			name:    "an update whose generation was pruned is stuck",
+			changes: []*PackageChange{{Package: "app", Before: lock("app", 1, ReasonExplicit), After: updated("app", 2)}},
+			stuck:   []string{"app: the earlier build is no longer on the shelf"},
+		},
+		{
+			name:    "packages changed since are left alone",
+			changes: []*PackageChange{{Package: "app", Before: lock("app", 1, ReasonExplicit), After: updated("app", 2)}},
+			current: map[string]*Lockfile{"app": updated("app", 3)},
+			shelf:   []string{"app/1"},
+			stuck:   []string{"app: changed since transaction 7"},
+		},
+		{
+			name:    "closed packages opened again since are left alone",
+			changes: []*PackageChange{{Package: "old", Before: lock("old", 1, ReasonExplicit)}},
+			current: map[string]*Lockfile{"old": lock("old", 2, ReasonExplicit)},
+			stuck:   []string{"old: changed since transaction 7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testManager(t)
+			txn := &Transaction{ID: 7, Changes: tt.changes, dir: t.TempDir()}
+			if err := os.MkdirAll(filepath.Join(txn.dir, "after"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, change := range tt.changes {
+				if change.After == nil {
+					continue
+				}
+				if err := change.After.Write(filepath.Join(txn.dir, "after", change.Package+".lock")); err != nil {
+					t.Fatal(err)
+				}
+				if err := change.After.Write(m.getLockFilePath(change.Package)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, l := range tt.current {
+				if err := l.Write(m.getLockFilePath(name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, dir := range tt.shelf {
+				if err := os.MkdirAll(filepath.Join(m.shelfPath(), dir), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			steps, stuck := m.undoSteps(txn)
+			var gotSteps, gotStuck []string
+			for _, step := range steps {
+				gotSteps = append(gotSteps, step.String())
+			}
+			for _, pkgErr := range stuck {
+				gotStuck = append(gotStuck, pkgErr.Package+": "+pkgErr.Err.Error())
+			}
+			if strings.Join(gotSteps, "; ") != strings.Join(tt.steps, "; ") {
+				t.Errorf("steps = %q, want %q", gotSteps, tt.steps)
+			}
+			if strings.Join(gotStuck, "; ") != strings.Join(tt.stuck, "; ") {
+				t.Errorf("stuck = %q, want %q", gotStuck, tt.stuck)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// KeyMetadata represents the metadata from a pack.box key file
+type KeyMetadata struct {
+	Version   int
+	IssuedAt  int64
+	ExpiresAt int64
+	Algorithm string
+	Key       string
+}
+
+// keyCachePath returns the cache file for sourceRepo's key with ext
+func (m *Manager) keyCachePath(sourceRepo, ext string) string {
+	// Create a safe filename from the source repo URL
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourceRepo)))
+	return m.path("cache", "keys", hash+ext)
+}
+
+// fetchPublicKeyFromRepo fetches the public key from the repository's keys directory
+func (m *Manager) fetchPublicKeyFromRepo(sourceRepo string) (string, error) {
+	// Skip fetching for local sources
+	if sourceRepo == "local" {
+		return "", fmt.Errorf("local sources don't have remote keys")
+	}
+
+	// Try cached key first (with version checking)
+	if cachedKey, version, err := m.getCachedPublicKeyWithVersion(sourceRepo); err == nil {
+		// Check if we need to refresh (for automatic cache invalidation)
+		if m.shouldRefreshKey(sourceRepo, version) {
+			// Try to fetch new version in background, but use cached for now
+			go m.backgroundKeyRefresh(sourceRepo)
+		}
+		return cachedKey, nil
+	}
+
+	provider, err := m.provider(sourceRepo)
+	if err != nil {
+		return "", err
+	}
+
+	return m.fetchPublicKeyWithProvider(sourceRepo, provider)
+}
+
+// fetchPublicKeyWithProvider fetches sourceRepo's key through provider,
+// bypassing the cache
+func (m *Manager) fetchPublicKeyWithProvider(sourceRepo string, provider RepoProvider) (string, error) {
+	// Try new .box format first
+	if keyData, err := m.fetchKeyMetadata(sourceRepo, provider); err == nil {
+		return keyData.Key, nil
+	}
+
+	// Fallback to legacy .pub format
+	return m.fetchLegacyPublicKey(sourceRepo, provider)
+}
+
+// fetchKeyMetadata fetches key metadata from the new .box format
+func (m *Manager) fetchKeyMetadata(sourceRepo string, provider RepoProvider) (*KeyMetadata, error) {
+	keyURL := provider.KeyURL("pack.box")
+
+	content, err := m.fetchBytes(keyURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key metadata: %v", err)
+	}
+
+	// Parse the .box file using Box parser
+	metadata, err := parseKeyMetadata(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key metadata: %v", err)
+	}
+
+	// Validate key format
+	if len(metadata.Key) != 44 || !strings.HasSuffix(metadata.Key, "=") {
+		return nil, fmt.Errorf("invalid public key format in metadata")
+	}
+
+	// Cache the key with version info
+	if err := m.cachePublicKeyWithVersion(sourceRepo, metadata); err != nil {
+		// Don't fail on cache errors, just log them
+		fmt.Fprintf(m.out, "Warning: failed to cache public key: %v\n", err)
+	}
+
+	return metadata, nil
+}
+
+// fetchLegacyPublicKey fetches from the old .pub format (fallback)
+func (m *Manager) fetchLegacyPublicKey(sourceRepo string, provider RepoProvider) (string, error) {
+	keyURL := provider.KeyURL("pack.pub")
+
+	keyBytes, err := m.fetchBytes(keyURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch legacy public key: %v", err)
+	}
+
+	pubkey := strings.TrimSpace(string(keyBytes))
+
+	// Validate key format
+	if len(pubkey) != 44 || !strings.HasSuffix(pubkey, "=") {
+		return "", fmt.Errorf("invalid legacy public key format")
+	}
+
+	// Cache as legacy key (version 0)
+	if err := m.cachePublicKeyWithVersion(sourceRepo, legacyKeyMetadata(pubkey)); err != nil {
+		fmt.Fprintf(m.out, "Warning: failed to cache legacy public key: %v\n", err)
+	}
+
+	return pubkey, nil
+}
+
+// legacyKeyMetadata wraps a bare .pub key as a version 0 key
+func legacyKeyMetadata(pubkey string) *KeyMetadata {
+	return &KeyMetadata{
+		Version:   0,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: time.Now().Add(365 * 24 * time.Hour).Unix(),
+		Algorithm: "ed25519",
+		Key:       pubkey,
+	}
+}
+
+// fetchPreviousKeyVersions attempts to fetch previous key versions for transition support
+func (m *Manager) fetchPreviousKeyVersions(sourceRepo string, currentVersion int) ([]string, error) {
+	provider, err := m.provider(sourceRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	var previousKeys []string
+
+	// Try to fetch up to 3 previous versions
+	for i := 1; i <= 3 && currentVersion-i >= 0; i++ {
+		prevVersion := currentVersion - i
+		keyURL := provider.KeyURL(fmt.Sprintf("pack_v%d.box", prevVersion))
+
+		content, err := m.fetchBytes(keyURL)
+		if err != nil {
+			continue // Version doesn't exist
+		}
+
+		if metadata, err := parseKeyMetadata(string(content)); err == nil {
+			previousKeys = append(previousKeys, metadata.Key)
+		}
+	}
+
+	if len(previousKeys) == 0 {
+		return nil, fmt.Errorf("no previous key versions found")
+	}
+
+	return previousKeys, nil
+}
+
+// clearKeyCache removes cached keys for a source to force refresh
+func (m *Manager) clearKeyCache(sourceRepo string) {
+	m.keyMu.Lock()
+	defer m.keyMu.Unlock()
+
+	// Remove both .box and .pub cache files
+	os.Remove(m.keyCachePath(sourceRepo, ".box"))
+	os.Remove(m.keyCachePath(sourceRepo, ".pub"))
+}
+
+// getCachedPublicKeyWithVersion retrieves a cached public key with version info
+func (m *Manager) getCachedPublicKeyWithVersion(sourceRepo string) (string, int, error) {
+	m.keyMu.Lock()
+	defer m.keyMu.Unlock()
+
+	// Try versioned cache first
+	if content, err := os.ReadFile(m.keyCachePath(sourceRepo, ".box")); err == nil {
+		metadata, err := parseKeyMetadata(string(content))
+		if err == nil {
+			return metadata.Key, metadata.Version, nil
+		}
+	}
+
+	// Fallback to legacy cache
+	if content, err := os.ReadFile(m.keyCachePath(sourceRepo, ".pub")); err == nil {
+		return strings.TrimSpace(string(content)), 0, nil
+	}
+
+	return "", 0, fmt.Errorf("no cached key found")
+}
+
+// cachePublicKeyWithVersion stores a public key with version metadata
+func (m *Manager) cachePublicKeyWithVersion(sourceRepo string, metadata *KeyMetadata) error {
+	m.keyMu.Lock()
+	defer m.keyMu.Unlock()
+
+	keyFile := m.keyCachePath(sourceRepo, ".box")
+	if err := os.MkdirAll(filepath.Dir(keyFile), privateDirPerms); err != nil {
+		return err
+	}
+
+	// Create .box format cache file
+	cacheContent := fmt.Sprintf(`[data -c keyinfo]
+  version     %d
+  issued_at   %d
+  expires_at  %d
+  algorithm   %s
+  cached_at   %d
+end
+
+[data -c pubkey]
+  key %s
+end`, metadata.Version, metadata.IssuedAt, metadata.ExpiresAt, metadata.Algorithm, time.Now().Unix(), metadata.Key)
+
+	return os.WriteFile(keyFile, []byte(cacheContent), privateFilePerms)
+}
+
+// shouldRefreshKey determines if a cached key should be refreshed
+func (m *Manager) shouldRefreshKey(sourceRepo string, version int) bool {
+	stat, err := os.Stat(m.keyCachePath(sourceRepo, ".box"))
+	if err != nil {
+		return true // Refresh if cache file doesn't exist
+	}
+
+	// Refresh if cache is older than 24 hours
+	return time.Since(stat.ModTime()) > 24*time.Hour
+}
+
+// backgroundKeyRefresh refreshes a key in the background
+func (m *Manager) backgroundKeyRefresh(sourceRepo string) {
+	provider, err := m.provider(sourceRepo)
+	if err != nil {
+		return
+	}
+
+	// Try to fetch new metadata
+	if metadata, err := m.fetchKeyMetadata(sourceRepo, provider); err == nil {
+		// Key was updated, clear old cache and store new
+		m.cachePublicKeyWithVersion(sourceRepo, metadata)
+	} else {
+		// Fallback to legacy format
+		if key, err := m.fetchLegacyPublicKey(sourceRepo, provider); err == nil {
+			m.cachePublicKeyWithVersion(sourceRepo, legacyKeyMetadata(key))
+		}
+	}
+}
+
+// parseKeyMetadata reads the keyinfo and pubkey blocks of a pack.box key file
+func parseKeyMetadata(content string) (*KeyMetadata, error) {
+	file, err := ParseBox([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &KeyMetadata{
+		Algorithm: "ed25519", // default
+	}
+
+	if keyinfo := file.Block("keyinfo"); keyinfo != nil {
+		version, err := keyinfo.Int("version")
+		if err != nil {
+			return nil, err
+		}
+		metadata.Version = int(version)
+
+		if metadata.IssuedAt, err = keyinfo.Int("issued_at"); err != nil {
+			return nil, err
+		}
+		if metadata.ExpiresAt, err = keyinfo.Int("expires_at"); err != nil {
+			return nil, err
+		}
+		if algorithm := keyinfo.String("algorithm"); algorithm != "" {
+			metadata.Algorithm = algorithm
+		}
+	}
+
+	if pubkey := file.Block("pubkey"); pubkey != nil {
+		metadata.Key = pubkey.String("key")
+	}
+
+	if metadata.Key == "" {
+		return nil, fmt.Errorf("no public key found in metadata")
+	}
+
+	return metadata, nil
+}
+
+// RefreshKeys updates cached public keys from all configured sources. Keys
+// that could not be refreshed are reported per source.
+func (m *Manager) RefreshKeys() []error {
+	config, err := m.loadConfig()
+	if err != nil {
+		return []error{fmt.Errorf("could not read sources config: %v", err)}
+	}
+
+	// Refresh keys from each source concurrently
+	return m.refreshKeysConcurrently(config.Sources)
+}
+
+// refreshKeysConcurrently refreshes public keys from multiple sources in parallel
+func (m *Manager) refreshKeysConcurrently(sources []Source) []error {
+	// Filter out local sources
+	var remoteSources []string
+	for _, source := range sources {
+		if source.URL != "local" {
+			remoteSources = append(remoteSources, source.URL)
+		}
+	}
+
+	if len(remoteSources) == 0 {
+		return nil
+	}
+
+	// Create jobs and results channels
+	jobs := make(chan string, len(remoteSources))
+	results := make(chan keyRefreshResult, len(remoteSources))
+
+	workerCount := keyRefreshWorkers
+	if len(remoteSources) < keyRefreshWorkers {
+		workerCount = len(remoteSources)
+	}
+
+	// Start worker goroutines
+	for w := 0; w < workerCount; w++ {
+		go m.keyRefreshWorker(jobs, results)
+	}
+
+	// Send jobs
+	go func() {
+		defer close(jobs)
+		for _, source := range remoteSources {
+			jobs <- source
+		}
+	}()
+
+	// Collect results
+	var errs []error
+	for i := 0; i < len(remoteSources); i++ {
+		result := <-results
+		if result.Error != nil {
+			errs = append(errs, fmt.Errorf("could not refresh key for %s: %v", result.Source, result.Error))
+		}
+	}
+
+	return errs
+}
+
+// keyRefreshResult represents the result of a key refresh operation
+type keyRefreshResult struct {
+	Source string
+	Error  error
+}
+
+// keyRefreshWorker processes key refresh jobs
+func (m *Manager) keyRefreshWorker(jobs <-chan string, results chan<- keyRefreshResult) {
+	for source := range jobs {
+		_, err := m.fetchPublicKeyFromRepo(source)
+		results <- keyRefreshResult{
+			Source: source,
+			Error:  err,
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstalledPackage is one entry on the shelf
+type InstalledPackage struct {
+	Name string
+	Lock *Lockfile
+	// Err is set when the lock file could not be read
+	Err error
+}
+
+// SearchResult groups the packages matching a search by source
+type SearchResult struct {
+	Source   Source
+	Packages []PackageInfo
+	Err      error
+}
+
+// Installed returns every package that has a lock file, sorted by name
+func (m *Manager) Installed() ([]InstalledPackage, error) {
+	locksDir := m.path("locks")
+	if _, err := os.Stat(locksDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	files, err := os.ReadDir(locksDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading locks directory: %v", err)
+	}
+
+	var installed []InstalledPackage
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".lock") {
+			continue
+		}
+
+		packageName := strings.TrimSuffix(file.Name(), ".lock")
+		lock, err := ReadLockfile(filepath.Join(locksDir, file.Name()))
+		installed = append(installed, InstalledPackage{
+			Name: packageName,
+			Lock: lock,
+			Err:  err,
+		})
+	}
+
+	return installed, nil
+}
+
+// Available returns the packages listed in a source's signed index,
+// answering from the metadata cache where the cache mode allows
+func (m *Manager) Available(source Source) ([]PackageInfo, error) {
+	idx, err := m.sourceIndex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []PackageInfo
+	for _, entry := range idx.Packages {
+		packages = append(packages, entry.Info())
+	}
+
+	return packages, nil
+}
+
+// Search returns the packages whose name or description contains term,
+// grouped by source. Sources without matches are left out, sources whose
+// index could not be read are returned with Err set.
+func (m *Manager) Search(term string) ([]SearchResult, error) {
+	searchTerm := strings.ToLower(term)
+
+	sources, err := m.Sources()
+	if err != nil {
+		return nil, fmt.Errorf("error getting sources: %v", err)
+	}
+
+	var results []SearchResult
+	for _, source := range sources {
+		available, err := m.Available(source)
+		if err != nil {
+			results = append(results, SearchResult{Source: source, Err: err})
+			continue
+		}
+
+		var matches []PackageInfo
+		for _, pkg := range available {
+			// Search in name and description
+			if strings.Contains(strings.ToLower(pkg.Name), searchTerm) ||
+				strings.Contains(strings.ToLower(pkg.Description), searchTerm) {
+				matches = append(matches, pkg)
+			}
+		}
+		if len(matches) > 0 {
+			results = append(results, SearchResult{Source: source, Packages: matches})
+		}
+	}
+
+	return results, nil
+}
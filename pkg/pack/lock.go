@@ -0,0 +1,397 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockSchemaVersion is the lock file layout this version of pack writes.
+// Version 1 files predate the schema field and may use the legacy names
+// source, source_type, source_version and sha256.
+const lockSchemaVersion = 2
+
+// Lockfile records how an installed package got onto the system
+type Lockfile struct {
+	// Schema is the layout version the file was read as
+	Schema int
+
+	Package string
+	// Repo is the source the recipe came from, or "local"
+	Repo string
+
+	SrcURL  string
+	SrcType string
+	SrcRef  string
+	// SrcRefUsed is the resolved commit or version that was built
+	SrcRefUsed string
+	// Pinned is set when SrcRef was chosen with name@ref rather than taken
+	// from the recipe
+	Pinned bool
+	// Held packages are left out of updates
+	Held bool
+
+	RecipeSHA256 string
+	RecipeURL    string
+
+	InstalledAt time.Time
+	ShelfPath   string
+	SymlinkPath string
+	ConfigDir   string
+	TrustState  string
+
+	// Generation is the shelf generation ShelfPath points at, 0 for
+	// recipes that install outside of one; Bins are the binaries pack
+	// linked from it
+	Generation int
+	Bins       []string
+
+	// InstallReason is ReasonDependency for packages only installed because
+	// another package needed them
+	InstallReason string
+	// Deps are the runtime dependencies the recipe declared
+	Deps []string
+	// Provides lists the package, its binaries and any provides names;
+	// Conflicts is the recipe's conflicts field
+	Provides  []string
+	Conflicts []string
+
+	// Extra holds fields this version of pack does not know about so that
+	// they survive a rewrite
+	Extra map[string]string
+}
+
+// legacyLockFields maps schema 1 names to their current equivalents
+var legacyLockFields = map[string]string{
+	"source":         "src_url",
+	"source_type":    "src_type",
+	"source_version": "src_ref_used",
+	"sha256":         "recipe_sha256",
+}
+
+// fields returns the lock as ordered key/value pairs in file order
+func (l *Lockfile) fields() [][2]string {
+	installedAt := ""
+	if !l.InstalledAt.IsZero() {
+		installedAt = l.InstalledAt.UTC().Format(time.RFC3339)
+	}
+
+	generation := ""
+	if l.Generation > 0 {
+		generation = strconv.Itoa(l.Generation)
+	}
+
+	pinned, held := "", ""
+	if l.Pinned {
+		pinned = "true"
+	}
+	if l.Held {
+		held = "true"
+	}
+
+	return [][2]string{
+		{"schema", strconv.Itoa(lockSchemaVersion)},
+		{"package", l.Package},
+		{"repo", l.Repo},
+		{"src_url", l.SrcURL},
+		{"src_type", l.SrcType},
+		{"src_ref", l.SrcRef},
+		{"src_ref_used", l.SrcRefUsed},
+		{"pinned", pinned},
+		{"held", held},
+		{"recipe_sha256", l.RecipeSHA256},
+		{"recipe_url", l.RecipeURL},
+		{"installed_at", installedAt},
+		{"shelf_path", l.ShelfPath},
+		{"symlink_path", l.SymlinkPath},
+		{"config_dir", l.ConfigDir},
+		{"trust_state", l.TrustState},
+		{"generation", generation},
+		{"bins", strings.Join(l.Bins, " ")},
+		{"install_reason", l.InstallReason},
+		{"deps", strings.Join(l.Deps, " ")},
+		{"provides", strings.Join(l.Provides, " ")},
+		{"conflicts", strings.Join(l.Conflicts, " ")},
+	}
+}
+
+// set assigns a single lock field, keeping unknown ones in Extra
+func (l *Lockfile) set(key, value string) error {
+	switch key {
+	case "schema":
+		schema, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid schema %q", value)
+		}
+		l.Schema = schema
+	case "package":
+		l.Package = value
+	case "repo":
+		l.Repo = value
+	case "src_url":
+		l.SrcURL = value
+	case "src_type":
+		l.SrcType = value
+	case "src_ref":
+		l.SrcRef = value
+	case "src_ref_used":
+		l.SrcRefUsed = value
+	case "pinned":
+		l.Pinned = value == "true"
+	case "held":
+		l.Held = value == "true"
+	case "recipe_sha256":
+		l.RecipeSHA256 = value
+	case "recipe_url":
+		l.RecipeURL = value
+	case "installed_at":
+		if value == "" {
+			return nil
+		}
+		installedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("invalid installed_at %q", value)
+		}
+		l.InstalledAt = installedAt
+	case "shelf_path":
+		l.ShelfPath = value
+	case "symlink_path":
+		l.SymlinkPath = value
+	case "config_dir":
+		l.ConfigDir = value
+	case "trust_state":
+		l.TrustState = value
+	case "generation":
+		generation, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid generation %q", value)
+		}
+		l.Generation = generation
+	case "bins":
+		l.Bins = strings.Fields(value)
+	case "install_reason":
+		l.InstallReason = value
+	case "deps":
+		l.Deps = strings.Fields(value)
+	case "provides":
+		l.Provides = strings.Fields(value)
+	case "conflicts":
+		l.Conflicts = strings.Fields(value)
+	default:
+		if l.Extra == nil {
+			l.Extra = make(map[string]string)
+		}
+		l.Extra[key] = value
+	}
+	return nil
+}
+
+// Marshal renders the lock in box data block format using the current schema
+func (l *Lockfile) Marshal() []byte {
+	var b strings.Builder
+	b.WriteString("[data -c lock]\n")
+	for _, field := range l.fields() {
+		if field[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s %s\n", field[0], quoteBoxValue(field[1]))
+	}
+
+	extraKeys := make([]string, 0, len(l.Extra))
+	for key := range l.Extra {
+		extraKeys = append(extraKeys, key)
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		fmt.Fprintf(&b, "  %s %s\n", key, quoteBoxValue(l.Extra[key]))
+	}
+
+	b.WriteString("end\n")
+	return []byte(b.String())
+}
+
+// ParseLockfile reads a lock from its box data block. Legacy schema 1
+// field names are migrated to their current names; a current name always
+// wins over its legacy one.
+func ParseLockfile(content []byte) (*Lockfile, error) {
+	file, err := ParseBox(content)
+	if err != nil {
+		return nil, err
+	}
+
+	block := file.Block("lock")
+	if block == nil {
+		return nil, fmt.Errorf("no lock data block found")
+	}
+
+	lock := &Lockfile{Schema: 1}
+	legacy := make(map[string]string)
+	current := make(map[string]bool)
+
+	for _, field := range block.Fields {
+		if newKey, ok := legacyLockFields[field.Key]; ok {
+			legacy[newKey] = field.Value()
+			continue
+		}
+
+		if err := lock.set(field.Key, field.Value()); err != nil {
+			return nil, &BoxSyntaxError{Line: field.Line, Msg: err.Error()}
+		}
+		current[field.Key] = true
+	}
+
+	if lock.Schema > lockSchemaVersion {
+		return nil, fmt.Errorf("lock schema %d is newer than this version of pack supports (%d)", lock.Schema, lockSchemaVersion)
+	}
+
+	for key, value := range legacy {
+		if current[key] {
+			continue
+		}
+		if err := lock.set(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return lock, nil
+}
+
+// ReadLockfile parses the lock file at path
+func ReadLockfile(path string) (*Lockfile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := ParseLockfile(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return lock, nil
+}
+
+// Write saves the lock to path using the current schema, replacing any
+// existing file in one rename
+func (l *Lockfile) Write(path string) error {
+	l.Schema = lockSchemaVersion
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, l.Marshal(), publicFilePerms); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// getLockFilePath returns the path to a package's lock file
+func (m *Manager) getLockFilePath(packageName string) string {
+	return m.path("locks", packageName+".lock")
+}
+
+// IsInstalled reports whether packageName has a lock file
+func (m *Manager) IsInstalled(packageName string) bool {
+	_, err := os.Stat(m.getLockFilePath(packageName))
+	return err == nil
+}
+
+// Lock returns the lock file of an installed package. Locks written by an
+// older pack are migrated in memory; they are saved in the current schema
+// the next time the package's lock is written, or by doctor --fix.
+func (m *Manager) Lock(packageName string) (*Lockfile, error) {
+	lockFilePath := m.getLockFilePath(packageName)
+	if _, err := os.Stat(lockFilePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("package %s is not installed (no lock file found)", packageName)
+	}
+	return ReadLockfile(lockFilePath)
+}
+
+// newLock returns a lock for packageName with its install paths filled in
+func (m *Manager) newLock(packageName string) (*Lockfile, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lockfile{
+		Schema:      lockSchemaVersion,
+		Package:     packageName,
+		InstalledAt: time.Now().UTC(),
+		ShelfPath:   filepath.Join(m.shelfPath(), packageName),
+		SymlinkPath: filepath.Join(m.binDir, packageName),
+		ConfigDir:   filepath.Join(homeDir, ".config", packageName),
+		// Always ed25519 now
+		TrustState: "ed25519",
+	}, nil
+}
+
+// saveLock writes lock into the locks directory
+func (m *Manager) saveLock(lock *Lockfile) error {
+	if err := os.MkdirAll(m.path("locks"), publicDirPerms); err != nil {
+		return err
+	}
+	return lock.Write(m.getLockFilePath(lock.Package))
+}
+
+// writeBootstrapLock records the box interpreter installed by bootstrapBoxMinimal
+func (m *Manager) writeBootstrapLock(tempDir, shelfDir string) error {
+	// Get current commit hash from the cloned repository
+	getCommitCmd := exec.Command("git", "rev-parse", "HEAD")
+	getCommitCmd.Dir = tempDir
+	commitOutput, err := getCommitCmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to get commit hash: %v", err)
+	}
+	commitHash := strings.TrimSpace(string(commitOutput))
+	if len(commitHash) > 8 {
+		commitHash = commitHash[:8]
+	}
+
+	lock, err := m.newLock("boxlang")
+	if err != nil {
+		return err
+	}
+	lock.Repo = DefaultRepo
+	lock.SrcURL = "https://github.com/shrub4thedub/boxlang.git"
+	lock.SrcType = "git"
+	lock.SrcRef = "HEAD"
+	lock.SrcRefUsed = commitHash
+	lock.RecipeSHA256 = "bootstrap"
+	if provider, err := NewRepoProvider("", DefaultRepo, ""); err == nil {
+		lock.RecipeURL = provider.RecipeURL("", "boxlang")
+	}
+	lock.ShelfPath = shelfDir
+	lock.SymlinkPath = filepath.Join(m.binDir, "box")
+	lock.Provides = []string{"boxlang", "box"}
+	lock.TrustState = "bootstrap"
+
+	return m.saveLock(lock)
+}
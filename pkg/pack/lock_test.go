@@ -0,0 +1,169 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLockfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Lockfile
+		err     string
+	}{
+		{
+			name: "current schema",
+			content: `[data -c lock]
+  schema 2
+  package edith
+  repo local
+  src_ref_used abc12345
+  pinned true
+  held true
+  installed_at 2025-01-02T03:04:05Z
+  generation 3
+  bins "edith edith-helper"
+  deps libfoo
+end
+`,
+			want: &Lockfile{
+				Schema: 2, Package: "edith", Repo: "local", SrcRefUsed: "abc12345", Pinned: true, Held: true,
+				InstalledAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Generation: 3,
+				Bins: []string{"edith", "edith-helper"}, Deps: []string{"libfoo"},
+			},
+		},
+		{
+			name: "legacy fields are migrated",
+			content: `[data -c lock]
+  package edith
+  source https://example.com/edith.git
+  source_type git
+  source_version v1.0
+  sha256 deadbeef
+end
+`,
+			want: &Lockfile{
+				Schema: 1, Package: "edith", SrcURL: "https://example.com/edith.git", SrcType: "git",
+				SrcRefUsed: "v1.0", RecipeSHA256: "deadbeef",
+			},
+		},
+		{
+			name:    "current names win over legacy ones",
+			content: "[data -c lock]\n  src_url new\n  source old\n  sha256 old\n  recipe_sha256 new\nend\n",
+			want:    &Lockfile{Schema: 1, SrcURL: "new", RecipeSHA256: "new"},
+		},
+		{
+			name:    "unknown fields are kept",
+			content: "[data -c lock]\n  schema 2\n  package edith\n  colour blue\nend\n",
+			want:    &Lockfile{Schema: 2, Package: "edith", Extra: map[string]string{"colour": "blue"}},
+		},
+		{
+			name:    "newer schema",
+			content: "[data -c lock]\n  schema 3\nend\n",
+			err:     "lock schema 3 is newer than this version of pack supports (2)",
+		},
+		{
+			name:    "bad generation",
+			content: "[data -c lock]\n  package edith\n  generation two\nend\n",
+			err:     `line 3: invalid generation "two"`,
+		},
+		{
+			name:    "no lock block",
+			content: "[data -c pkg]\n  name edith\nend\n",
+			err:     "no lock data block found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock, err := ParseLockfile([]byte(tt.content))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lock, tt.want) {
+				t.Errorf("lock = %+v\nwant   %+v", lock, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockfileRoundTrip(t *testing.T) {
+	lock := &Lockfile{
+		Schema: lockSchemaVersion, Package: "edith", Repo: "file:///srv/my repo", SrcRef: "main",
+		Held: true, InstalledAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Generation: 2,
+		Bins: []string{"edith"}, Extra: map[string]string{"note": "says \"hi\" # not a comment\nand more"},
+	}
+	got, err := ParseLockfile(lock.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, lock) {
+		t.Errorf("round trip = %+v\nwant         %+v", got, lock)
+	}
+}
+
+// TestLockDoesNotMigrateOnRead makes sure reading an old lock leaves the
+// file alone until something writes it
+func TestLockDoesNotMigrateOnRead(t *testing.T) {
+	m := testManager(t)
+	legacy := []byte("[data -c lock]\n  package edith\n  source https://example.com/edith.git\nend\n")
+	path := m.getLockFilePath("edith")
+	if err := os.WriteFile(path, legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := m.Lock("edith")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.SrcURL != "https://example.com/edith.git" {
+		t.Errorf("SrcURL = %q", lock.SrcURL)
+	}
+	if _, err := m.Installed(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != string(legacy) {
+		t.Fatalf("reading rewrote the lock:\n%s", content)
+	}
+
+	if err := m.saveLock(lock); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ReadLockfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Schema != lockSchemaVersion || saved.SrcURL != lock.SrcURL {
+		t.Errorf("saved lock = %+v", saved)
+	}
+}
@@ -0,0 +1,284 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package pack installs, updates and removes packages described by box
+// recipes. The pack command is a thin client of the Manager type defined
+// here; other Go programs can drive installs the same way.
+package pack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRepo = "https://github.com/shrub4thedub/pack-repo"
+	packDir     = ".pack"
+
+	// Performance and timeout constants
+	httpTimeoutSeconds         = 30
+	dialTimeoutSeconds         = 10
+	keepAliveSeconds           = 30
+	maxIdleConns               = 100
+	maxIdleConnsPerHost        = 10
+	idleConnTimeoutSeconds     = 90
+	tlsHandshakeTimeoutSeconds = 10
+
+	// Worker pool constants
+	updateCheckWorkers = 8
+	keyRefreshWorkers  = 4
+	recipeFetchWorkers = 8
+
+	// Shelf generations kept for rollback, including the current one
+	keepGenerations = 3
+	// Build logs kept per package
+	keepBuildLogs = 10
+
+	// Cache and pagination constants
+	cacheExpiryMinutes     = 30
+	coreCheckIntervalHours = 2
+
+	// File permission constants
+	publicFilePerms  = 0644
+	publicDirPerms   = 0755
+	privateFilePerms = 0600
+	privateDirPerms  = 0700
+)
+
+// repoSections are the directories a pack repository sorts recipes into
+var repoSections = []string{"lang", "utils", "toys", "misc"}
+
+// corePackages are updated ahead of everything else
+var corePackages = []string{"pack", "boxlang"}
+
+// ErrCancelled is returned when the prompter declines to go ahead
+var ErrCancelled = errors.New("cancelled")
+
+// Global HTTP client with connection pooling for better performance
+var defaultHTTPClient = &http.Client{
+	Timeout: httpTimeoutSeconds * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeoutSeconds * time.Second,
+			KeepAlive: keepAliveSeconds * time.Second,
+		}).DialContext,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeoutSeconds * time.Second,
+		TLSHandshakeTimeout: tlsHandshakeTimeoutSeconds * time.Second,
+	},
+}
+
+// Options configures a Manager. Zero values fall back to what the pack
+// command uses.
+type Options struct {
+	// Root is the pack directory, ~/.pack by default
+	Root string
+	// BinDir is where package binaries are symlinked, ~/.local/bin by default
+	BinDir string
+	// Out receives progress messages and box output, discarded by default
+	Out io.Writer
+	// Stdin is handed to box scripts, none by default
+	Stdin io.Reader
+	// Prompter answers questions; by default every question fails
+	Prompter Prompter
+	// HTTPClient is used for all downloads
+	HTTPClient *http.Client
+	// Cache controls when package metadata is fetched from the network
+	Cache CacheMode
+}
+
+// Manager installs, removes and tracks packages under a pack directory
+type Manager struct {
+	root   string
+	binDir string
+	out    io.Writer
+	stdin  io.Reader
+	prompt Prompter
+	client *http.Client
+	cache  CacheMode
+
+	// inTransaction is set while an operation is being journaled
+	inTransaction bool
+	// sourceFor pins packages to a source while a packfile is applied
+	sourceFor map[string]string
+
+	// verifyMu serializes recipe verification, which may rotate keys
+	verifyMu sync.Mutex
+	// keyMu guards the key cache and configMu guards sources.box, both
+	// of which are also written by background key refreshes
+	keyMu    sync.Mutex
+	configMu sync.Mutex
+}
+
+// New returns a Manager for opts
+func New(opts Options) (*Manager, error) {
+	m := &Manager{
+		root:   opts.Root,
+		binDir: opts.BinDir,
+		out:    opts.Out,
+		stdin:  opts.Stdin,
+		prompt: opts.Prompter,
+		client: opts.HTTPClient,
+		cache:  opts.Cache,
+	}
+
+	if m.root == "" || m.binDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %v", err)
+		}
+		if m.root == "" {
+			m.root = filepath.Join(homeDir, packDir)
+		}
+		if m.binDir == "" {
+			m.binDir = filepath.Join(homeDir, ".local", "bin")
+		}
+	}
+	if m.out == nil {
+		m.out = io.Discard
+	}
+	if m.prompt == nil {
+		m.prompt = noPrompter{}
+	}
+	if m.client == nil {
+		m.client = defaultHTTPClient
+	}
+
+	return m, nil
+}
+
+// Root returns the pack directory
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// BinDir returns the directory package binaries are linked into
+func (m *Manager) BinDir() string {
+	return m.binDir
+}
+
+// path joins elem onto the pack directory
+func (m *Manager) path(elem ...string) string {
+	return filepath.Join(append([]string{m.root}, elem...)...)
+}
+
+func (m *Manager) configPath() string {
+	return m.path("config")
+}
+
+func (m *Manager) localRepoPath() string {
+	return m.path("local")
+}
+
+func (m *Manager) shelfPath() string {
+	return m.path("shelf")
+}
+
+// EnsureDirs creates the pack directory layout
+func (m *Manager) EnsureDirs() error {
+	// Create main pack directory
+	if err := os.MkdirAll(m.root, publicDirPerms); err != nil {
+		return err
+	}
+
+	// Create subdirectories (future shrub dont change these its a fucking pain)
+	subdirs := []string{"locks", "config", "tmp", "local", "shelf", "cache"}
+	for _, subdir := range subdirs {
+		if err := os.MkdirAll(m.path(subdir), publicDirPerms); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Simple stoopid simple progress bar for updates
+func (m *Manager) showProgress(current, total int, message string) {
+	percent := float64(current) / float64(total) * 100
+	barWidth := 30
+	filled := int(percent / 100.0 * float64(barWidth))
+
+	bar := "["
+	for i := 0; i < barWidth; i++ {
+		if i < filled {
+			bar += "="
+		} else if i == filled && current < total {
+			bar += ">"
+		} else {
+			bar += " "
+		}
+	}
+	bar += "]"
+
+	fmt.Fprintf(m.out, "\r%s %3.0f%% (%d/%d) %s", bar, percent, current, total, message)
+	if current >= total {
+		fmt.Fprintln(m.out)
+	}
+}
+
+// CleanTemp removes everything under the pack tmp directory and returns how
+// many entries were removed along with any that could not be
+func (m *Manager) CleanTemp() (int, []error) {
+	tmpDir := m.path("tmp")
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, []error{fmt.Errorf("error reading tmp directory: %v", err)}
+	}
+
+	removedCount := 0
+	var errs []error
+	for _, entry := range entries {
+		entryPath := filepath.Join(tmpDir, entry.Name())
+		if err := os.RemoveAll(entryPath); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %v", entryPath, err))
+		} else {
+			removedCount++
+		}
+	}
+
+	return removedCount, errs
+}
+
+// PackageError ties an error to the package it happened to
+type PackageError struct {
+	Package string
+	Err     error
+}
+
+func (e *PackageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Package, e.Err)
+}
+
+func (e *PackageError) Unwrap() error {
+	return e.Err
+}
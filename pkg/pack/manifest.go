@@ -0,0 +1,398 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of manifest entry
+const (
+	EntryFile = "file"
+	EntryLink = "link"
+	EntryDir  = "dir"
+)
+
+// ManifestEntry is one thing a recipe put on disk
+type ManifestEntry struct {
+	Kind string
+	Path string
+	// SHA256 is set for files, Target for links
+	SHA256 string
+	Target string
+	// Modified is set for files and links that were there before the
+	// install changed them. They are not the package's to remove.
+	Modified bool
+}
+
+// Manifest lists what installing a package created or changed
+type Manifest struct {
+	Package string
+	Entries []ManifestEntry
+}
+
+// Marshal renders the manifest as a box data block
+func (mf *Manifest) Marshal() []byte {
+	var b strings.Builder
+	b.WriteString("[data -c manifest]\n")
+	fmt.Fprintf(&b, "  package %s\n", quoteBoxValue(mf.Package))
+	for _, entry := range mf.Entries {
+		modified := ""
+		if entry.Modified {
+			modified = " modified"
+		}
+		switch entry.Kind {
+		case EntryFile:
+			fmt.Fprintf(&b, "  file %s %s%s\n", quoteBoxValue(entry.Path), entry.SHA256, modified)
+		case EntryLink:
+			fmt.Fprintf(&b, "  link %s %s%s\n", quoteBoxValue(entry.Path), quoteBoxValue(entry.Target), modified)
+		case EntryDir:
+			fmt.Fprintf(&b, "  dir %s\n", quoteBoxValue(entry.Path))
+		}
+	}
+	b.WriteString("end\n")
+	return []byte(b.String())
+}
+
+// ParseManifest reads a manifest from its box data block
+func ParseManifest(content []byte) (*Manifest, error) {
+	file, err := ParseBox(content)
+	if err != nil {
+		return nil, err
+	}
+
+	block := file.Block("manifest")
+	if block == nil {
+		return nil, fmt.Errorf("no manifest data block found")
+	}
+
+	mf := &Manifest{Package: block.String("package")}
+	for _, field := range block.Fields {
+		entry := ManifestEntry{Kind: field.Key}
+		switch field.Key {
+		case "package":
+			continue
+		case EntryFile, EntryLink:
+			values := field.Values
+			if len(values) == 3 && values[2] == "modified" {
+				entry.Modified = true
+				values = values[:2]
+			}
+			if len(values) != 2 {
+				return nil, &BoxSyntaxError{Line: field.Line, Msg: fmt.Sprintf("%s needs a path and a value", field.Key)}
+			}
+			entry.Path = values[0]
+			if field.Key == EntryFile {
+				entry.SHA256 = values[1]
+			} else {
+				entry.Target = values[1]
+			}
+		case EntryDir:
+			if len(field.Values) != 1 {
+				return nil, &BoxSyntaxError{Line: field.Line, Msg: "dir needs a path"}
+			}
+			entry.Path = field.Values[0]
+		default:
+			return nil, &BoxSyntaxError{Line: field.Line, Msg: fmt.Sprintf("unknown manifest entry %q", field.Key)}
+		}
+		mf.Entries = append(mf.Entries, entry)
+	}
+
+	return mf, nil
+}
+
+// manifestPath is where packageName's manifest is kept, next to its lock
+func (m *Manager) manifestPath(packageName string) string {
+	return m.path("locks", packageName, "files.box")
+}
+
+// managedPaths are the places a recipe for packageName declaring bins is
+// expected to write. The rest of the bin directory is shared and anything
+// else appearing there during the run is not the package's.
+func (m *Manager) managedPaths(packageName string, bins []string) []string {
+	paths := []string{m.packageShelf(packageName)}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(homeDir, ".config", packageName))
+	}
+	for _, bin := range bins {
+		paths = append(paths, filepath.Join(m.binDir, bin))
+	}
+	return paths
+}
+
+// fileState is what a snapshot remembers about one path
+type fileState struct {
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+	target  string
+}
+
+// snapshot records the state of everything under roots
+func snapshot(roots []string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			state := fileState{mode: info.Mode(), size: info.Size(), modTime: info.ModTime()}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				state.target, _ = os.Readlink(path)
+			}
+			states[path] = state
+			return nil
+		})
+	}
+	return states
+}
+
+// removeCreated deletes everything that appeared under roots since before,
+// undoing a run that must not be kept
+func removeCreated(roots []string, before map[string]fileState) {
+	for path := range snapshot(roots) {
+		if _, ok := before[path]; !ok {
+			os.RemoveAll(path)
+		}
+	}
+}
+
+// hashFile returns the hex sha256 of the file at path
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// diffSnapshots returns entries for everything created or changed between
+// before and after, sorted by path. Changed paths are marked modified.
+func diffSnapshots(before, after map[string]fileState) []ManifestEntry {
+	var entries []ManifestEntry
+	for path, state := range after {
+		old, existed := before[path]
+		if existed && old == state {
+			continue
+		}
+
+		switch {
+		case state.mode&fs.ModeSymlink != 0:
+			entries = append(entries, ManifestEntry{Kind: EntryLink, Path: path, Target: state.target, Modified: existed})
+		case state.mode.IsDir():
+			if !existed {
+				entries = append(entries, ManifestEntry{Kind: EntryDir, Path: path})
+			}
+		case state.mode.IsRegular():
+			hash, err := hashFile(path)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, ManifestEntry{Kind: EntryFile, Path: path, SHA256: hash, Modified: existed})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// recordManifest saves what changed under roots since before as
+// packageName's manifest, leaving out paths foreign reports. Entries of the
+// previous manifest are kept as recorded so an update that leaves a file
+// alone does not forget it, unless this run removed them.
+func (m *Manager) recordManifest(packageName string, roots []string, before map[string]fileState, foreign func(path string) bool) error {
+	mf := &Manifest{Package: packageName}
+	after := snapshot(roots)
+
+	// What an earlier install created stays the package's when this run
+	// changes it
+	created := make(map[string]bool)
+	previous, err := m.Files(packageName)
+	if err == nil {
+		for _, entry := range previous.Entries {
+			created[entry.Path] = !entry.Modified
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range diffSnapshots(before, after) {
+		if foreign != nil && foreign(entry.Path) {
+			continue
+		}
+		if created[entry.Path] {
+			entry.Modified = false
+		}
+		mf.Entries = append(mf.Entries, entry)
+		seen[entry.Path] = true
+	}
+
+	if previous != nil {
+		for _, entry := range previous.Entries {
+			if seen[entry.Path] {
+				continue
+			}
+			_, existed := before[entry.Path]
+			if _, exists := after[entry.Path]; existed && !exists {
+				continue
+			}
+			mf.Entries = append(mf.Entries, entry)
+		}
+		sort.Slice(mf.Entries, func(i, j int) bool { return mf.Entries[i].Path < mf.Entries[j].Path })
+	}
+
+	return m.saveManifest(mf)
+}
+
+// saveManifest writes mf next to its package's lock
+func (m *Manager) saveManifest(mf *Manifest) error {
+	path := m.manifestPath(mf.Package)
+	if err := os.MkdirAll(filepath.Dir(path), publicDirPerms); err != nil {
+		return err
+	}
+	return os.WriteFile(path, mf.Marshal(), publicFilePerms)
+}
+
+// syncManifest brings packageName's manifest in line with changes pack
+// made itself: entries at or under removed are dropped and, when linked is
+// set, its bin links are recorded as pointing into its generation. Every
+// other entry is kept as recorded so verify still sees what the user
+// changed.
+func (m *Manager) syncManifest(packageName string, linked *Lockfile, removed []string) error {
+	mf, err := m.Files(packageName)
+	if err != nil {
+		return nil // nothing recorded to keep in sync
+	}
+
+	links := make(map[string]string)
+	if linked != nil {
+		for _, name := range linked.Bins {
+			if target, ok := findBin(linked.ShelfPath, name); ok {
+				links[filepath.Join(m.binDir, name)] = target
+			}
+		}
+	}
+
+	var entries []ManifestEntry
+	for _, entry := range mf.Entries {
+		if underAny(entry.Path, removed) {
+			continue
+		}
+		if target, ok := links[entry.Path]; ok {
+			entry.Kind, entry.SHA256, entry.Target = EntryLink, "", target
+			delete(links, entry.Path)
+		}
+		entries = append(entries, entry)
+	}
+	for path, target := range links {
+		entries = append(entries, ManifestEntry{Kind: EntryLink, Path: path, Target: target})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	mf.Entries = entries
+
+	return m.saveManifest(mf)
+}
+
+// underAny reports whether path is one of dirs or inside one of them
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Files returns the manifest of what installing packageName wrote
+func (m *Manager) Files(packageName string) (*Manifest, error) {
+	content, err := os.ReadFile(m.manifestPath(packageName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			if !m.IsInstalled(packageName) {
+				return nil, fmt.Errorf("package %s is not installed", packageName)
+			}
+			return nil, fmt.Errorf("no file manifest recorded for %s (installed before pack kept one)", packageName)
+		}
+		return nil, err
+	}
+
+	mf, err := ParseManifest(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", m.manifestPath(packageName), err)
+	}
+	return mf, nil
+}
+
+// Owns returns the installed packages that put path on disk. Packages
+// without a manifest are matched on their shelf and symlink paths.
+func (m *Manager) Owns(path string) ([]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	installed, err := m.Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	var owners []string
+	for _, pkg := range installed {
+		if pkg.Err != nil {
+			continue
+		}
+
+		if mf, err := m.Files(pkg.Name); err == nil {
+			for _, entry := range mf.Entries {
+				if entry.Path == path {
+					owners = append(owners, pkg.Name)
+					break
+				}
+			}
+			continue
+		}
+
+		if path == pkg.Lock.SymlinkPath || (pkg.Lock.ShelfPath != "" && (path == pkg.Lock.ShelfPath || strings.HasPrefix(path, pkg.Lock.ShelfPath+string(filepath.Separator)))) {
+			owners = append(owners, pkg.Name)
+		}
+	}
+
+	return owners, nil
+}
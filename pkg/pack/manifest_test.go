@@ -0,0 +1,298 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Manifest
+		err     string
+	}{
+		{
+			name: "every entry kind",
+			content: `[data -c manifest]
+  package edith
+  dir /home/u/.pack/shelf/edith/1
+  file "/home/u/.pack/shelf/edith/1/bin/edith" abc123
+  link /home/u/.local/bin/edith /home/u/.pack/shelf/edith/1/bin/edith
+end
+`,
+			want: &Manifest{Package: "edith", Entries: []ManifestEntry{
+				{Kind: EntryDir, Path: "/home/u/.pack/shelf/edith/1"},
+				{Kind: EntryFile, Path: "/home/u/.pack/shelf/edith/1/bin/edith", SHA256: "abc123"},
+				{Kind: EntryLink, Path: "/home/u/.local/bin/edith", Target: "/home/u/.pack/shelf/edith/1/bin/edith"},
+			}},
+		},
+		{
+			name: "modified entries",
+			content: `[data -c manifest]
+  package edith
+  file /home/u/.local/bin/edith abc123 modified
+  link /home/u/.local/bin/e /usr/bin/edith modified
+end
+`,
+			want: &Manifest{Package: "edith", Entries: []ManifestEntry{
+				{Kind: EntryFile, Path: "/home/u/.local/bin/edith", SHA256: "abc123", Modified: true},
+				{Kind: EntryLink, Path: "/home/u/.local/bin/e", Target: "/usr/bin/edith", Modified: true},
+			}},
+		},
+		{
+			name:    "empty manifest",
+			content: "[data -c manifest]\n  package edith\nend\n",
+			want:    &Manifest{Package: "edith"},
+		},
+		{
+			name:    "file without a hash",
+			content: "[data -c manifest]\n  package edith\n  file /bin/edith\nend\n",
+			err:     "line 3: file needs a path and a value",
+		},
+		{
+			name:    "dir with two paths",
+			content: "[data -c manifest]\n  dir /a /b\nend\n",
+			err:     "line 2: dir needs a path",
+		},
+		{
+			name:    "unknown entry",
+			content: "[data -c manifest]\n  socket /tmp/s\nend\n",
+			err:     `line 2: unknown manifest entry "socket"`,
+		},
+		{
+			name:    "no manifest block",
+			content: "[data -c lock]\n  package edith\nend\n",
+			err:     "no manifest data block found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mf, err := ParseManifest([]byte(tt.content))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(mf, tt.want) {
+				t.Errorf("manifest = %+v, want %+v", mf, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	mf := &Manifest{Package: "edith", Entries: []ManifestEntry{
+		{Kind: EntryDir, Path: "/home/u/My Files/edith"},
+		{Kind: EntryFile, Path: "/home/u/My Files/edith/#notes", SHA256: "abc123"},
+		{Kind: EntryLink, Path: "/home/u/.local/bin/edith", Target: `/home/u/My Files/edith/"bin"`},
+		{Kind: EntryFile, Path: "/home/u/.local/bin/e", SHA256: "def456", Modified: true},
+	}}
+	got, err := ParseManifest(mf.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, mf) {
+		t.Errorf("round trip = %+v, want %+v", got, mf)
+	}
+}
+
+// writeFiles creates each of paths with content
+func writeFiles(t *testing.T, content string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRemoveCreated(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := testManager(t)
+
+	kept := filepath.Join(m.packageShelf("edith"), "kept")
+	writeFiles(t, "x", kept)
+	roots := m.managedPaths("edith", []string{"edith"})
+	before := snapshot(roots)
+
+	created := []string{
+		filepath.Join(m.binDir, "edith"),
+		filepath.Join(m.packageShelf("edith"), "bin", "edith"),
+	}
+	other := filepath.Join(m.binDir, "other")
+	writeFiles(t, "x", append(created, other)...)
+
+	removeCreated(roots, before)
+
+	for _, path := range append(created, filepath.Dir(created[1])) {
+		if _, err := os.Lstat(path); err == nil {
+			t.Errorf("%s was left behind", path)
+		}
+	}
+	for _, path := range []string{kept, other} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+}
+
+func TestRecordManifest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := testManager(t)
+
+	link := filepath.Join(m.binDir, "edith")
+	existing := filepath.Join(m.binDir, "e")
+	stray := filepath.Join(m.binDir, "stray")
+	binary := filepath.Join(m.packageShelf("edith"), "edith")
+	writeFiles(t, "old", existing)
+	if err := m.saveManifest(&Manifest{Package: "edith", Entries: []ManifestEntry{
+		{Kind: EntryLink, Path: link, Target: "/old"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/old", link); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := m.managedPaths("edith", []string{"edith", "e"})
+	before := snapshot(roots)
+
+	// An update that relinks edith, overwrites e, installs its binary and
+	// happens to run alongside something writing stray
+	os.Remove(link)
+	if err := os.Symlink(binary, link); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, "new", existing, binary, stray)
+
+	if err := m.recordManifest("edith", roots, before, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Files("edith")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, _ := hashFile(binary)
+	want := []ManifestEntry{
+		{Kind: EntryFile, Path: existing, SHA256: hash, Modified: true},
+		{Kind: EntryLink, Path: link, Target: binary},
+		{Kind: EntryDir, Path: m.packageShelf("edith")},
+		{Kind: EntryFile, Path: binary, SHA256: hash},
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Path < want[j].Path })
+	if !reflect.DeepEqual(got.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", got.Entries, want)
+	}
+}
+
+func TestSyncManifest(t *testing.T) {
+	m := testManager(t)
+	gen1, gen2 := m.generationDir("edith", 1), m.generationDir("edith", 2)
+	if err := os.MkdirAll(filepath.Join(gen2, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gen2, "bin", "edith"), []byte("x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(m.binDir, "edith")
+	other := filepath.Join(m.binDir, "edith-helper")
+	deleted := filepath.Join(gen2, "deleted-by-user")
+	if err := m.saveManifest(&Manifest{Package: "edith", Entries: []ManifestEntry{
+		{Kind: EntryLink, Path: link, Target: filepath.Join(gen1, "bin", "edith")},
+		{Kind: EntryLink, Path: other, Target: "/somewhere/else"},
+		{Kind: EntryDir, Path: gen1},
+		{Kind: EntryFile, Path: filepath.Join(gen1, "bin", "edith"), SHA256: "abc"},
+		{Kind: EntryFile, Path: deleted, SHA256: "def"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolling forward to generation 2 after pruning generation 1
+	if err := m.syncManifest("edith", &Lockfile{ShelfPath: gen2, Bins: []string{"edith"}}, []string{gen1}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Files("edith")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ManifestEntry{
+		{Kind: EntryFile, Path: deleted, SHA256: "def"},
+		{Kind: EntryLink, Path: link, Target: filepath.Join(gen2, "bin", "edith")},
+		{Kind: EntryLink, Path: other, Target: "/somewhere/else"},
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Path < want[j].Path })
+	if !reflect.DeepEqual(got.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", got.Entries, want)
+	}
+}
+
+func TestVerifyFilesSkipsConfig(t *testing.T) {
+	m := testManager(t)
+	configDir := filepath.Join(t.TempDir(), "edith")
+	shelf := m.packageShelf("edith")
+
+	config := filepath.Join(configDir, "config.box")
+	binary := filepath.Join(shelf, "edith")
+	for _, path := range []string{config, binary} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("edited"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.saveLock(&Lockfile{Package: "edith", ShelfPath: shelf, ConfigDir: configDir}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.saveManifest(&Manifest{Package: "edith", Entries: []ManifestEntry{
+		{Kind: EntryDir, Path: configDir},
+		{Kind: EntryFile, Path: config, SHA256: "as-installed"},
+		{Kind: EntryFile, Path: binary, SHA256: "as-installed"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	check, err := m.VerifyFiles("edith")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Checked != 1 || !reflect.DeepEqual(check.Modified, []string{binary}) {
+		t.Errorf("checked %d, modified %v, want 1 and only %s", check.Checked, check.Modified, binary)
+	}
+}
@@ -0,0 +1,407 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Packfile is a declared set of packages, written by Export and applied
+// with Import or Sync
+type Packfile struct {
+	Packages []PackfileEntry
+}
+
+// PackfileEntry is one package in a packfile
+type PackfileEntry struct {
+	Name string
+	// Repo is the source the package comes from, or "local"
+	Repo string
+	// Pin is the git ref the package is pinned to, if any
+	Pin  string
+	Held bool
+	// Reason is ReasonDependency for packages only there for another one
+	Reason string
+}
+
+// spec is the entry as name or name@pin
+func (e PackfileEntry) spec() string {
+	if e.Pin != "" {
+		return e.Name + "@" + e.Pin
+	}
+	return e.Name
+}
+
+// ParsePackfile reads the package blocks of a packfile
+func ParsePackfile(content []byte) (*Packfile, error) {
+	file, err := ParseBox(content)
+	if err != nil {
+		return nil, err
+	}
+
+	pf := &Packfile{}
+	seen := make(map[string]bool)
+	for _, block := range file.Blocks {
+		if block.Name != "package" {
+			continue
+		}
+
+		entry := PackfileEntry{
+			Name:   block.String("name"),
+			Repo:   block.String("repo"),
+			Pin:    block.String("pin"),
+			Held:   block.String("held") == "true",
+			Reason: block.String("reason"),
+		}
+		if entry.Name == "" {
+			return nil, &BoxSyntaxError{Line: block.Line, Msg: "package block has no name"}
+		}
+		if seen[entry.Name] {
+			return nil, &BoxSyntaxError{Line: block.Line, Msg: fmt.Sprintf("%s is listed twice", entry.Name)}
+		}
+		seen[entry.Name] = true
+		pf.Packages = append(pf.Packages, entry)
+	}
+
+	return pf, nil
+}
+
+// ReadPackfile parses the packfile at path
+func ReadPackfile(path string) (*Packfile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pf, err := ParsePackfile(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return pf, nil
+}
+
+// Marshal renders the packfile with one data block per package
+func (pf *Packfile) Marshal() []byte {
+	var b strings.Builder
+	b.WriteString("# packages for pack import or pack sync\n")
+	for _, entry := range pf.Packages {
+		b.WriteString("\n[data -c package]\n")
+		fmt.Fprintf(&b, "  name %s\n", quoteBoxValue(entry.Name))
+		if entry.Repo != "" {
+			fmt.Fprintf(&b, "  repo %s\n", quoteBoxValue(entry.Repo))
+		}
+		if entry.Pin != "" {
+			fmt.Fprintf(&b, "  pin %s\n", quoteBoxValue(entry.Pin))
+		}
+		if entry.Held {
+			b.WriteString("  held true\n")
+		}
+		if entry.Reason == ReasonDependency {
+			fmt.Fprintf(&b, "  reason %s\n", ReasonDependency)
+		}
+		b.WriteString("end\n")
+	}
+	return []byte(b.String())
+}
+
+// Export returns a packfile of every installed package, taken from the locks
+func (m *Manager) Export() (*Packfile, error) {
+	installed, err := m.Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	pf := &Packfile{}
+	for _, pkg := range installed {
+		if pkg.Err != nil {
+			fmt.Fprintf(m.out, "warning: leaving out %s: %v\n", pkg.Name, pkg.Err)
+			continue
+		}
+
+		entry := PackfileEntry{
+			Name: pkg.Name,
+			Repo: pkg.Lock.Repo,
+			Held: pkg.Lock.Held,
+		}
+		if pkg.Lock.Pinned {
+			entry.Pin = pkg.Lock.SrcRef
+		}
+		if pkg.Lock.InstallReason == ReasonDependency {
+			entry.Reason = ReasonDependency
+		}
+		pf.Packages = append(pf.Packages, entry)
+	}
+
+	sort.Slice(pf.Packages, func(i, j int) bool {
+		return pf.Packages[i].Name < pf.Packages[j].Name
+	})
+	return pf, nil
+}
+
+// SyncOptions controls Import and Sync
+type SyncOptions struct {
+	// Prune closes installed packages the packfile doesn't list, other
+	// than pack itself and boxlang
+	Prune bool
+	// Jobs is how many recipes may run at once, as in InstallOptions
+	Jobs int
+}
+
+// SyncResult describes what Import or Sync did
+type SyncResult struct {
+	// Installed were missing and have been opened
+	Installed []string
+	// Changed were reopened at a different pin or source, held or released
+	Changed []string
+	// Removed were closed because the packfile doesn't list them
+	Removed []string
+	// UpToDate already matched the packfile
+	UpToDate []string
+	Failed   []*PackageError
+}
+
+// syncPlan is what reconcile will do
+type syncPlan struct {
+	install   []PackfileEntry
+	reinstall []PackfileEntry
+	hold      []PackfileEntry
+	remove    []*Lockfile
+}
+
+func (p *syncPlan) empty() bool {
+	return len(p.install)+len(p.reinstall)+len(p.hold)+len(p.remove) == 0
+}
+
+// Import opens every package in pf that isn't installed, from the source
+// and at the pin it lists, once the prompter confirms. Installed packages
+// are left as they are.
+func (m *Manager) Import(pf *Packfile, opts SyncOptions) (result *SyncResult, err error) {
+	defer m.journal("import")(&err)
+	return m.reconcile(pf, opts, false)
+}
+
+// Sync makes the installed packages match pf once the prompter confirms:
+// missing packages are opened, ones at a different pin or source are
+// reopened, holds are set or released and, with opts.Prune, packages pf
+// doesn't list are closed.
+func (m *Manager) Sync(pf *Packfile, opts SyncOptions) (result *SyncResult, err error) {
+	defer m.journal("sync")(&err)
+	return m.reconcile(pf, opts, true)
+}
+
+// reconcile works out and carries out the changes pf asks for. Only
+// missing packages are touched unless sync is set.
+func (m *Manager) reconcile(pf *Packfile, opts SyncOptions, sync bool) (*SyncResult, error) {
+	result := &SyncResult{}
+	plan, err := m.planSync(pf, opts, sync, result)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.empty() {
+		return result, nil
+	}
+
+	m.printSyncPlan(plan)
+	ok, err := m.prompt.Confirm("\napply these changes?")
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, ErrCancelled
+	}
+
+	// Packages on their way out go first so they can't conflict with what
+	// comes in, dependents before their dependencies
+	for _, lock := range plan.remove {
+		fmt.Fprintf(m.out, "closing %s...\n", lock.Package)
+		if err := m.Uninstall(lock.Package); err != nil {
+			result.Failed = append(result.Failed, &PackageError{Package: lock.Package, Err: err})
+			continue
+		}
+		result.Removed = append(result.Removed, lock.Package)
+	}
+
+	if len(plan.install)+len(plan.reinstall) > 0 {
+		m.syncInstall(plan, opts, result)
+	}
+
+	for _, entry := range plan.hold {
+		if _, err := m.setHeld(entry.Name, entry.Held); err != nil {
+			result.Failed = append(result.Failed, &PackageError{Package: entry.Name, Err: err})
+			continue
+		}
+		result.Changed = append(result.Changed, entry.Name)
+	}
+
+	return result, nil
+}
+
+// planSync compares pf with what is installed. Packages whose source isn't
+// configured are failed straight away.
+func (m *Manager) planSync(pf *Packfile, opts SyncOptions, sync bool, result *SyncResult) (*syncPlan, error) {
+	installed, err := m.Installed()
+	if err != nil {
+		return nil, err
+	}
+	locks := make(map[string]*Lockfile)
+	for _, pkg := range installed {
+		if pkg.Err == nil {
+			locks[pkg.Name] = pkg.Lock
+		}
+	}
+
+	sources, err := m.Sources()
+	if err != nil {
+		return nil, err
+	}
+	configured := map[string]bool{"local": true}
+	for _, source := range sources {
+		configured[source.URL] = true
+	}
+
+	plan := &syncPlan{}
+	listed := make(map[string]bool)
+	for _, entry := range pf.Packages {
+		listed[entry.Name] = true
+		lock := locks[entry.Name]
+
+		pin := ""
+		if lock != nil && lock.Pinned {
+			pin = lock.SrcRef
+		}
+		needsBuild := lock == nil || (sync && (pin != entry.Pin || (entry.Repo != "" && lock.Repo != entry.Repo)))
+		if needsBuild && entry.Repo != "" && !configured[entry.Repo] {
+			result.Failed = append(result.Failed, &PackageError{
+				Package: entry.Name,
+				Err:     fmt.Errorf("source %s is not configured (add it with pack add-source)", entry.Repo),
+			})
+			continue
+		}
+
+		switch {
+		case lock == nil:
+			plan.install = append(plan.install, entry)
+		case needsBuild:
+			plan.reinstall = append(plan.reinstall, entry)
+		case sync && lock.Held != entry.Held:
+			plan.hold = append(plan.hold, entry)
+		default:
+			result.UpToDate = append(result.UpToDate, entry.Name)
+		}
+	}
+
+	if sync && opts.Prune {
+		for _, pkg := range installed {
+			// pack can't run without the core packages, listed or not
+			if pkg.Err == nil && !listed[pkg.Name] && !containsString(corePackages, pkg.Name) {
+				plan.remove = append(plan.remove, pkg.Lock)
+			}
+		}
+		sort.SliceStable(plan.remove, func(i, j int) bool {
+			return plan.remove[i].InstallReason != ReasonDependency && plan.remove[j].InstallReason == ReasonDependency
+		})
+	}
+
+	return plan, nil
+}
+
+// printSyncPlan shows what reconcile is about to do
+func (m *Manager) printSyncPlan(plan *syncPlan) {
+	for _, entry := range plan.install {
+		fmt.Fprintf(m.out, "+ open %s\n", entry.spec())
+	}
+	for _, entry := range plan.reinstall {
+		fmt.Fprintf(m.out, "~ reopen %s\n", entry.spec())
+	}
+	for _, entry := range plan.hold {
+		if entry.Held {
+			fmt.Fprintf(m.out, "~ hold %s\n", entry.Name)
+		} else {
+			fmt.Fprintf(m.out, "~ unhold %s\n", entry.Name)
+		}
+	}
+	for _, lock := range plan.remove {
+		fmt.Fprintf(m.out, "- close %s\n", lock.Package)
+	}
+}
+
+// syncInstall opens and reopens the packages in plan, each from the source
+// the packfile names, then gives them the hold and install reason it lists
+func (m *Manager) syncInstall(plan *syncPlan, opts SyncOptions, result *SyncResult) {
+	entries := append(append([]PackfileEntry{}, plan.install...), plan.reinstall...)
+	specs := make([]string, len(entries))
+	m.sourceFor = make(map[string]string)
+	for i, entry := range entries {
+		specs[i] = entry.spec()
+		if entry.Repo != "" {
+			m.sourceFor[entry.Name] = entry.Repo
+		}
+	}
+	defer func() { m.sourceFor = nil }()
+
+	installPlan, err := m.plan(specs, true)
+	if err != nil {
+		for _, entry := range entries {
+			result.Failed = append(result.Failed, &PackageError{Package: entry.Name, Err: err})
+		}
+		return
+	}
+
+	batch := &BatchResult{}
+	m.runPlan(installPlan, InstallOptions{Jobs: opts.Jobs}, batch)
+	result.Failed = append(result.Failed, batch.Failed...)
+	result.Failed = append(result.Failed, batch.Skipped...)
+
+	done := make(map[string]*Lockfile)
+	for _, installed := range batch.Installed {
+		done[installed.Package] = installed.Lock
+	}
+
+	for i, entry := range entries {
+		lock := done[entry.Name]
+		if lock == nil {
+			continue
+		}
+		if i < len(plan.install) {
+			result.Installed = append(result.Installed, entry.Name)
+		} else {
+			result.Changed = append(result.Changed, entry.Name)
+		}
+
+		reason := ReasonExplicit
+		if entry.Reason == ReasonDependency {
+			reason = ReasonDependency
+		}
+		if lock.Held == entry.Held && lock.InstallReason == reason {
+			continue
+		}
+		lock.Held = entry.Held
+		lock.InstallReason = reason
+		if err := m.saveLock(lock); err != nil {
+			fmt.Fprintf(m.out, "warning: failed to update lock for %s: %v\n", entry.Name, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePackfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []PackfileEntry
+		err     string
+	}{
+		{
+			name:    "empty",
+			content: "# nothing yet\n",
+		},
+		{
+			name: "every field",
+			content: `[data -c package]
+  name edith
+  repo https://github.com/shrub4thedub/pack-repo
+  pin v1.2.0
+  held true
+end
+
+[data -c package]
+  name libfoo
+  reason dependency
+end
+`,
+			want: []PackfileEntry{
+				{Name: "edith", Repo: "https://github.com/shrub4thedub/pack-repo", Pin: "v1.2.0", Held: true},
+				{Name: "libfoo", Reason: ReasonDependency},
+			},
+		},
+		{
+			name:    "other blocks are ignored",
+			content: "[data -c notes]\n  name not-a-package\nend\n[data -c package]\n  name edith\n  held false\nend\n",
+			want:    []PackfileEntry{{Name: "edith"}},
+		},
+		{
+			name:    "package without a name",
+			content: "[data -c package]\n  repo local\nend\n",
+			err:     "line 1: package block has no name",
+		},
+		{
+			name:    "package listed twice",
+			content: "[data -c package]\n  name edith\nend\n[data -c package]\n  name edith\nend\n",
+			err:     "line 4: edith is listed twice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pf, err := ParsePackfile([]byte(tt.content))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pf.Packages, tt.want) {
+				t.Errorf("packages = %+v, want %+v", pf.Packages, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackfileRoundTrip(t *testing.T) {
+	pf := &Packfile{Packages: []PackfileEntry{
+		{Name: "edith", Repo: "file:///srv/my repo", Pin: "v1", Held: true},
+		{Name: "libfoo", Repo: "local", Reason: ReasonDependency},
+	}}
+	got, err := ParsePackfile(pf.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pf) {
+		t.Errorf("round trip = %+v, want %+v", got, pf)
+	}
+}
+
+func TestPlanSyncPruneKeepsCorePackages(t *testing.T) {
+	m := testManager(t)
+	if err := os.MkdirAll(m.configPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.sourcesFile(), []byte("[data -c sources]\n  repo local\nend\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pack", "boxlang", "edith", "extra"} {
+		lock := &Lockfile{Package: name, Repo: "local", InstallReason: ReasonExplicit}
+		if err := lock.Write(m.getLockFilePath(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pf := &Packfile{Packages: []PackfileEntry{{Name: "edith", Repo: "local"}}}
+	for _, prune := range []bool{false, true} {
+		plan, err := m.planSync(pf, SyncOptions{Prune: prune}, true, &SyncResult{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var removed []string
+		for _, lock := range plan.remove {
+			removed = append(removed, lock.Package)
+		}
+		want := ""
+		if prune {
+			want = "extra"
+		}
+		if strings.Join(removed, " ") != want {
+			t.Errorf("prune %v removes %q, want %q", prune, removed, want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pinEnvVar carries a pinned ref to the box script, which can read it
+// with env and use it in place of its own src-ref
+const pinEnvVar = "PACK_SRC_REF"
+
+// SplitPin splits a package spec of the form name@ref. ref is empty when
+// the spec is not pinned.
+func SplitPin(spec string) (name, ref string, err error) {
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		if i == len(spec)-1 {
+			return "", "", fmt.Errorf("%s: missing ref after @", spec)
+		}
+		return spec[:i], spec[i+1:], nil
+	}
+	return spec, "", nil
+}
+
+// isCommitRef reports whether ref looks like an abbreviated or full commit
+// hash rather than a branch or tag name
+func isCommitRef(ref string) bool {
+	if len(ref) < 7 || len(ref) > 40 {
+		return false
+	}
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveGitRef returns the short commit hash ref points at in repoURL.
+// Commit hashes are taken as they are since ls-remote only lists refs.
+func resolveGitRef(repoURL, ref string) (string, error) {
+	commit, err := getGitRefCommit(repoURL, ref)
+	if err == nil {
+		return commit, nil
+	}
+	if isCommitRef(ref) {
+		return shortCommit(ref), nil
+	}
+	return "", err
+}
+
+// shortCommit abbreviates a commit hash to the 8 characters locks use
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
+
+// pinEnv returns the environment a box script runs with for ref
+func pinEnv(ref string) []string {
+	if ref == "" {
+		return nil
+	}
+	return []string{pinEnvVar + "=" + ref}
+}
+
+// checkPin makes sure the recipe at scriptPath can be pinned to ref
+func checkPin(packageName, ref, scriptPath string) error {
+	if ref == "" {
+		return nil
+	}
+
+	srcType, _, _, err := extractSourceFields(scriptPath)
+	if err != nil {
+		return fmt.Errorf("cannot pin %s: %v", packageName, err)
+	}
+	if srcType != "git" {
+		return fmt.Errorf("cannot pin %s to %s: pins need a git source, not %s", packageName, ref, srcType)
+	}
+
+	// A recipe that ignores the pin would build its own src-ref instead
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(content), pinEnvVar) {
+		return fmt.Errorf("cannot pin %s to %s: its recipe doesn't read %s", packageName, ref, pinEnvVar)
+	}
+	return nil
+}
+
+// findCheckout returns the clone of srcURL a recipe left in dir, looking at
+// dir itself and the directories directly inside it
+func findCheckout(dir, srcURL string) (string, bool) {
+	candidates := []string{dir}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			candidates = append(candidates, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(filepath.Join(candidate, ".git")); err != nil {
+			continue
+		}
+		origin, err := gitIn(candidate, "remote", "get-url", "origin")
+		if err == nil && sameRemote(origin, srcURL) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// sameRemote compares git urls ignoring a trailing slash or .git
+func sameRemote(a, b string) bool {
+	trim := func(url string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	}
+	return trim(a) == trim(b)
+}
+
+// gitIn runs git in dir and returns its trimmed output
+func gitIn(dir string, args ...string) (string, error) {
+	output, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// recipeCheckout returns the clone of its source the recipe at scriptPath
+// left next to it and the commit checked out there, if it left one behind
+func recipeCheckout(scriptPath string) (repoDir, commit string, ok bool) {
+	_, srcURL, _, err := extractSourceFields(scriptPath)
+	if err != nil {
+		return "", "", false
+	}
+	repoDir, ok = findCheckout(filepath.Dir(scriptPath), srcURL)
+	if !ok {
+		return "", "", false
+	}
+	commit, err = gitIn(repoDir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", "", false
+	}
+	return repoDir, commit, true
+}
+
+// checkPinnedCheckout fails when the recipe at scriptPath built something
+// other than ref. Recipes that don't leave their clone behind can't be
+// checked and are trusted to have used the pin.
+func checkPinnedCheckout(scriptPath, ref string) error {
+	repoDir, commit, ok := recipeCheckout(scriptPath)
+	if !ok {
+		return nil
+	}
+
+	want, err := gitIn(repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		want, err = gitIn(repoDir, "rev-parse", "--verify", "--quiet", "origin/"+ref+"^{commit}")
+	}
+	if err != nil {
+		return fmt.Errorf("pinned ref %s is not in the recipe's checkout", ref)
+	}
+	if commit != want {
+		return fmt.Errorf("recipe built %s, not the pinned %s (%s)", shortCommit(commit), ref, shortCommit(want))
+	}
+	return nil
+}
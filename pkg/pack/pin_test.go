@@ -0,0 +1,206 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitPin(t *testing.T) {
+	tests := []struct {
+		spec, name, ref string
+		wantErr         bool
+	}{
+		{"edith", "edith", "", false},
+		{"edith@v1.2.0", "edith", "v1.2.0", false},
+		{"edith@abc1234", "edith", "abc1234", false},
+		{"@v1", "@v1", "", false},
+		{"a@b@c", "a@b", "c", false},
+		{"edith@", "", "", true},
+	}
+	for _, tt := range tests {
+		name, ref, err := SplitPin(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitPin(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if name != tt.name || ref != tt.ref {
+			t.Errorf("SplitPin(%q) = %q, %q, want %q, %q", tt.spec, name, ref, tt.name, tt.ref)
+		}
+	}
+}
+
+func TestIsCommitRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"abc1234", true},
+		{"abc12345", true},
+		{"0123456789abcdef0123456789abcdef01234567", true},
+		{"abc123", false},
+		{"0123456789abcdef0123456789abcdef012345678", false},
+		{"ABC1234", false},
+		{"v1.2.0", false},
+		{"main", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isCommitRef(tt.ref); got != tt.want {
+			t.Errorf("isCommitRef(%q) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestResolveGitRefCommitFallback(t *testing.T) {
+	// Not a repository, so ls-remote fails and commit hashes are taken as given
+	repo := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		ref, want string
+	}{
+		{"abc1234", "abc1234"},
+		{"abc12345", "abc12345"},
+		{"0123456789abcdef0123456789abcdef01234567", "01234567"},
+	}
+	for _, tt := range tests {
+		got, err := resolveGitRef(repo, tt.ref)
+		if err != nil {
+			t.Errorf("resolveGitRef(%q): %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveGitRef(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+
+	if _, err := resolveGitRef(repo, "v1.2.0"); err == nil {
+		t.Error("resolveGitRef of an unknown tag succeeded")
+	}
+}
+
+func TestResolveGitRefAnnotatedTag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	origin := t.TempDir()
+	git(t, origin, "init", "-q")
+	git(t, origin, "commit", "-q", "--allow-empty", "-m", "one")
+	git(t, origin, "tag", "v1")
+	git(t, origin, "tag", "-a", "-m", "release", "v2")
+	head := shortCommit(git(t, origin, "rev-parse", "HEAD"))
+
+	// All three resolve to the commit, not the tag object ls-remote lists v2 as
+	for _, ref := range []string{"HEAD", "v1", "v2"} {
+		got, err := resolveGitRef(origin, ref)
+		if err != nil {
+			t.Errorf("resolveGitRef(%q): %v", ref, err)
+			continue
+		}
+		if got != head {
+			t.Errorf("resolveGitRef(%q) = %q, want %q", ref, got, head)
+		}
+	}
+}
+
+// writeRecipe writes a git recipe for srcURL with body into dir
+func writeRecipe(t *testing.T, dir, srcURL, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, "app.box")
+	content := "[data -c pkg]\n  name app\n  src-type git\n  src-url " + srcURL + "\n  src-ref HEAD\nend\n" + body
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckPin(t *testing.T) {
+	dir := t.TempDir()
+
+	reads := writeRecipe(t, dir, "https://example.com/app.git", "[fn fetch]\n  env PACK_SRC_REF\nend\n")
+	if err := checkPin("app", "v1", reads); err != nil {
+		t.Errorf("recipe reading the pin: %v", err)
+	}
+	if err := checkPin("app", "", reads); err != nil {
+		t.Errorf("no pin: %v", err)
+	}
+
+	ignores := writeRecipe(t, dir, "https://example.com/app.git", "[fn fetch]\n  run git clone https://example.com/app.git\nend\n")
+	if err := checkPin("app", "v1", ignores); err == nil || !strings.Contains(err.Error(), pinEnvVar) {
+		t.Errorf("recipe ignoring the pin: got %v, want an error about %s", err, pinEnvVar)
+	}
+}
+
+// git runs git in dir for a test
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=pack", "-c", "user.email=pack@example.com"}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestCheckPinnedCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	origin := t.TempDir()
+	git(t, origin, "init", "-q")
+	git(t, origin, "commit", "-q", "--allow-empty", "-m", "one")
+	git(t, origin, "tag", "v1")
+	git(t, origin, "commit", "-q", "--allow-empty", "-m", "two")
+	v1 := git(t, origin, "rev-parse", "v1")
+
+	work := t.TempDir()
+	script := writeRecipe(t, work, origin, "")
+	clone := filepath.Join(work, "app-source")
+	git(t, work, "clone", "-q", origin, clone)
+
+	// The recipe built HEAD instead of the pin
+	if err := checkPinnedCheckout(script, "v1"); err == nil {
+		t.Error("checkout at HEAD passed as v1")
+	}
+
+	git(t, clone, "checkout", "-q", "v1")
+	if err := checkPinnedCheckout(script, "v1"); err != nil {
+		t.Errorf("checkout at v1: %v", err)
+	}
+	if err := checkPinnedCheckout(script, v1[:7]); err != nil {
+		t.Errorf("checkout at %s: %v", v1[:7], err)
+	}
+	if _, commit, ok := recipeCheckout(script); !ok || commit != v1 {
+		t.Errorf("recipeCheckout = %q, %v, want %q", commit, ok, v1)
+	}
+
+	// Nothing left behind to check
+	if err := checkPinnedCheckout(writeRecipe(t, t.TempDir(), origin, ""), "v1"); err != nil {
+		t.Errorf("no checkout: %v", err)
+	}
+}
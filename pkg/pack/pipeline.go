@@ -0,0 +1,382 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// preparedStep is a plan step whose recipe has been downloaded and checked
+type preparedStep struct {
+	step       *PlanStep
+	tempDir    string
+	scriptPath string
+	bins       []string
+	// replaces are installed packages to close right before the build
+	replaces  []string
+	verifyErr error
+	verified  bool
+	err       error
+}
+
+// runPlan installs plan in three phases. Every recipe and signature is
+// downloaded and verified at once by a pool of workers, then conflicts and
+// reviews are dealt with one package at a time in plan order, and only then
+// do recipes run, opts.Jobs at a time, each once its dependencies are in.
+// Packages a step replaces are closed just before it runs and put back if
+// it fails. A step whose dependency failed or was skipped is skipped too.
+func (m *Manager) runPlan(plan *InstallPlan, opts InstallOptions, batch *BatchResult) {
+	prepared := m.fetchPlan(plan)
+	defer func() {
+		for _, p := range prepared {
+			if p.tempDir != "" {
+				os.RemoveAll(p.tempDir)
+			}
+		}
+	}()
+
+	failed := make(map[string]bool)
+	m.reviewPlan(prepared, failed, batch)
+	m.buildPlan(prepared, opts, failed, batch)
+}
+
+// fetchPlan downloads and verifies every recipe in plan concurrently,
+// reporting each package as it is done
+func (m *Manager) fetchPlan(plan *InstallPlan) []*preparedStep {
+	total := len(plan.Steps)
+	prepared := make([]*preparedStep, total)
+	for i, step := range plan.Steps {
+		prepared[i] = &preparedStep{step: step}
+	}
+
+	// Keys are fetched once per source up front rather than by every worker
+	seen := make(map[string]bool)
+	for _, step := range plan.Steps {
+		if repo := step.Source.Name; step.Source.Type != "local" && !seen[repo] {
+			seen[repo] = true
+			m.fetchPublicKeyFromRepo(repo)
+		}
+	}
+
+	jobs := make(chan *preparedStep, total)
+	results := make(chan *preparedStep, total)
+	for w := 0; w < recipeFetchWorkers && w < total; w++ {
+		go func() {
+			for p := range jobs {
+				m.prepareStep(p)
+				results <- p
+			}
+		}()
+	}
+	for _, p := range prepared {
+		jobs <- p
+	}
+	close(jobs)
+
+	for done := 1; done <= total; done++ {
+		p := <-results
+		status := "✓ verified"
+		switch {
+		case p.err != nil:
+			status = fmt.Sprintf("✗ %v", p.err)
+		case p.verifyErr != nil:
+			status = "⚠️  not verified"
+		case p.step.Source.Type == "local":
+			status = "local"
+		}
+		fmt.Fprintf(m.out, "[%d/%d] fetched %s: %s\n", done, total, p.step.Package, status)
+	}
+
+	return prepared
+}
+
+// prepareStep downloads p's recipe and signature and verifies them
+func (m *Manager) prepareStep(p *preparedStep) {
+	step := p.step
+
+	tempDir, err := os.MkdirTemp(m.path("tmp"), "pack-"+step.Package)
+	if err != nil {
+		p.err = fmt.Errorf("failed to create temp directory: %v", err)
+		return
+	}
+	p.tempDir = tempDir
+	p.scriptPath = filepath.Join(tempDir, step.Package+".box")
+
+	if err := m.downloadFrom(step.Source, p.scriptPath); err != nil {
+		p.err = fmt.Errorf("failed to download script: %v", err)
+		return
+	}
+	if err := checkPin(step.Package, step.Ref, p.scriptPath); err != nil {
+		p.err = err
+		return
+	}
+
+	block, err := recipeData(p.scriptPath)
+	if err != nil {
+		p.err = err
+		return
+	}
+	p.bins = recipeList(block, "bin")
+
+	p.verifyErr = m.VerifyRecipe(p.scriptPath, step.Source.Name)
+}
+
+// missingDep returns the first dependency of step that failed or was
+// skipped, if any
+func missingDep(step *PlanStep, failed map[string]bool) string {
+	for _, dep := range append(append([]string{}, step.BuildDeps...), step.Deps...) {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// skipStep records that step will not be installed because dep was not
+func (m *Manager) skipStep(step *PlanStep, dep string, failed map[string]bool, batch *BatchResult) {
+	err := fmt.Errorf("skipped because dependency %s was not installed", dep)
+	fmt.Fprintf(m.out, "✗ Skipping %s: dependency %s was not installed\n", step.Package, dep)
+	batch.Skipped = append(batch.Skipped, &PackageError{Package: step.Package, Err: err})
+	failed[step.Package] = true
+}
+
+// failStep records that step could not be installed
+func (m *Manager) failStep(step *PlanStep, err error, failed map[string]bool, batch *BatchResult) {
+	fmt.Fprintf(m.out, "✗ Failed to install %s: %v\n", step.Package, err)
+	batch.Failed = append(batch.Failed, &PackageError{Package: step.Package, Err: err})
+	failed[step.Package] = true
+}
+
+// reviewPlan resolves conflicts and gets every recipe reviewed before
+// anything runs, marking the steps that won't be installed in failed
+func (m *Manager) reviewPlan(prepared []*preparedStep, failed map[string]bool, batch *BatchResult) {
+	for i, p := range prepared {
+		step := p.step
+		if p.err != nil {
+			m.failStep(step, p.err, failed, batch)
+			continue
+		}
+		if dep := missingDep(step, failed); dep != "" {
+			m.skipStep(step, dep, failed, batch)
+			continue
+		}
+
+		fmt.Fprintf(m.out, "\nreviewing %s (%d/%d)\n", step.Package, i+1, len(prepared))
+
+		// Refuse to let one package's binaries overwrite another's. What it
+		// replaces is only closed once the recipe is accepted and about to run.
+		replaces, err := m.confirmConflicts(step.Package, p.scriptPath)
+		if err != nil {
+			p.err = err
+			m.failStep(step, err, failed, batch)
+			continue
+		}
+		p.replaces = replaces
+
+		verified, err := m.review(step.Package, p.scriptPath, p.verifyErr, "installation")
+		if err != nil {
+			p.err = err
+			m.failStep(step, err, failed, batch)
+			continue
+		}
+		p.verified = verified
+	}
+}
+
+// buildPlan runs the recipes that made it through review, in plan order and
+// up to opts.Jobs at a time. A step waits for everything it depends on in
+// the plan before it starts.
+func (m *Manager) buildPlan(prepared []*preparedStep, opts InstallOptions, failed map[string]bool, batch *BatchResult) {
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// With several recipes running at once the bin directory changes under
+	// each of them, so links and bins belonging to the others are kept out
+	// of each package's manifest
+	if jobs > 1 {
+		claims := m.binClaims(prepared)
+		for _, p := range prepared {
+			packageName := p.step.Package
+			p.step.foreign = func(path string) bool {
+				owner := claims(path)
+				return owner != "" && owner != packageName
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+	done := make(map[string]chan struct{})
+	for _, p := range prepared {
+		done[p.step.Package] = make(chan struct{})
+	}
+
+	total, started := 0, 0
+	for _, p := range prepared {
+		if p.err == nil && !failed[p.step.Package] {
+			total++
+		}
+	}
+
+	for _, p := range prepared {
+		p, step := p, p.step
+
+		mu.Lock()
+		ready := p.err == nil && !failed[step.Package]
+		mu.Unlock()
+		if !ready {
+			close(done[step.Package])
+			continue
+		}
+
+		for _, dep := range append(append([]string{}, step.BuildDeps...), step.Deps...) {
+			if ch, ok := done[dep]; ok {
+				<-ch
+			}
+		}
+
+		mu.Lock()
+		dep := missingDep(step, failed)
+		if dep != "" {
+			m.skipStep(step, dep, failed, batch)
+		}
+		mu.Unlock()
+		if dep != "" {
+			close(done[step.Package])
+			continue
+		}
+
+		sem <- struct{}{}
+		started++
+		fmt.Fprintf(m.out, "\n[%d/%d] Installing %s...\n", started, total, step.Package)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[step.Package])
+			defer func() { <-sem }()
+
+			result, err := m.buildStep(p, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				m.failStep(step, err, failed, batch)
+				return
+			}
+			fmt.Fprintf(m.out, "✓ Successfully installed %s\n", step.Package)
+			batch.Installed = append(batch.Installed, result)
+		}()
+	}
+
+	wg.Wait()
+
+	// Report in plan order however the builds finished
+	order := make(map[string]int)
+	for i, p := range prepared {
+		order[p.step.Package] = i
+	}
+	sort.SliceStable(batch.Installed, func(i, j int) bool {
+		return order[batch.Installed[i].Package] < order[batch.Installed[j].Package]
+	})
+	sort.SliceStable(batch.Failed, func(i, j int) bool {
+		return order[batch.Failed[i].Package] < order[batch.Failed[j].Package]
+	})
+}
+
+// buildStep runs a reviewed recipe and writes its lock
+func (m *Manager) buildStep(p *preparedStep, opts InstallOptions) (*InstallResult, error) {
+	step := p.step
+
+	if opts.Verbose {
+		fmt.Fprintf(m.out, "executing installation script for %s...\n", step.Package)
+	}
+	if step.Ref != "" {
+		fmt.Fprintf(m.out, "%s pinned to %s\n", step.Package, step.Ref)
+	}
+
+	closed, err := m.closeReplaced(step.Package, p.replaces, p.tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, _ := m.Lock(step.Package)
+	generation, err := m.runRecipe(step, LogInstall, p.tempDir, p.scriptPath)
+	if err != nil {
+		m.restoreReplaced(closed)
+		return nil, err
+	}
+
+	// Without a lock pack can't update, roll back or undo the package, so
+	// the install counts as failed and is taken back
+	lock, err := m.writeLock(step, p.scriptPath, generation)
+	if err != nil {
+		m.discardGeneration(step.Package, generation, previous)
+		m.restoreReplaced(closed)
+		return nil, fmt.Errorf("failed to create lock file for %s: %v", step.Package, err)
+	}
+
+	return &InstallResult{
+		Package:  step.Package,
+		Source:   step.Source,
+		Verified: p.verified,
+		Lock:     lock,
+	}, nil
+}
+
+// binClaims returns a function naming the package in prepared that a path
+// in the bin directory belongs to: one of its bins, or a link into its
+// shelf
+func (m *Manager) binClaims(prepared []*preparedStep) func(path string) string {
+	bins := make(map[string]string)
+	for _, p := range prepared {
+		for _, bin := range p.bins {
+			bins[filepath.Join(m.binDir, bin)] = p.step.Package
+		}
+	}
+
+	return func(path string) string {
+		if filepath.Dir(path) != m.binDir {
+			return ""
+		}
+		if owner, ok := bins[path]; ok {
+			return owner
+		}
+		if target, err := os.Readlink(path); err == nil {
+			for _, p := range prepared {
+				shelf := m.packageShelf(p.step.Package)
+				if strings.HasPrefix(target, shelf+string(filepath.Separator)) {
+					return p.step.Package
+				}
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Policy says how a PolicyPrompter answers questions on its own
+type Policy struct {
+	// AssumeYes answers every confirmation and review with yes and picks the
+	// first source. Without it questions go to the next prompter, or fail.
+	AssumeYes bool
+	// SkipReview accepts recipes without showing them
+	SkipReview bool
+	// Source picks the source whose name contains it when a package is in
+	// more than one; "local" matches the local repository
+	Source string
+}
+
+// PolicyPrompter answers questions by policy so pack can run unattended.
+// Whatever the policy doesn't cover goes to next, and fails when next is
+// nil instead of waiting on input that will never come. Recipes that fail
+// verification are never accepted on its say-so.
+type PolicyPrompter struct {
+	policy Policy
+	next   Prompter
+	out    io.Writer
+}
+
+// NewPolicyPrompter returns a prompter answering by policy, handing anything
+// else to next. Recipes accepted under AssumeYes are still printed to out.
+func NewPolicyPrompter(policy Policy, next Prompter, out io.Writer) *PolicyPrompter {
+	if out == nil {
+		out = io.Discard
+	}
+	return &PolicyPrompter{policy: policy, next: next, out: out}
+}
+
+// IsTerminal reports whether f is a terminal someone can answer questions on
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func unanswered(question string) error {
+	return fmt.Errorf("cannot answer %q without a terminal (use --yes to assume yes)", question)
+}
+
+// Confirm says yes under AssumeYes
+func (p *PolicyPrompter) Confirm(question string) (bool, error) {
+	if p.policy.AssumeYes {
+		fmt.Fprintf(p.out, "%s [y/N]: y (--yes)\n", question)
+		return true, nil
+	}
+	if p.next == nil {
+		return false, unanswered(question)
+	}
+	return p.next.Confirm(question)
+}
+
+// Choose picks the named source, or the first one under AssumeYes
+func (p *PolicyPrompter) Choose(question string, options []string) (int, error) {
+	if p.policy.Source != "" {
+		want := strings.ToLower(p.policy.Source)
+		for i, option := range options {
+			if strings.Contains(strings.ToLower(option), want) {
+				fmt.Fprintf(p.out, "using %s (--source)\n", option)
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no source matching %q: %s", p.policy.Source, strings.Join(options, ", "))
+	}
+	if p.policy.AssumeYes && len(options) > 0 {
+		fmt.Fprintf(p.out, "using %s (--yes)\n", options[0])
+		return 0, nil
+	}
+	if p.next == nil {
+		return 0, unanswered(question)
+	}
+	return p.next.Choose(question, options)
+}
+
+// Review accepts the recipe under SkipReview, or prints it and accepts it
+// under AssumeYes
+func (p *PolicyPrompter) Review(packageName, path string) (bool, error) {
+	if p.policy.SkipReview {
+		return true, nil
+	}
+	if p.policy.AssumeYes {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read script: %v", err)
+		}
+		printRecipe(p.out, content)
+		return true, nil
+	}
+	if p.next == nil {
+		return false, unanswered(fmt.Sprintf("review %s", packageName))
+	}
+	return p.next.Review(packageName, path)
+}
+
+// ConfirmUnverified always says no unless a terminal prompter is there to
+// ask and AssumeYes isn't set; --yes never vouches for a bad signature
+func (p *PolicyPrompter) ConfirmUnverified(question string) (bool, error) {
+	if p.policy.AssumeYes || p.next == nil {
+		fmt.Fprintf(p.out, "%s [y/N]: n (unverified)\n", question)
+		return false, nil
+	}
+	return p.next.ConfirmUnverified(question)
+}
@@ -0,0 +1,232 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Prompter answers the questions pack asks while it works
+type Prompter interface {
+	// Confirm asks a yes/no question where the default is no
+	Confirm(question string) (bool, error)
+	// Choose asks for one of options and returns its index
+	Choose(question string, options []string) (int, error)
+	// Review shows the recipe at path before it runs and may edit it in
+	// place. Returning false cancels the operation.
+	Review(packageName, path string) (bool, error)
+	// ConfirmUnverified asks whether to go ahead with something that
+	// failed signature verification, where the default is no
+	ConfirmUnverified(question string) (bool, error)
+}
+
+// noPrompter is used when no prompter was configured
+type noPrompter struct{}
+
+func (noPrompter) Confirm(question string) (bool, error) {
+	return false, fmt.Errorf("no prompter configured to answer %q", question)
+}
+
+func (noPrompter) Choose(question string, options []string) (int, error) {
+	return 0, fmt.Errorf("no prompter configured to answer %q", question)
+}
+
+func (noPrompter) Review(packageName, path string) (bool, error) {
+	return false, fmt.Errorf("no prompter configured to review %s", packageName)
+}
+
+func (noPrompter) ConfirmUnverified(question string) (bool, error) {
+	return false, fmt.Errorf("no prompter configured to answer %q", question)
+}
+
+// TerminalPrompter asks questions on a terminal
+type TerminalPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewTerminalPrompter returns a prompter reading answers from in
+func NewTerminalPrompter(in io.Reader, out io.Writer) *TerminalPrompter {
+	return &TerminalPrompter{in: bufio.NewReader(in), out: out}
+}
+
+func (p *TerminalPrompter) readLine() (string, error) {
+	response, err := p.in.ReadString('\n')
+	if err != nil && !(err == io.EOF && response != "") {
+		return "", fmt.Errorf("failed to read input: %v", err)
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// Confirm asks question with a [y/N] suffix
+func (p *TerminalPrompter) Confirm(question string) (bool, error) {
+	fmt.Fprintf(p.out, "%s [y/N]: ", question)
+	response, err := p.readLine()
+	if err != nil {
+		return false, err
+	}
+
+	response = strings.ToLower(response)
+	return response == "y" || response == "yes", nil
+}
+
+// ConfirmUnverified asks question the same way as Confirm
+func (p *TerminalPrompter) ConfirmUnverified(question string) (bool, error) {
+	return p.Confirm(question)
+}
+
+// Choose lists options and reads a number, 1 by default
+func (p *TerminalPrompter) Choose(question string, options []string) (int, error) {
+	fmt.Fprintf(p.out, "\n%s\n\n", question)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "%d) %s\n", i+1, option)
+	}
+
+	fmt.Fprint(p.out, "\nSelect [1]: ")
+	input, err := p.readLine()
+	if err != nil {
+		return 0, err
+	}
+
+	if input == "" {
+		input = "1"
+	}
+
+	choice, err := strconv.Atoi(input)
+	if err != nil || choice < 1 || choice > len(options) {
+		return 0, fmt.Errorf("invalid selection")
+	}
+
+	return choice - 1, nil
+}
+
+// Review prints the recipe and asks to proceed, cancel or edit it
+func (p *TerminalPrompter) Review(packageName, path string) (bool, error) {
+	// Read and display the script content
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read script: %v", err)
+	}
+
+	printRecipe(p.out, content)
+
+	for {
+		fmt.Fprint(p.out, "proceed? [y/e/n]: ")
+		response, err := p.readLine()
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(response) {
+		case "y", "yes", "":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "e", "edit":
+			if err := p.editScript(path); err != nil {
+				fmt.Fprintf(p.out, "error editing script: %v\n", err)
+				continue
+			}
+			// Show the updated script and ask again
+			return p.Review(packageName, path)
+		default:
+			fmt.Fprintln(p.out, "enter y/e/n")
+		}
+	}
+}
+
+// printRecipe shows recipe content between rules
+func printRecipe(out io.Writer, content []byte) {
+	fmt.Fprintln(out, "recipe:")
+	fmt.Fprintln(out, "-------")
+	fmt.Fprintln(out, string(content))
+	fmt.Fprintln(out, "-------")
+}
+
+func (p *TerminalPrompter) editScript(scriptPath string) error {
+	// Try to find an editor
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = os.Getenv("VISUAL")
+	}
+
+	// Validate editor from environment variables for security
+	if editor != "" {
+		if !isValidEditor(editor) {
+			fmt.Fprintf(p.out, "warning: potentially unsafe editor '%s' from environment, using fallback\n", editor)
+			editor = ""
+		}
+	}
+
+	if editor == "" {
+		// Try common editors
+		for _, e := range []string{"vim", "nano", "vi"} {
+			if _, err := exec.LookPath(e); err == nil {
+				editor = e
+				break
+			}
+		}
+	}
+	if editor == "" {
+		return fmt.Errorf("no editor found")
+	}
+
+	// Launch editor
+	cmd := exec.Command(editor, scriptPath)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// isValidEditor validates that an editor command is safe to execute
+func isValidEditor(editor string) bool {
+	// Check for basic command injection patterns
+	if strings.Contains(editor, ";") ||
+		strings.Contains(editor, "&") ||
+		strings.Contains(editor, "|") ||
+		strings.Contains(editor, "`") ||
+		strings.Contains(editor, "$") ||
+		strings.Contains(editor, "$(") {
+		return false
+	}
+
+	// Only allow single commands (no spaces except in known safe editors)
+	parts := strings.Fields(editor)
+	if len(parts) == 0 {
+		return false
+	}
+
+	// Check if the base command exists
+	if _, err := exec.LookPath(parts[0]); err != nil {
+		return false
+	}
+
+	return true
+}
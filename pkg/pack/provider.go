@@ -0,0 +1,161 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// defaultBranch is used when a source does not name a branch
+const defaultBranch = "main"
+
+// RepoProvider knows where a repository hosting service serves the files
+// of a pack repository. section is one of repoSections, or "" for recipes
+// kept in the repository root.
+type RepoProvider interface {
+	// Name is the provider name as written in sources.box
+	Name() string
+	// RecipeURL returns the URL of packageName's recipe
+	RecipeURL(section, packageName string) string
+	// SignatureURL returns the URL of the detached signature of the recipe
+	SignatureURL(section, packageName string) string
+	// KeyURL returns the URL of a file in the repository's keys directory
+	KeyURL(name string) string
+	// IndexURL returns the URL of the repository index
+	IndexURL() string
+}
+
+// rawProvider implements RepoProvider for any host that serves repository
+// files from a single base URL
+type rawProvider struct {
+	name string
+	base string
+}
+
+func (p *rawProvider) Name() string {
+	return p.name
+}
+
+func (p *rawProvider) file(path string) string {
+	return p.base + "/" + path
+}
+
+// recipePath returns the repository path of a recipe
+func recipePath(section, packageName string) string {
+	if section == "" {
+		return packageName + ".box"
+	}
+	return fmt.Sprintf("%s/%s/%s.box", section, packageName, packageName)
+}
+
+func (p *rawProvider) RecipeURL(section, packageName string) string {
+	return p.file(recipePath(section, packageName))
+}
+
+func (p *rawProvider) SignatureURL(section, packageName string) string {
+	return p.file(recipePath(section, packageName) + ".sig")
+}
+
+func (p *rawProvider) KeyURL(name string) string {
+	return p.file("keys/" + name)
+}
+
+func (p *rawProvider) IndexURL() string {
+	return p.file("index.box")
+}
+
+// Provider names accepted in sources.box
+var repoProviders = []string{"github", "gitlab", "gitea", "forgejo", "http", "file"}
+
+// NewRepoProvider returns the provider called kind for the repository at
+// repoURL. An empty kind is detected from the URL and an empty branch
+// means main.
+func NewRepoProvider(kind, repoURL, branch string) (RepoProvider, error) {
+	repoURL = strings.TrimSuffix(repoURL, "/")
+	if kind == "" {
+		kind = detectProvider(repoURL)
+	}
+	if branch == "" {
+		branch = defaultBranch
+	}
+
+	switch kind {
+	case "github":
+		return &rawProvider{name: kind, base: fmt.Sprintf("%s/raw/%s", repoURL, branch)}, nil
+	case "gitlab":
+		return &rawProvider{name: kind, base: fmt.Sprintf("%s/-/raw/%s", repoURL, branch)}, nil
+	case "gitea", "forgejo":
+		return &rawProvider{name: kind, base: fmt.Sprintf("%s/raw/branch/%s", repoURL, branch)}, nil
+	case "http":
+		return &rawProvider{name: kind, base: repoURL}, nil
+	case "file":
+		dir := strings.TrimPrefix(repoURL, "file://")
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("file source must be an absolute path: %s", repoURL)
+		}
+		return &rawProvider{name: kind, base: "file://" + dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (expected one of %s)", kind, strings.Join(repoProviders, ", "))
+	}
+}
+
+// detectProvider guesses the provider from a repository URL. Unknown hosts
+// get GitHub conventions, which is what pack has always assumed.
+func detectProvider(repoURL string) string {
+	if strings.HasPrefix(repoURL, "file://") {
+		return "file"
+	}
+
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "github"
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	switch {
+	case host == "raw.githubusercontent.com":
+		// Already a raw base URL with the branch in it
+		return "http"
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return "gitlab"
+	case host == "codeberg.org" || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo."):
+		return "forgejo"
+	default:
+		return "github"
+	}
+}
+
+// provider returns the provider configured for repoURL in sources.box,
+// detecting one from the URL if the repository is not configured
+func (m *Manager) provider(repoURL string) (RepoProvider, error) {
+	if config, err := m.loadConfig(); err == nil {
+		for _, source := range config.Sources {
+			if source.URL == repoURL {
+				return NewRepoProvider(source.Provider, source.URL, source.Branch)
+			}
+		}
+	}
+	return NewRepoProvider("", repoURL, "")
+}
@@ -0,0 +1,306 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PackageInfo represents basic package information
+type PackageInfo struct {
+	Name        string
+	Description string
+	Version     string
+	License     string
+}
+
+// Peek fetches a package's recipe, from the metadata cache when it is
+// current, and returns the fields of its pkg data block without installing
+// anything
+func (m *Manager) Peek(packageName string) (map[string]string, error) {
+	// Create temporary directory for script
+	tempDir, err := os.MkdirTemp("", "pack-peek-"+packageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Download or copy script using multi-source selection
+	scriptPath := filepath.Join(tempDir, packageName+".box")
+
+	selectedSource, err := m.chooseSource(packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.fetchRecipeCached(selectedSource, packageName, scriptPath); err != nil {
+		return nil, fmt.Errorf("failed to download script: %v", err)
+	}
+
+	return parsePackageData(scriptPath)
+}
+
+// recipeData returns the pkg data block of the recipe at scriptPath
+func recipeData(scriptPath string) (*DataBlock, error) {
+	file, err := ParseBoxFile(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block := file.Block("pkg")
+	if block == nil {
+		return nil, fmt.Errorf("no pkg data block in %s", filepath.Base(scriptPath))
+	}
+	return block, nil
+}
+
+// recipeHasFn reports whether the recipe at scriptPath defines function
+// name, as in [fn name] or [fn -i name]
+func recipeHasFn(scriptPath, name string) bool {
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[fn") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		words := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+		if len(words) > 1 && words[0] == "fn" && words[len(words)-1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePackageData returns every field of the recipe's pkg data block
+func parsePackageData(scriptPath string) (map[string]string, error) {
+	file, err := ParseBoxFile(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+
+	block := file.Block("pkg")
+	if block == nil {
+		return nil, nil
+	}
+	return block.Map(), nil
+}
+
+// extractSHA256FromRecipe parses the sha256 field from a recipe's data block
+func extractSHA256FromRecipe(scriptPath string) (string, error) {
+	block, err := recipeData(scriptPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read script: %v", err)
+	}
+
+	if !block.Has("sha256") {
+		return "", fmt.Errorf("sha256 field not found in recipe data block")
+	}
+	return block.String("sha256"), nil
+}
+
+// extractRecipeURL extracts the src-url from the recipe data block
+func extractRecipeURL(scriptPath string) (string, error) {
+	block, err := recipeData(scriptPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Look for src-url field in canonical schema
+	if url := block.String("src-url"); url != "" {
+		return url, nil
+	}
+
+	// Fallback to old url field for compatibility
+	if url := block.String("url"); url != "" {
+		return url, nil
+	}
+
+	return "", fmt.Errorf("src-url not found in recipe")
+}
+
+// detectSourceTypeAndVersion extracts and validates source info from canonical recipe schema.
+// A non-empty pin replaces the recipe's src-ref.
+func detectSourceTypeAndVersion(scriptPath, pin string) (sourceType, sourceURL, sourceRef, sourceVersion string, err error) {
+	// Extract source fields from canonical recipe schema
+	sourceType, sourceURL, sourceRef, err = extractSourceFields(scriptPath)
+	if err != nil {
+		// Fall back to legacy url field
+		legacyURL, legacyErr := extractRecipeURL(scriptPath)
+		if legacyErr != nil {
+			return "", "", "", "", fmt.Errorf("failed to extract source info: %v", err)
+		}
+
+		// Infer source type from legacy URL
+		if strings.Contains(legacyURL, "github.com") || strings.Contains(legacyURL, "gitlab.com") || strings.HasSuffix(legacyURL, ".git") {
+			sourceType = "git"
+			sourceURL = legacyURL
+			sourceRef = "HEAD"
+		} else {
+			sourceType = "archive"
+			sourceURL = legacyURL
+			sourceRef = "latest"
+		}
+	}
+
+	if pin != "" {
+		sourceRef = pin
+	}
+
+	// Get actual version based on source type
+	switch sourceType {
+	case "git":
+		sourceVersion, err = resolveGitRef(sourceURL, sourceRef)
+		if err != nil {
+			sourceVersion = "unknown"
+			err = nil // Don't fail installation for version detection issues
+		}
+	case "archive", "file":
+		// For archives and files, use the ref as version
+		sourceVersion = sourceRef
+	default:
+		sourceVersion = "unknown"
+	}
+
+	return sourceType, sourceURL, sourceRef, sourceVersion, nil
+}
+
+// extractSourceFields extracts src-type, src-url, and src-ref from canonical recipe schema
+func extractSourceFields(scriptPath string) (srcType, srcURL, srcRef string, err error) {
+	block, err := recipeData(scriptPath)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	srcType = block.String("src-type")
+	srcURL = block.String("src-url")
+	srcRef = block.String("src-ref")
+
+	if srcType == "" || srcURL == "" {
+		return "", "", "", fmt.Errorf("missing required src-type or src-url fields")
+	}
+
+	if srcRef == "" {
+		srcRef = "HEAD" // Default reference
+	}
+
+	return srcType, srcURL, srcRef, nil
+}
+
+// getGitRefCommit gets the commit hash for a specific reference
+func getGitRefCommit(repoURL, ref string) (string, error) {
+	// Annotated tags list the tag object under ref and the commit it
+	// points at under ref^{}
+	cmd := exec.Command("git", "ls-remote", repoURL, ref, ref+"^{}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git commit for ref %s: %v", ref, err)
+	}
+
+	return parseLsRemote(string(output), ref)
+}
+
+// parseLsRemote picks the commit for the first ref in ls-remote output,
+// preferring its peeled ^{} line when there is one
+func parseLsRemote(output, ref string) (string, error) {
+	var commit, name string
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		if commit == "" {
+			commit, name = parts[0], parts[1]
+			continue
+		}
+		if parts[1] == name+"^{}" {
+			commit = parts[0]
+			break
+		}
+	}
+
+	if commit == "" {
+		return "", fmt.Errorf("no commit hash found for ref %s", ref)
+	}
+	return shortCommit(commit), nil
+}
+
+// getGitHeadCommit gets the HEAD commit hash from a git repository
+func getGitHeadCommit(repoURL string) (string, error) {
+	return getGitRefCommit(repoURL, "HEAD")
+}
+
+// calculateRecipeVersion calculates the hash of recipe content excluding sha256 field
+func calculateRecipeVersion(scriptPath string) (string, error) {
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return "", err
+	}
+
+	contentWithoutSHA256, err := removeCSHA256Field(content)
+	if err != nil {
+		return "", err
+	}
+
+	return calculateSHA256(contentWithoutSHA256), nil
+}
+
+// removeCSHA256Field removes the sha256 field from file content for hash calculation
+func removeCSHA256Field(content []byte) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	var filteredLines []string
+
+	for _, line := range lines {
+		// Simple approach: skip any line that contains "sha256" as the first field after whitespace
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && strings.HasPrefix(trimmed, "sha256 ") {
+			// Skip this line
+			continue
+		}
+		// Add all other lines
+		filteredLines = append(filteredLines, line)
+	}
+
+	return []byte(strings.Join(filteredLines, "\n")), nil
+}
+
+// parsePackageInfo extracts package information from a .box file
+func parsePackageInfo(filePath string) PackageInfo {
+	block, err := recipeData(filePath)
+	if err != nil {
+		return PackageInfo{}
+	}
+
+	return PackageInfo{
+		Name:        block.String("name"),
+		Description: block.String("desc"),
+		Version:     block.String("ver"),
+		License:     block.String("license"),
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// storedRecipePath is where the recipe a package was installed with is kept
+func (m *Manager) storedRecipePath(packageName string) string {
+	return m.path("locks", packageName, "recipe.box")
+}
+
+// generationRecipePath is where the recipe of one generation is kept
+func (m *Manager) generationRecipePath(packageName string, generation int) string {
+	return m.path("locks", packageName, "gen-"+strconv.Itoa(generation)+".box")
+}
+
+// fetchedRecipePath is where review keeps the recipe at scriptPath as it
+// was fetched, before it could be edited
+func fetchedRecipePath(scriptPath string) string {
+	return filepath.Join(filepath.Dir(scriptPath), "fetched-"+filepath.Base(scriptPath))
+}
+
+// keepFetchedRecipe copies the recipe at scriptPath and its signature
+// aside before review
+func keepFetchedRecipe(scriptPath string) error {
+	return copyRecipe(scriptPath, fetchedRecipePath(scriptPath))
+}
+
+// recipeAsFetched returns the unedited copy of the recipe at scriptPath if
+// review kept one, and scriptPath otherwise
+func recipeAsFetched(scriptPath string) string {
+	if fetched := fetchedRecipePath(scriptPath); isFile(fetched) {
+		return fetched
+	}
+	return scriptPath
+}
+
+// copyRecipe copies the recipe at src to dst along with its signature,
+// dropping any signature dst had
+func copyRecipe(src, dst string) error {
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	os.Remove(dst + ".sig")
+	if _, err := os.Stat(src + ".sig"); err == nil {
+		return copyFile(src+".sig", dst+".sig")
+	}
+	return nil
+}
+
+// storeRecipe keeps the recipe that was just run, as it was fetched, and
+// the signature it was verified with next to the package's lock. Edits
+// made during review ran once but are not kept next to the signature.
+func (m *Manager) storeRecipe(packageName, scriptPath string) error {
+	storedPath := m.storedRecipePath(packageName)
+	if err := os.MkdirAll(filepath.Dir(storedPath), publicDirPerms); err != nil {
+		return err
+	}
+
+	return copyRecipe(recipeAsFetched(scriptPath), storedPath)
+}
+
+// restoreGenerationRecipe makes the recipe lock's generation was installed
+// with the stored one again. Generations saved before recipes were kept
+// per generation have none, and a recipe that doesn't match is dropped.
+func (m *Manager) restoreGenerationRecipe(lock *Lockfile) error {
+	genPath := m.generationRecipePath(lock.Package, lock.Generation)
+	if !isFile(genPath) {
+		os.Remove(m.storedRecipePath(lock.Package))
+		os.Remove(m.storedRecipePath(lock.Package) + ".sig")
+		return nil
+	}
+	return copyRecipe(genPath, m.storedRecipePath(lock.Package))
+}
+
+// storedRecipe copies the stored recipe of lock's package and its
+// signature to scriptPath after checking it still hashes to what the lock
+// recorded
+func (m *Manager) storedRecipe(lock *Lockfile, scriptPath string) error {
+	storedPath := m.storedRecipePath(lock.Package)
+	if _, err := os.Stat(storedPath); err != nil {
+		return fmt.Errorf("no stored recipe for %s", lock.Package)
+	}
+
+	hash, err := calculateRecipeVersion(storedPath)
+	if err != nil {
+		return err
+	}
+	if hash != lock.RecipeSHA256 {
+		return fmt.Errorf("stored recipe for %s does not match its lock (expected %s, got %s)", lock.Package, lock.RecipeSHA256, hash)
+	}
+
+	return copyRecipe(storedPath, scriptPath)
+}
+
+// showRecipeDiff prints how the recipe at newPath differs from the stored
+// one lock's package was installed with
+func (m *Manager) showRecipeDiff(lock *Lockfile, newPath string) {
+	oldPath := filepath.Join(filepath.Dir(newPath), "installed-"+filepath.Base(newPath))
+	if err := m.storedRecipe(lock, oldPath); err != nil {
+		return
+	}
+	defer os.Remove(oldPath)
+	defer os.Remove(oldPath + ".sig")
+
+	if newHash, err := calculateRecipeVersion(newPath); err != nil || newHash == lock.RecipeSHA256 {
+		return
+	}
+
+	fmt.Fprintln(m.out, "recipe changes since install:")
+	cmd := exec.Command("diff", "-u", "--label", "installed", "--label", "new", oldPath, newPath)
+	cmd.Stdout = m.out
+	cmd.Stderr = m.out
+	// diff exits 1 when the files differ, which is the point
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			fmt.Fprintf(m.out, "warning: could not diff recipes: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GenerateKeyPair generates a base64 encoded Ed25519 key pair for recipe signing
+func GenerateKeyPair() (publicB64, privateB64 string, err error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(publicKey), base64.StdEncoding.EncodeToString(privateKey), nil
+}
+
+// ParsePrivateKey decodes a base64 Ed25519 private key
+func ParsePrivateKey(privateKeyB64 string) (ed25519.PrivateKey, error) {
+	privateKeyBytes, err := base64.StdEncoding.DecodeString(privateKeyB64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %v", err)
+	}
+
+	if len(privateKeyBytes) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key size: expected %d, got %d", ed25519.PrivateKeySize, len(privateKeyBytes))
+	}
+
+	return ed25519.PrivateKey(privateKeyBytes), nil
+}
+
+// SignFile writes a detached signature next to filePath and returns its path
+func SignFile(privateKey ed25519.PrivateKey, filePath string) (string, error) {
+	// Read file content
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+
+	// Sign content
+	signature := ed25519.Sign(privateKey, content)
+
+	// Base64 encode signature
+	signatureB64 := base64.StdEncoding.EncodeToString(signature)
+
+	// Write signature file
+	sigPath := filePath + ".sig"
+	if err := os.WriteFile(sigPath, []byte(signatureB64), publicFilePerms); err != nil {
+		return "", fmt.Errorf("failed to write signature %s: %v", sigPath, err)
+	}
+
+	return sigPath, nil
+}
+
+// SignPath signs target, or every .box file below it when it is a
+// directory, and returns the files that were signed
+func SignPath(privateKey ed25519.PrivateKey, target string) ([]string, error) {
+	// Check if target is directory or file
+	stat, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access target: %v", err)
+	}
+
+	if !stat.IsDir() {
+		// Sign single file
+		if _, err := SignFile(privateKey, target); err != nil {
+			return nil, err
+		}
+		return []string{target}, nil
+	}
+
+	// Sign all .box files in directory
+	var signed []string
+	err = filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(path, ".box") {
+			if _, err := SignFile(privateKey, path); err != nil {
+				return fmt.Errorf("failed to sign %s: %v", path, err)
+			}
+			signed = append(signed, path)
+		}
+
+		return nil
+	})
+
+	return signed, err
+}
+
+// IsRepo reports whether dir looks like a pack repository
+func IsRepo(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "keys"))
+	return err == nil
+}
+
+// CreateRepo lays out a new pack repository in dir. The returned bool
+// reports whether a git repository could be initialised as well.
+func CreateRepo(dir string) (bool, error) {
+	// Check if we're already in a pack repo
+	if IsRepo(dir) {
+		return false, fmt.Errorf("keys/ directory already exists - already a pack repository?")
+	}
+
+	// Create directory structure
+	dirs := append([]string{"keys"}, repoSections...)
+	for _, d := range dirs {
+		if err := os.MkdirAll(filepath.Join(dir, d), publicDirPerms); err != nil {
+			return false, fmt.Errorf("error creating directory %s: %v", d, err)
+		}
+	}
+
+	// Create README.md
+	readmeContent := `# Pack Repository
+
+This is a pack repository containing packages that can be installed with the pack package manager.
+
+## Repository Structure
+
+- ` + "`lang/`" + ` - Programming languages and development tools
+- ` + "`utils/`" + ` - System utilities and tools
+- ` + "`toys/`" + ` - Fun and recreational packages
+- ` + "`misc/`" + ` - Miscellaneous packages
+- ` + "`keys/`" + ` - Signing keys for package verification
+
+Each package is stored in ` + "`section/package-name/package-name.box`" + ` with a corresponding ` + "`.box.sig`" + ` signature file.
+
+## Adding Packages
+
+1. Create a directory: ` + "`section/package-name/`" + `
+2. Add your recipe: ` + "`package-name.box`" + `
+3. Sign the package: ` + "`pack repo sign`" + `
+4. Update the index: ` + "`pack repo index`" + `
+
+## Repository Management
+
+- ` + "`pack repo create`" + ` - Create new repository
+- ` + "`pack repo keygen`" + ` - Generate signing keys
+- ` + "`pack repo sign`" + ` - Sign all packages
+- ` + "`pack repo index`" + ` - Write the signed package index (` + "`index.box`" + `)
+
+## Usage
+
+Add this repository to pack:
+` + "```bash" + `
+pack add-source https://github.com/username/repo-name
+` + "```" + `
+
+Install packages:
+` + "```bash" + `
+pack open package-name
+` + "```" + `
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte(readmeContent), publicFilePerms); err != nil {
+		return false, fmt.Errorf("error creating README.md: %v", err)
+	}
+
+	// Create .gitignore
+	gitignoreContent := `# Pack repository gitignore
+*.tmp
+.pack/
+*.bak
+*~
+.DS_Store
+`
+
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(gitignoreContent), publicFilePerms); err != nil {
+		return false, fmt.Errorf("error creating .gitignore: %v", err)
+	}
+
+	// Initialize git repository
+	gitInit := exec.Command("git", "init")
+	gitInit.Dir = dir
+	return gitInit.Run() == nil, nil
+}
+
+// RepoKeygen writes a fresh public key to dir/keys/pack.box and returns the
+// matching private key. A legacy keys/pack.pub is removed.
+func RepoKeygen(dir string) (string, error) {
+	// Check if we're in a pack repository
+	if !IsRepo(dir) {
+		return "", fmt.Errorf("not in a pack repository (no keys/ directory found)")
+	}
+
+	publicB64, privateB64, err := GenerateKeyPair()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate keys: %v", err)
+	}
+
+	// Create keys/pack.box with proper structure
+	now := time.Now().Unix()
+	expires := time.Now().Add(2 * 365 * 24 * time.Hour).Unix() // 2 years
+
+	keyContent := fmt.Sprintf(`[data -c keyinfo]
+  version     2
+  issued_at   %d
+  expires_at  %d
+  algorithm   ed25519
+end
+
+[data -c pubkey]
+  key %s
+end
+`, now, expires, publicB64)
+
+	if err := os.WriteFile(filepath.Join(dir, "keys", "pack.box"), []byte(keyContent), publicFilePerms); err != nil {
+		return "", fmt.Errorf("failed to write keys/pack.box: %v", err)
+	}
+
+	// Remove legacy pack.pub if it exists
+	os.Remove(filepath.Join(dir, "keys", "pack.pub"))
+
+	return privateB64, nil
+}
+
+// RepoRecipes lists the .box recipes in the repository root and in every
+// section of the repository at dir
+func RepoRecipes(dir string) []string {
+	var boxFiles []string
+
+	// Check repository root, skipping the index
+	if files, err := filepath.Glob(filepath.Join(dir, "*.box")); err == nil {
+		for _, file := range files {
+			if filepath.Base(file) != indexFile {
+				boxFiles = append(boxFiles, file)
+			}
+		}
+	}
+
+	// Check all sections
+	for _, section := range repoSections {
+		pattern := filepath.Join(dir, section, "*", "*.box")
+		if files, err := filepath.Glob(pattern); err == nil {
+			boxFiles = append(boxFiles, files...)
+		}
+	}
+
+	return boxFiles
+}
@@ -0,0 +1,244 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PackageSource represents a source where a package is available
+type PackageSource struct {
+	Name string
+	URL  string
+	Type string // "remote" or "local"
+}
+
+// FindSources finds all sources where a package is available
+func (m *Manager) FindSources(packageName string) ([]PackageSource, error) {
+	var availableSources []PackageSource
+
+	// Check local source if it exists
+	localRepoPath := m.localRepoPath()
+
+	// First try the old flat structure for backward compatibility
+	localPackagePath := filepath.Join(localRepoPath, packageName+".box")
+	if _, err := os.Stat(localPackagePath); err == nil {
+		availableSources = append(availableSources, PackageSource{
+			Name: "local",
+			URL:  localPackagePath,
+			Type: "local",
+		})
+	} else {
+		// If not found in flat structure, search through the new section-based structure
+		for _, section := range repoSections {
+			localPackagePath := filepath.Join(localRepoPath, section, packageName, packageName+".box")
+			if _, err := os.Stat(localPackagePath); err == nil {
+				availableSources = append(availableSources, PackageSource{
+					Name: "local",
+					URL:  localPackagePath,
+					Type: "local",
+				})
+				break // Found in this section, no need to check others
+			}
+		}
+	}
+
+	// Check configured remote sources
+	config, err := m.loadConfig()
+	if err == nil {
+		for _, source := range config.Sources {
+			provider, err := NewRepoProvider(source.Provider, source.URL, source.Branch)
+			if err != nil {
+				continue
+			}
+
+			// The index says where the recipe is without probing
+			if idx, err := m.sourceIndex(source); err == nil {
+				if entry, ok := idx.Lookup(packageName); ok {
+					availableSources = append(availableSources, PackageSource{
+						Name: source.URL,
+						URL:  provider.RecipeURL(entry.Section, packageName),
+						Type: "remote",
+					})
+				}
+				continue
+			}
+
+			// Sources without an index are probed layout by layout
+			if m.cache == CacheOffline {
+				continue
+			}
+			if scriptURL, ok := m.findRemoteRecipe(provider, packageName); ok {
+				availableSources = append(availableSources, PackageSource{
+					Name: source.URL,
+					URL:  scriptURL,
+					Type: "remote",
+				})
+			}
+		}
+	}
+
+	return availableSources, nil
+}
+
+// findRemoteRecipe returns the URL of packageName's recipe in a remote
+// repository, checking the flat layout before each section
+func (m *Manager) findRemoteRecipe(provider RepoProvider, packageName string) (string, bool) {
+	for _, section := range append([]string{""}, repoSections...) {
+		scriptURL := provider.RecipeURL(section, packageName)
+		// Test if package exists at this source (lightweight check)
+		if m.testPackageExists(scriptURL) {
+			return scriptURL, true
+		}
+	}
+	return "", false
+}
+
+// fetchRecipe copies packageName's recipe from repo, the source it was
+// installed from, to scriptPath
+func (m *Manager) fetchRecipe(repo, packageName, scriptPath string) (PackageSource, error) {
+	if repo == "local" {
+		// Use local source - try both old and new formats
+		localRepoPath := m.localRepoPath()
+
+		// First try old flat structure
+		localPackagePath := filepath.Join(localRepoPath, packageName+".box")
+		if err := copyFile(localPackagePath, scriptPath); err != nil {
+			// If old structure fails, try new section-based structure
+			found := false
+			for _, section := range repoSections {
+				localPackagePath = filepath.Join(localRepoPath, section, packageName, packageName+".box")
+				if err := copyFile(localPackagePath, scriptPath); err == nil {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return PackageSource{}, fmt.Errorf("package not found in local repository: %v", err)
+			}
+		}
+
+		return PackageSource{Name: "local", URL: localPackagePath, Type: "local"}, nil
+	}
+
+	provider, err := m.provider(repo)
+	if err != nil {
+		return PackageSource{}, err
+	}
+
+	scriptURL, ok := m.findRemoteRecipe(provider, packageName)
+	if !ok {
+		return PackageSource{}, fmt.Errorf("%s not found in %s", packageName, repo)
+	}
+
+	if err := m.downloadFile(scriptURL, scriptPath); err != nil {
+		return PackageSource{}, fmt.Errorf("failed to download from original source: %v", err)
+	}
+
+	return PackageSource{Name: repo, URL: scriptURL, Type: "remote"}, nil
+}
+
+// selectSource lets the prompter choose from multiple sources
+func (m *Manager) selectSource(packageName string, sources []PackageSource) (PackageSource, error) {
+	var options []string
+	for _, source := range sources {
+		if source.Type == "local" {
+			options = append(options, "Local repository")
+		} else {
+			options = append(options, source.Name)
+		}
+	}
+
+	choice, err := m.prompt.Choose(fmt.Sprintf("Package '%s' is available from multiple sources:", packageName), options)
+	if err != nil {
+		return PackageSource{}, err
+	}
+	if choice < 0 || choice >= len(sources) {
+		return PackageSource{}, fmt.Errorf("invalid selection")
+	}
+
+	return sources[choice], nil
+}
+
+// downloadFrom copies the recipe at selectedSource to scriptPath
+func (m *Manager) downloadFrom(selectedSource PackageSource, scriptPath string) error {
+	if selectedSource.Type == "local" {
+		return copyFile(selectedSource.URL, scriptPath)
+	}
+	return m.downloadFile(selectedSource.URL, scriptPath)
+}
+
+// chooseSource finds the sources offering packageName and picks one,
+// asking the prompter when there is more than one
+func (m *Manager) chooseSource(packageName string) (PackageSource, error) {
+	// Find all available sources
+	sources, err := m.FindSources(packageName)
+	if err != nil {
+		return PackageSource{}, err
+	}
+
+	if len(sources) == 0 {
+		return PackageSource{}, fmt.Errorf("package '%s' not found in any configured source", packageName)
+	}
+
+	// A packfile says where the package comes from
+	if repo, ok := m.sourceFor[packageName]; ok {
+		for _, source := range sources {
+			if source.Name == repo {
+				return source, nil
+			}
+		}
+		return PackageSource{}, fmt.Errorf("package '%s' not found in %s", packageName, repo)
+	}
+
+	var selectedSource PackageSource
+
+	if len(sources) == 1 {
+		// Only one source available, use it
+		selectedSource = sources[0]
+		if selectedSource.Type == "local" {
+			fmt.Fprintf(m.out, "Found package in local repository\n")
+		} else {
+			fmt.Fprintf(m.out, "Found package at %s\n", selectedSource.Name)
+		}
+	} else {
+		// Multiple sources available, let user choose
+		selectedSource, err = m.selectSource(packageName, sources)
+		if err != nil {
+			return PackageSource{}, err
+		}
+	}
+
+	return selectedSource, nil
+}
+
+// constructRecipeURL constructs the recipe URL based on selected source
+func constructRecipeURL(selectedSource PackageSource) string {
+	if selectedSource.Type == "local" {
+		return "local"
+	}
+
+	// Remote sources already point at the recipe they were found at
+	return selectedSource.URL
+}
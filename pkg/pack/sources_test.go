@@ -0,0 +1,80 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestFindSourcesRemembersMissingIndex looks a package up twice in a
+// source without an index and expects the index to be asked for once
+func TestFindSourcesRemembersMissingIndex(t *testing.T) {
+	var indexRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.box":
+			indexRequests.Add(1)
+			http.NotFound(w, r)
+		case "/edith.box":
+			w.Write([]byte("[data -c pkg]\n  name edith\nend\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	m := testManager(t)
+	if err := os.MkdirAll(m.configPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := "[data -c sources]\n  repo " + server.URL + "\n  provider http\nend\n"
+	if err := os.WriteFile(m.sourcesFile(), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		sources, err := m.FindSources("edith")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sources) != 1 || !strings.HasSuffix(sources[0].URL, "/edith.box") {
+			t.Fatalf("lookup %d found %+v", i+1, sources)
+		}
+	}
+	if n := indexRequests.Load(); n != 1 {
+		t.Errorf("index requested %d times, want 1", n)
+	}
+
+	// Refreshing asks again
+	m.cache = CacheRefresh
+	if _, err := m.FindSources("edith"); err != nil {
+		t.Fatal(err)
+	}
+	if n := indexRequests.Load(); n != 2 {
+		t.Errorf("index requested %d times after a refresh, want 2", n)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Uninstall runs the uninstall function of the recipe packageName was
+// installed from and removes its lock file
+func (m *Manager) Uninstall(packageName string) (err error) {
+	defer m.journal("uninstall", packageName)(&err)
+
+	// Read lock file to get original recipe URL
+	lock, err := m.Lock(packageName)
+	if err != nil {
+		return err
+	}
+
+	// Warn when this is the last thing providing what others depend on
+	orphaned := m.orphanedDependents(lock)
+	names := make([]string, 0, len(orphaned))
+	for name := range orphaned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(m.out, "⚠️  warning: %s is the only provider of %s, which %s depend(s) on\n", packageName, name, strings.Join(orphaned[name], ", "))
+	}
+
+	// Create temporary directory for uninstall in .pack/tmp
+	tempDir, err := os.MkdirTemp(m.path("tmp"), "pack-uninstall-"+packageName)
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Recipes with an uninstall function get to run it first. The copy
+	// stored at install time is used over downloading it again.
+	scriptPath := filepath.Join(tempDir, packageName+".box")
+	recipeErr := m.storedRecipe(lock, scriptPath)
+	if recipeErr != nil {
+		fmt.Fprintf(m.out, "%v, downloading it again\n", recipeErr)
+		_, recipeErr = m.fetchRecipe(lock.Repo, packageName, scriptPath)
+	}
+
+	if recipeErr != nil {
+		fmt.Fprintf(m.out, "warning: failed to download recipe: %v\n", recipeErr)
+	} else if recipeHasFn(scriptPath, "uninstall") {
+		if err := m.runLogged(packageName, LogUninstall, tempDir, nil, scriptPath, "uninstall"); err != nil {
+			fmt.Fprintf(m.out, "warning: recipe uninstall failed: %v\n", err)
+		}
+	} else {
+		fmt.Fprintln(m.out, "recipe has no uninstall function, removing recorded files...")
+	}
+
+	// Whatever is still there from the install is removed by pack
+	if errs := m.removeInstalledFiles(lock); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(m.out, "✗ %v\n", err)
+		}
+		return fmt.Errorf("could not remove %d path(s), keeping the lock so close can be retried", len(errs))
+	}
+
+	// Generations and their links belong to pack, so pack removes them
+	if lock.Generation > 0 {
+		m.removeGenerations(lock)
+	}
+	os.RemoveAll(m.path("locks", packageName))
+
+	// Remove lock file after successful uninstall
+	fmt.Fprintln(m.out, "removing lockfile...")
+	if err := os.Remove(m.getLockFilePath(packageName)); err != nil {
+		fmt.Fprintf(m.out, "warning: failed to remove lockfile: %v\n", err)
+	} else {
+		fmt.Fprintln(m.out, "✓ lockfile removed")
+	}
+
+	return nil
+}
+
+// removeInstalledFiles removes what the manifest says lock's package put on
+// disk, or its shelf directory and symlink when there is no manifest.
+// Configuration is kept and files changed since install are left alone;
+// the returned errors are the paths that could not be removed.
+func (m *Manager) removeInstalledFiles(lock *Lockfile) []error {
+	mf, err := m.Files(lock.Package)
+	if err != nil {
+		return m.removeLegacyInstall(lock)
+	}
+
+	var errs []error
+	keptConfig := false
+
+	// Deepest paths first so directories are empty by the time we get there
+	entries := append([]ManifestEntry{}, mf.Entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path > entries[j].Path })
+
+	for _, entry := range entries {
+		if lock.ConfigDir != "" && (entry.Path == lock.ConfigDir || strings.HasPrefix(entry.Path, lock.ConfigDir+string(filepath.Separator))) {
+			keptConfig = true
+			continue
+		}
+
+		if entry.Modified {
+			fmt.Fprintf(m.out, "leaving %s, it was there before %s was installed\n", entry.Path, lock.Package)
+			continue
+		}
+
+		info, err := os.Lstat(entry.Path)
+		if err != nil {
+			continue // already gone
+		}
+
+		switch entry.Kind {
+		case EntryLink:
+			if target, err := os.Readlink(entry.Path); err != nil || target != entry.Target {
+				fmt.Fprintf(m.out, "leaving %s, it no longer points where %s put it\n", entry.Path, lock.Package)
+				continue
+			}
+		case EntryFile:
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			if hash, err := hashFile(entry.Path); err != nil || hash != entry.SHA256 {
+				fmt.Fprintf(m.out, "leaving %s, it changed since it was installed\n", entry.Path)
+				continue
+			}
+		case EntryDir:
+			if !info.IsDir() {
+				continue
+			}
+			if !isEmptyDir(entry.Path) {
+				fmt.Fprintf(m.out, "leaving %s, it is not empty\n", entry.Path)
+				continue
+			}
+		}
+
+		if err := os.Remove(entry.Path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %v", entry.Path, err))
+		}
+	}
+
+	if keptConfig {
+		fmt.Fprintf(m.out, "keeping configuration in %s\n", lock.ConfigDir)
+	}
+	return errs
+}
+
+// removeLegacyInstall cleans up a package installed before manifests were
+// recorded, going by the paths in its lock
+func (m *Manager) removeLegacyInstall(lock *Lockfile) []error {
+	var errs []error
+
+	shelf := m.packageShelf(lock.Package)
+	if lock.SymlinkPath != "" {
+		if target, err := os.Readlink(lock.SymlinkPath); err == nil && strings.HasPrefix(target, shelf+string(filepath.Separator)) {
+			if err := os.Remove(lock.SymlinkPath); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove %s: %v", lock.SymlinkPath, err))
+			}
+		}
+	}
+
+	if _, err := os.Stat(shelf); err == nil {
+		if err := os.RemoveAll(shelf); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %v", shelf, err))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,607 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PackageUpdate represents an available update
+type PackageUpdate struct {
+	PackageName    string
+	CurrentVersion string
+	NewVersion     string
+	UpdateType     string // "source updated", "recipe updated", "both updated"
+	// Pin is the ref the package is pinned to, if any
+	Pin string
+	// Held packages are only updated when asked for
+	Held bool
+}
+
+// UpdateOptions controls UpdateAll
+type UpdateOptions struct {
+	// IncludeHeld updates held packages along with the rest
+	IncludeHeld bool
+}
+
+// UpdateResult describes the outcome of UpdateAll
+type UpdateResult struct {
+	Available []PackageUpdate
+	// Held lists available updates that were left alone
+	Held    []PackageUpdate
+	Updated []string
+	Failed  []*PackageError
+}
+
+// UpdateAll updates pack and boxlang first, refreshes repository keys and
+// then updates every outdated package once the prompter confirms. Held
+// packages are skipped unless opts.IncludeHeld is set.
+func (m *Manager) UpdateAll(opts UpdateOptions) (result *UpdateResult, err error) {
+	defer m.journal("update")(&err)
+
+	// Always update pack and boxlang first during pack update
+	m.updateCorePackagesFirst(opts.IncludeHeld)
+
+	// Refresh public keys from all configured sources
+	fmt.Fprintln(m.out, "Refreshing public keys...")
+	for _, err := range m.RefreshKeys() {
+		fmt.Fprintf(m.out, "Warning: %v\n", err)
+	}
+
+	updates, err := m.CheckUpdates()
+	if err != nil {
+		return nil, fmt.Errorf("error scanning for updates: %v", err)
+	}
+
+	result = &UpdateResult{}
+	result.split(updates, opts.IncludeHeld)
+	availableUpdates := result.Available
+
+	if len(result.Held) > 0 {
+		fmt.Fprintf(m.out, "\nheld (use --include-held to update):\n")
+		for _, update := range result.Held {
+			fmt.Fprintf(m.out, "- %s: %s → %s (held)\n", update.PackageName, update.CurrentVersion, update.NewVersion)
+		}
+	}
+
+	if len(availableUpdates) == 0 {
+		return result, nil
+	}
+
+	// Display available updates
+	fmt.Fprintf(m.out, "\navailable updates:\n")
+	for _, update := range availableUpdates {
+		notes := ""
+		if update.Pin != "" {
+			notes += ", pinned to " + update.Pin
+		}
+		if update.Held {
+			notes += ", held"
+		}
+		fmt.Fprintf(m.out, "- %s: %s → %s (%s%s)\n",
+			update.PackageName,
+			update.CurrentVersion,
+			update.NewVersion,
+			update.UpdateType,
+			notes)
+	}
+
+	// Ask for confirmation
+	ok, err := m.prompt.Confirm(fmt.Sprintf("\nupdate %d package(s)?", len(availableUpdates)))
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, ErrCancelled
+	}
+
+	// Perform updates
+	fmt.Fprintln(m.out, "\nupdating packages...")
+	total := len(availableUpdates)
+
+	for i, update := range availableUpdates {
+		m.showProgress(i, total, fmt.Sprintf("updating %s...", update.PackageName))
+		if err := m.Update(update.PackageName); err != nil {
+			fmt.Fprintf(m.out, "\nerror updating %s: %v\n", update.PackageName, err)
+			result.Failed = append(result.Failed, &PackageError{Package: update.PackageName, Err: err})
+		} else {
+			m.showProgress(i+1, total, fmt.Sprintf("updated %s", update.PackageName))
+			result.Updated = append(result.Updated, update.PackageName)
+		}
+	}
+
+	return result, nil
+}
+
+// split sorts updates into Available and, unless includeHeld, Held
+func (r *UpdateResult) split(updates []PackageUpdate, includeHeld bool) {
+	for _, update := range updates {
+		if update.Held && !includeHeld {
+			r.Held = append(r.Held, update)
+		} else {
+			r.Available = append(r.Available, update)
+		}
+	}
+}
+
+// Outdated checks every installed package for updates without changing
+// anything: pack and boxlang aren't updated first and keys aren't
+// refreshed. Packages that could not be checked are in Failed.
+func (m *Manager) Outdated(opts UpdateOptions) (*UpdateResult, error) {
+	updates, failed, err := m.scanForUpdates()
+	if err != nil {
+		return nil, fmt.Errorf("error scanning for updates: %v", err)
+	}
+
+	result := &UpdateResult{Failed: failed}
+	result.split(updates, opts.IncludeHeld)
+	return result, nil
+}
+
+// CheckUpdates checks all installed packages for available updates using
+// concurrent workers, warning about any it could not check
+func (m *Manager) CheckUpdates() ([]PackageUpdate, error) {
+	updates, failed, err := m.scanForUpdates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkgErr := range failed {
+		fmt.Fprintf(m.out, "warning: failed to check updates for %s: %v\n", pkgErr.Package, pkgErr.Err)
+	}
+
+	return updates, nil
+}
+
+// scanForUpdates checks every lock for an update, sorted by package name
+func (m *Manager) scanForUpdates() ([]PackageUpdate, []*PackageError, error) {
+	locksDir := m.path("locks")
+	if _, err := os.Stat(locksDir); os.IsNotExist(err) {
+		return nil, nil, nil // No packages installed
+	}
+
+	files, err := os.ReadDir(locksDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Filter for lock files only
+	var lockFiles []string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".lock") {
+			lockFiles = append(lockFiles, file.Name())
+		}
+	}
+
+	if len(lockFiles) == 0 {
+		return nil, nil, nil
+	}
+
+	// Use concurrent workers for faster checking
+	updates, failed := m.checkUpdatesParallel(lockFiles, locksDir)
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].PackageName < updates[j].PackageName
+	})
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Package < failed[j].Package
+	})
+	return updates, failed, nil
+}
+
+// PackageCheckJob represents a job for checking package updates
+type PackageCheckJob struct {
+	FileName    string
+	PackageName string
+	LocksDir    string
+}
+
+// PackageCheckResult represents the result of checking a package for updates
+type PackageCheckResult struct {
+	Update    PackageUpdate
+	HasUpdate bool
+	Error     error
+	Package   string
+}
+
+// checkUpdatesParallel checks packages for updates using multiple goroutines
+func (m *Manager) checkUpdatesParallel(lockFiles []string, locksDir string) ([]PackageUpdate, []*PackageError) {
+	total := len(lockFiles)
+	jobs := make(chan PackageCheckJob, total)
+	results := make(chan PackageCheckResult, total)
+
+	// Start worker goroutines
+	for w := 0; w < updateCheckWorkers && w < total; w++ {
+		go m.updateCheckWorker(jobs, results)
+	}
+
+	// Send jobs to workers
+	go func() {
+		defer close(jobs)
+		for _, fileName := range lockFiles {
+			packageName := strings.TrimSuffix(fileName, ".lock")
+			jobs <- PackageCheckJob{
+				FileName:    fileName,
+				PackageName: packageName,
+				LocksDir:    locksDir,
+			}
+		}
+	}()
+
+	// Collect results and show progress
+	var updates []PackageUpdate
+	var failed []*PackageError
+	completed := 0
+
+	for completed < total {
+		result := <-results
+		completed++
+		m.showProgress(completed, total, "")
+
+		if result.Error != nil {
+			failed = append(failed, &PackageError{Package: result.Package, Err: result.Error})
+			continue
+		}
+
+		if result.HasUpdate {
+			updates = append(updates, result.Update)
+		}
+	}
+
+	return updates, failed
+}
+
+// updateCheckWorker is a worker that processes package update check jobs
+func (m *Manager) updateCheckWorker(jobs <-chan PackageCheckJob, results chan<- PackageCheckResult) {
+	for job := range jobs {
+		result := PackageCheckResult{
+			Package: job.PackageName,
+		}
+
+		// Parse lock file
+		lock, err := ReadLockfile(filepath.Join(job.LocksDir, job.FileName))
+		if err != nil {
+			result.Error = err
+			results <- result
+			continue
+		}
+
+		// Check for updates
+		update, hasUpdate, err := m.checkPackageForUpdate(job.PackageName, lock)
+		result.Update = update
+		result.HasUpdate = hasUpdate
+		result.Error = err
+
+		results <- result
+	}
+}
+
+// checkPackageForUpdate checks if a package has available updates
+func (m *Manager) checkPackageForUpdate(packageName string, lock *Lockfile) (PackageUpdate, bool, error) {
+	update := PackageUpdate{
+		PackageName:    packageName,
+		CurrentVersion: lock.SrcRefUsed,
+	}
+	if lock.Pinned {
+		update.Pin = lock.SrcRef
+	}
+	update.Held = lock.Held
+
+	var updateReasons []string
+	var checkErr error
+
+	// Check recipe for updates
+	recipeURL := lock.RecipeURL
+	if recipeURL != "" && recipeURL != "local" {
+		// Download current recipe and compare hash
+		currentRecipeVersion, err := m.getCurrentRecipeVersion(packageName, lock)
+		if err != nil {
+			checkErr = fmt.Errorf("could not fetch recipe: %v", err)
+		} else if currentRecipeVersion != lock.RecipeSHA256 {
+			updateReasons = append(updateReasons, "recipe updated")
+		}
+	}
+
+	// Check source for updates
+	sourceURL := lock.SrcURL
+	sourceType := lock.SrcType
+	if sourceURL != "" && sourceURL != "unknown" && sourceType != "" {
+		// Pinned packages follow their pin rather than HEAD
+		var newSourceVersion string
+		var err error
+		if lock.Pinned {
+			newSourceVersion, err = resolveGitRef(sourceURL, lock.SrcRef)
+		} else {
+			newSourceVersion, err = getCurrentSourceVersion(sourceURL, sourceType)
+		}
+		if err != nil {
+			checkErr = fmt.Errorf("could not check source: %v", err)
+		} else if newSourceVersion != lock.SrcRefUsed {
+			updateReasons = append(updateReasons, "source updated")
+			update.NewVersion = newSourceVersion
+		}
+	}
+
+	// An update found either way is still worth reporting
+	if len(updateReasons) == 0 {
+		return update, false, checkErr
+	}
+
+	update.UpdateType = strings.Join(updateReasons, ", ")
+	if update.NewVersion == "" {
+		update.NewVersion = "latest"
+	}
+
+	return update, true, nil
+}
+
+// getCurrentRecipeVersion downloads the recipe a lock was installed from and calculates its version hash
+func (m *Manager) getCurrentRecipeVersion(packageName string, lock *Lockfile) (string, error) {
+	// Create temp file to download recipe
+	tempFile, err := os.CreateTemp("", "recipe-*.box")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	// Download recipe without ETag caching for update checks
+	if err := m.downloadFileWithCache(lock.RecipeURL, tempFile.Name(), false); err != nil {
+		// The recipe may have moved within its repository, look it up again
+		if lock.Repo == "" || lock.Repo == "local" {
+			return "", err
+		}
+		if _, err := m.fetchRecipe(lock.Repo, packageName, tempFile.Name()); err != nil {
+			return "", err
+		}
+	}
+
+	// Calculate version hash
+	return calculateRecipeVersion(tempFile.Name())
+}
+
+// updateStep fetches the recipe packageName was installed from into
+// scriptPath and returns the step that reinstalls it, at its pin if it has
+// one. When the source is gone the stored recipe is used and stored is set.
+func (m *Manager) updateStep(packageName string, lock *Lockfile, scriptPath string) (step *PlanStep, stored bool, err error) {
+	originalRepo := lock.Repo
+
+	selectedSource, err := m.fetchRecipe(originalRepo, packageName, scriptPath)
+	if err != nil {
+		// Without the source the installed recipe can still be rerun
+		if storedErr := m.storedRecipe(lock, scriptPath); storedErr != nil {
+			return nil, false, err
+		}
+		fmt.Fprintf(m.out, "warning: %v\n", err)
+		stored = true
+		selectedSource = PackageSource{Name: originalRepo, URL: lock.RecipeURL, Type: "remote"}
+		if originalRepo == "local" {
+			selectedSource.Type = "local"
+		}
+	}
+
+	step = &PlanStep{
+		Package:   packageName,
+		Source:    selectedSource,
+		Requested: lock.InstallReason != ReasonDependency,
+	}
+	if lock.Pinned {
+		step.Ref = lock.SrcRef
+		if err := checkPin(packageName, step.Ref, scriptPath); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return step, stored, nil
+}
+
+// getCurrentSourceVersion gets the current version of a source
+func getCurrentSourceVersion(sourceURL, sourceType string) (string, error) {
+	switch sourceType {
+	case "git":
+		return getGitHeadCommit(sourceURL)
+	case "download":
+		// For downloads, we'd need to download and hash, but that's expensive
+		// For now, just indicate that we can't easily check
+		return "latest", nil
+	default:
+		return "", fmt.Errorf("unknown source type: %s", sourceType)
+	}
+}
+
+// Update reinstalls packageName from the same source it was originally
+// installed from, keeping it at its pinned ref if it has one
+func (m *Manager) Update(packageName string) (err error) {
+	defer m.journal("update", packageName)(&err)
+
+	// Read the lock file to get original source info
+	lock, err := m.Lock(packageName)
+	if err != nil {
+		return fmt.Errorf("failed to parse lock file: %v", err)
+	}
+
+	originalRepo := lock.Repo
+	if originalRepo == "" {
+		return fmt.Errorf("no original repo found in lock file")
+	}
+
+	// Create temporary directory for script in .pack/tmp
+	tempDir, err := os.MkdirTemp(m.path("tmp"), "pack-update-"+packageName)
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Download script from original source
+	scriptPath := filepath.Join(tempDir, packageName+".box")
+
+	step, stored, err := m.updateStep(packageName, lock, scriptPath)
+	if err != nil {
+		return err
+	}
+	if stored {
+		fmt.Fprintln(m.out, "reinstalling from the stored recipe")
+	} else {
+		fmt.Fprintf(m.out, "Using original source: %s\n", step.Source.Name)
+		m.showRecipeDiff(lock, scriptPath)
+	}
+	if step.Ref != "" {
+		fmt.Fprintf(m.out, "keeping pin %s\n", step.Ref)
+	}
+
+	if _, err := m.verifyAndReview(packageName, scriptPath, originalRepo, "update"); err != nil {
+		return err
+	}
+
+	// Execute script
+	generation, err := m.runRecipe(step, LogUpdate, tempDir, scriptPath)
+	if err != nil {
+		return err
+	}
+
+	// Create or update lock file after successful installation
+	fmt.Fprintln(m.out, "updating lockfile...")
+
+	// A lock still describing the old generation would leave the package
+	// linked to files pack no longer knows about, so go back to it
+	if _, err := m.writeLock(step, scriptPath, generation); err != nil {
+		m.discardGeneration(packageName, generation, lock)
+		return fmt.Errorf("failed to update lock file: %v", err)
+	}
+	fmt.Fprintln(m.out, "✓ lockfile updated")
+
+	return nil
+}
+
+// AutoUpdateCore silently checks and updates pack/boxlang before package
+// installation, at most once per core check interval
+func (m *Manager) AutoUpdateCore() {
+	// Skip if we checked recently (within last 2 hours)
+	if m.shouldSkipCoreCheck() {
+		return
+	}
+
+	for _, packageName := range corePackages {
+		if hasUpdate, err := m.checkCorePackageForUpdate(packageName, false); err == nil && hasUpdate {
+			fmt.Fprintf(m.out, "🔄 updating %s...\n", packageName)
+			if err := m.Update(packageName); err != nil {
+				fmt.Fprintf(m.out, "warning: failed to auto-update %s: %v\n", packageName, err)
+			} else {
+				fmt.Fprintf(m.out, "✓ %s updated successfully\n", packageName)
+			}
+		}
+	}
+
+	// Update timestamp after check
+	m.updateCoreCheckTimestamp()
+}
+
+func (m *Manager) shouldSkipCoreCheck() bool {
+	data, err := os.ReadFile(m.path("cache", "core_check_timestamp"))
+	if err != nil {
+		return false
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return false
+	}
+
+	// Skip if checked within last core check interval
+	return time.Since(timestamp) < coreCheckIntervalHours*time.Hour
+}
+
+func (m *Manager) updateCoreCheckTimestamp() {
+	cacheDir := m.path("cache")
+	if err := os.MkdirAll(cacheDir, publicDirPerms); err != nil {
+		return
+	}
+
+	timestampFile := filepath.Join(cacheDir, "core_check_timestamp")
+	timestamp := time.Now().Format(time.RFC3339)
+	os.WriteFile(timestampFile, []byte(timestamp), publicFilePerms)
+}
+
+// updateCorePackagesFirst prioritizes pack and boxlang updates during pack
+// update, leaving held ones alone unless includeHeld is set
+func (m *Manager) updateCorePackagesFirst(includeHeld bool) {
+	fmt.Fprintln(m.out, "checking for core package updates...")
+
+	coreUpdatesNeeded := false
+
+	for _, packageName := range corePackages {
+		if hasUpdate, err := m.checkCorePackageForUpdate(packageName, includeHeld); err == nil && hasUpdate {
+			coreUpdatesNeeded = true
+			fmt.Fprintf(m.out, "🔄 updating %s...\n", packageName)
+			if err := m.Update(packageName); err != nil {
+				fmt.Fprintf(m.out, "error updating %s: %v\n", packageName, err)
+			} else {
+				fmt.Fprintf(m.out, "✓ %s updated successfully\n", packageName)
+			}
+		}
+	}
+
+	if coreUpdatesNeeded {
+		fmt.Fprintln(m.out, "core package updates complete, continuing with other packages...")
+	}
+}
+
+// checkCorePackageForUpdate checks if a core package (pack/boxlang) has updates available.
+// A held package never does unless includeHeld is set.
+func (m *Manager) checkCorePackageForUpdate(packageName string, includeHeld bool) (bool, error) {
+	if !m.IsInstalled(packageName) {
+		return false, nil // Package not installed
+	}
+
+	// Read lock file to get current info
+	lock, err := m.Lock(packageName)
+	if err != nil {
+		return false, err
+	}
+	if lock.Held && !includeHeld {
+		return false, nil
+	}
+
+	// Use existing update checking logic
+	_, hasUpdate, err := m.checkPackageForUpdate(packageName, lock)
+	return hasUpdate, err
+}
+
+// isPackageUpToDate checks if a package is installed and up to date
+func (m *Manager) isPackageUpToDate(packageName string) bool {
+	if !m.IsInstalled(packageName) {
+		return false // Package not installed
+	}
+
+	// Use existing update check logic
+	lock, err := m.Lock(packageName)
+	if err != nil {
+		return false
+	}
+
+	_, hasUpdate, err := m.checkPackageForUpdate(packageName, lock)
+	return err == nil && !hasUpdate
+}
@@ -0,0 +1,236 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// calculateSHA256 calculates the SHA256 hash of file contents
+func calculateSHA256(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// VerifyRecipe verifies the Ed25519 signature of the recipe at scriptPath
+// against the keys of sourceRepo. Recipes from the local repository are
+// trusted as they are. The signature is read from scriptPath.sig when that
+// exists and downloaded there otherwise.
+func (m *Manager) VerifyRecipe(scriptPath string, sourceRepo string) error {
+	// Skip verification for local sources
+	if sourceRepo == "local" {
+		return nil
+	}
+
+	// Recipes are verified one at a time: a failed check may clear cached
+	// keys and rewrite sources.box under the other workers' feet
+	m.verifyMu.Lock()
+	defer m.verifyMu.Unlock()
+
+	// Only use Ed25519 signature verification for remote sources
+	if err := m.verifyEd25519Signature(scriptPath, sourceRepo); err != nil {
+		return fmt.Errorf("Ed25519 signature verification failed: %v", err)
+	}
+
+	return nil
+}
+
+// verifyEd25519Signature verifies a detached Ed25519 signature with fallback chain
+func (m *Manager) verifyEd25519Signature(scriptPath string, sourceRepo string) error {
+	sigPath := scriptPath + ".sig"
+
+	provider, err := m.provider(sourceRepo)
+	if err != nil {
+		return err
+	}
+
+	packageName := strings.TrimSuffix(filepath.Base(scriptPath), ".box")
+
+	// A stored recipe brings its signature along, anything else has it
+	// downloaded next to it
+	if _, err := os.Stat(sigPath); err != nil {
+		if err := m.downloadSignature(provider, packageName, sigPath); err != nil {
+			return err
+		}
+	}
+
+	// Read signature
+	sigBytes, err := os.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %v", err)
+	}
+
+	// Decode base64 signature
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigBytes)))
+	if err != nil {
+		return fmt.Errorf("invalid base64 signature: %v", err)
+	}
+
+	// Read recipe content
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to read recipe: %v", err)
+	}
+
+	// Implement fallback chain verification
+	return m.verifyWithKeyChain(content, signature, sourceRepo, provider)
+}
+
+// downloadSignature fetches packageName's signature to sigPath, trying the
+// flat layout before each section
+func (m *Manager) downloadSignature(provider RepoProvider, packageName, sigPath string) error {
+	for _, section := range append([]string{""}, repoSections...) {
+		if err := m.downloadFile(provider.SignatureURL(section, packageName), sigPath); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to download signature: signature file not found in any location")
+}
+
+// verifyWithKeyChain tries multiple keys in order: current, refreshed, previous versions
+func (m *Manager) verifyWithKeyChain(content, signature []byte, sourceRepo string, provider RepoProvider) error {
+	// Step 1: Try current cached key
+	if pubkey, _, err := m.getCachedPublicKeyWithVersion(sourceRepo); err == nil {
+		if verifySignatureWithKey(content, signature, pubkey) == nil {
+			return nil
+		}
+	}
+
+	// Step 2: Try to fetch latest key metadata and verify
+	if metadata, err := m.fetchKeyMetadata(sourceRepo, provider); err == nil {
+		if verifySignatureWithKey(content, signature, metadata.Key) == nil {
+			// Cache the new key
+			m.cachePublicKeyWithVersion(sourceRepo, metadata)
+			return nil
+		}
+
+		// Step 3: If new key fails, try with previous versions (if available)
+		if previousKeys, err := m.fetchPreviousKeyVersions(sourceRepo, metadata.Version); err == nil {
+			for _, prevKey := range previousKeys {
+				if verifySignatureWithKey(content, signature, prevKey) == nil {
+					return nil // Accept previous key during transition
+				}
+			}
+		}
+	}
+
+	// Step 4: Try legacy .pub format as last resort
+	if legacyKey, err := m.fetchLegacyPublicKey(sourceRepo, provider); err == nil {
+		if verifySignatureWithKey(content, signature, legacyKey) == nil {
+			return nil
+		}
+	}
+
+	// Step 5: Clear cache and try once more with fresh fetch
+	m.clearKeyCache(sourceRepo)
+	if metadata, err := m.fetchKeyMetadata(sourceRepo, provider); err == nil {
+		if verifySignatureWithKey(content, signature, metadata.Key) == nil {
+			m.cachePublicKeyWithVersion(sourceRepo, metadata)
+			return nil
+		}
+	}
+
+	// Step 6: Attempt automated recovery from verification failure
+	if err := m.recoverFromVerificationFailure(sourceRepo, provider); err == nil {
+		// Retry verification after recovery
+		if legacyKey, err := m.fetchLegacyPublicKey(sourceRepo, provider); err == nil {
+			if verifySignatureWithKey(content, signature, legacyKey) == nil {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("signature verification failed with all available keys")
+}
+
+// verifySignatureWithKey verifies a signature against a specific public key
+func verifySignatureWithKey(content, signature []byte, pubkeyB64 string) error {
+	pubkeyBytes, err := base64.StdEncoding.DecodeString(pubkeyB64)
+	if err != nil {
+		return fmt.Errorf("invalid base64 public key: %v", err)
+	}
+
+	if len(pubkeyBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key size: expected %d, got %d", ed25519.PublicKeySize, len(pubkeyBytes))
+	}
+
+	if !ed25519.Verify(pubkeyBytes, content, signature) {
+		return fmt.Errorf("signature verification failed")
+	}
+
+	return nil
+}
+
+// recoverFromVerificationFailure attempts to recover from Ed25519 verification failures
+func (m *Manager) recoverFromVerificationFailure(sourceRepo string, provider RepoProvider) error {
+	// 1. Clear cached keys for this source
+	m.clearKeyCache(sourceRepo)
+
+	// 2. Fetch fresh public key from repository
+	pubKey, err := m.fetchLegacyPublicKey(sourceRepo, provider)
+	if err != nil {
+		return fmt.Errorf("failed to fetch fresh public key: %v", err)
+	}
+
+	// 3. Update sources.box configuration
+	if err := m.updateSourcePublicKey(sourceRepo, pubKey); err != nil {
+		return fmt.Errorf("failed to update source configuration: %v", err)
+	}
+
+	return nil
+}
+
+// verifySHA256Hash performs legacy SHA256 self-verification
+func verifySHA256Hash(scriptPath string) error {
+	// Read the file content
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to read script: %v", err)
+	}
+
+	// Extract expected hash from recipe
+	expectedHash, err := extractSHA256FromRecipe(scriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to extract expected hash: %v", err)
+	}
+
+	// Calculate actual hash excluding the SHA256 field
+	contentWithoutSHA256, err := removeCSHA256Field(content)
+	if err != nil {
+		return fmt.Errorf("failed to remove SHA256 field: %v", err)
+	}
+	actualHash := calculateSHA256(contentWithoutSHA256)
+
+	// Compare hashes
+	if actualHash != expectedHash {
+		return fmt.Errorf("SHA256 verification failed: expected %s, got %s", expectedHash, actualHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestVerifyRecipeConcurrent verifies good and badly signed recipes from
+// several goroutines, as the plan's fetch workers do. The bad ones send
+// every worker down the key recovery path, which rewrites the key cache
+// and sources.box.
+func TestVerifyRecipeConcurrent(t *testing.T) {
+	root := t.TempDir()
+	repoDir := t.TempDir()
+	repoURL := "file://" + repoDir
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPub, _, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	pubB64 := base64.StdEncoding.EncodeToString(pub)
+
+	if err := os.MkdirAll(filepath.Join(repoDir, "keys"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "keys", "pack.pub"), []byte(pubB64+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(Options{Root: root, BinDir: filepath.Join(root, "bin")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(m.configPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf("[data -c sources]\n  repo %s\n  pubkey %s\nend\n", repoURL, base64.StdEncoding.EncodeToString(oldPub))
+	if err := os.WriteFile(m.sourcesFile(), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const recipes = 16
+	errs := make([]error, recipes)
+	var wg sync.WaitGroup
+	for i := 0; i < recipes; i++ {
+		dir := t.TempDir()
+		scriptPath := filepath.Join(dir, fmt.Sprintf("pkg%d.box", i))
+		content := []byte(fmt.Sprintf("[data -c pkg%d]\n  version 1\nend\n", i))
+		key := priv
+		if i%2 == 1 {
+			key = otherPriv
+		}
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, content))
+		if err := os.WriteFile(scriptPath, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(scriptPath+".sig", []byte(sig), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func(i int, scriptPath string) {
+			defer wg.Done()
+			errs[i] = m.VerifyRecipe(scriptPath, repoURL)
+		}(i, scriptPath)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i%2 == 0 && err != nil {
+			t.Errorf("recipe %d: %v", i, err)
+		}
+		if i%2 == 1 && err == nil {
+			t.Errorf("recipe %d: bad signature accepted", i)
+		}
+	}
+
+	// Recovery swapped the stale key in sources.box for the repository's
+	file, err := ParseBoxFile(m.sourcesFile())
+	if err != nil {
+		t.Fatalf("sources.box after verification: %v", err)
+	}
+	block := file.Block("sources")
+	if block == nil || block.String("repo") != repoURL || block.String("pubkey") != pubB64 {
+		t.Errorf("sources.box = %+v, want %s with key %s", block, repoURL, pubB64)
+	}
+}
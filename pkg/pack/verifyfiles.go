@@ -0,0 +1,132 @@
+/*
+ * pack - your new least faviroute package manager
+ *
+ * Copyright (C) 2025 Shrub Industries
+ *
+ * This file is part of pack.
+ *
+ * pack is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * pack is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with pack.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pack
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileCheck is the result of checking one package's installed files
+// against its manifest
+type FileCheck struct {
+	Package string
+	// Checked counts the manifest entries that were looked at
+	Checked  int
+	Modified []string
+	Missing  []string
+	// Extra are files on the package's shelf the manifest does not list
+	Extra []string
+}
+
+// OK reports whether everything matched the manifest
+func (c *FileCheck) OK() bool {
+	return len(c.Modified) == 0 && len(c.Missing) == 0 && len(c.Extra) == 0
+}
+
+// VerifyFiles re-hashes packageName's installed files against the manifest
+// recorded when it was installed. Configuration is the user's to edit and
+// is not checked.
+func (m *Manager) VerifyFiles(packageName string) (*FileCheck, error) {
+	mf, err := m.Files(packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var configDirs []string
+	if lock, err := m.Lock(packageName); err == nil && lock.ConfigDir != "" {
+		configDirs = append(configDirs, lock.ConfigDir)
+	}
+
+	check := &FileCheck{Package: packageName}
+	recorded := make(map[string]bool)
+
+	for _, entry := range mf.Entries {
+		recorded[entry.Path] = true
+		if underAny(entry.Path, configDirs) {
+			continue
+		}
+		check.Checked++
+
+		info, err := os.Lstat(entry.Path)
+		if err != nil {
+			check.Missing = append(check.Missing, entry.Path)
+			continue
+		}
+
+		switch entry.Kind {
+		case EntryFile:
+			if !info.Mode().IsRegular() {
+				check.Modified = append(check.Modified, entry.Path)
+			} else if hash, err := hashFile(entry.Path); err != nil || hash != entry.SHA256 {
+				check.Modified = append(check.Modified, entry.Path)
+			}
+		case EntryLink:
+			if target, err := os.Readlink(entry.Path); err != nil || target != entry.Target {
+				check.Modified = append(check.Modified, entry.Path)
+			}
+		case EntryDir:
+			if !info.IsDir() {
+				check.Modified = append(check.Modified, entry.Path)
+			}
+		}
+	}
+
+	// Anything else on the package's own shelf was not put there by pack
+	filepath.WalkDir(m.packageShelf(packageName), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if !recorded[path] {
+			check.Extra = append(check.Extra, path)
+		}
+		return nil
+	})
+	sort.Strings(check.Extra)
+
+	return check, nil
+}
+
+// VerifyAllFiles checks every installed package. Packages that cannot be
+// checked, such as ones installed before manifests were kept, are returned
+// as errors.
+func (m *Manager) VerifyAllFiles() ([]*FileCheck, []*PackageError, error) {
+	installed, err := m.Installed()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var checks []*FileCheck
+	var skipped []*PackageError
+	for _, pkg := range installed {
+		check, err := m.VerifyFiles(pkg.Name)
+		if err != nil {
+			skipped = append(skipped, &PackageError{Package: pkg.Name, Err: fmt.Errorf("cannot verify: %v", err)})
+			continue
+		}
+		checks = append(checks, check)
+	}
+	return checks, skipped, nil
+}